@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+)
+
+// discover runs the "discover" subcommand: it broadcasts the SLMP node search and lists
+// the devices that answer, so PLC_HOST / SEC_PLC_HOST can be filled in without hunting for IPs.
+//
+//	msp-go discover [-addr 255.255.255.255:45237] [-wait 3s]
+func discover(args []string) int {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	addr := fs.String("addr", mcp.NodeSearchBroadcast, "node search destination: broadcast address or a single device, host:port")
+	wait := fs.Duration("wait", 3*time.Second, "how long to wait for responses")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	nodes, err := mcp.SearchNodes(ctx, *addr, *wait)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "node search failed: %v\n", err)
+		return 1
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintf(os.Stderr, "no device answered within %v\n", *wait)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tSUBNET MASK\tGATEWAY\tHOSTNAME\tVENDOR\tMODEL\tVERSION\tPORT")
+	for _, n := range nodes {
+		port := "-"
+		if n.Port != 0 {
+			port = fmt.Sprint(n.Port)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%04X\t%08X\t%04X\t%s\n",
+			n.IP, n.MAC, dottedMask(n.SubnetMask), n.Gateway, n.Hostname, n.VendorCode, n.ModelCode, n.Version, port)
+	}
+	w.Flush()
+	return 0
+}
+
+// dottedMask renders a subnet mask in dotted decimal notation like the IP addresses.
+func dottedMask(mask []byte) string {
+	if len(mask) != 4 {
+		return "-"
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", mask[0], mask[1], mask[2], mask[3])
+}
@@ -29,6 +29,10 @@ top, list, png
 **/
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "discover" {
+		os.Exit(discover(os.Args[2:]))
+	}
+
 	config.Load(".env.local")
 	cfg := config.Cfg
 
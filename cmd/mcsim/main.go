@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mochigome-git/msp-go/pkg/mcsim"
+)
+
+// mcsim runs an MC protocol PLC emulator, so the gateway can be run and tried without a PLC.
+// It answers 3E, 4E and 1E frames in binary and ascii on the same port.
+//
+//	go run ./cmd/mcsim [-tcp :5000] [-udp :5001] [-delay 20ms] [-error 0401=C056] [-password ab12] [-v]
+func main() {
+	tcpAddr := flag.String("tcp", ":5000", "tcp address to listen on, empty for none")
+	udpAddr := flag.String("udp", "", "udp address to listen on, empty for none")
+	delay := flag.Duration("delay", 0, "delay of every response")
+	model := flag.String("model", "Q03UDVCPU", "CPU model name answered to the CPU model read")
+	modelCode := flag.Uint("model-code", 0x0366, "CPU model code answered to the CPU model read")
+	password := flag.String("password", "", "remote password locking every connection until unlocked")
+	verbose := flag.Bool("v", false, "log every request")
+	endCodes := map[uint16]uint16{}
+	flag.Func("error", "answer a command with an end code instead, command=endcode in hex like 0401=C056 or 01=5B (1E); command * for every command. repeatable", func(s string) error {
+		command, endCode, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want command=endcode, got %q", s)
+		}
+		c := uint64(mcsim.AnyCommand)
+		if command != "*" {
+			var err error
+			if c, err = strconv.ParseUint(command, 16, 16); err != nil {
+				return fmt.Errorf("invalid command %q", command)
+			}
+		}
+		e, err := strconv.ParseUint(endCode, 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid end code %q", endCode)
+		}
+		endCodes[uint16(c)] = uint16(e)
+		return nil
+	})
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	opts := mcsim.Options{Model: *model, ModelCode: uint16(*modelCode), Password: *password}
+	if *verbose {
+		opts.Logger = logger
+	}
+	sim := mcsim.New(opts)
+	sim.SetDelay(*delay)
+	for command, endCode := range endCodes {
+		sim.SetEndCode(command, endCode)
+	}
+
+	if *tcpAddr == "" && *udpAddr == "" {
+		logger.Fatalf("nothing to listen on: set -tcp or -udp")
+	}
+	for network, addr := range map[string]string{"tcp": *tcpAddr, "udp": *udpAddr} {
+		if addr == "" {
+			continue
+		}
+		listened, err := sim.Listen(network, addr)
+		if err != nil {
+			logger.Fatalf("listen %v %v: %v", network, addr, err)
+		}
+		logger.Printf("MC protocol PLC emulator listening on %v %v", network, listened)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	sim.Close()
+}
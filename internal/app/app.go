@@ -6,10 +6,12 @@ import (
 	"strconv"
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/mochigome-git/msp-go/internal/profiler"
 	"github.com/mochigome-git/msp-go/internal/worker"
 	"github.com/mochigome-git/msp-go/pkg/config"
 	"github.com/mochigome-git/msp-go/pkg/mqtt"
+	"github.com/mochigome-git/msp-go/pkg/plc"
 
 	"github.com/mochigome-git/msp-go/internal/plcservice"
 )
@@ -70,6 +72,9 @@ func (a *Application) Run(ctx context.Context) error {
 
 	go profiler.Start(a.cfg.Profilling, a.logger)
 
+	// PLCs are identified by the scan loop once reachable and again after every reconnect
+	a.plcSvc.OnIdentity(a.publishMetadata)
+
 	a.workerPool = worker.NewPool(15, a.cfg, a.logger, a.mqttClient, a.plcSvc)
 	a.workerPool.Start()
 	defer a.workerPool.Stop()
@@ -85,3 +90,27 @@ func (a *Application) Run(ctx context.Context) error {
 		}
 	}
 }
+
+// publishMetadata publishes the identity of a PLC as a retained message on the
+// metadata topic, one topic per PLC name.
+func (a *Application) publishMetadata(name string, identity plc.Identity) {
+	if a.mqttClient == nil {
+		return
+	}
+
+	prefix := a.cfg.MqttMetaTopic
+	if prefix == "" {
+		prefix = a.cfg.MqttTopic + "metadata/"
+	}
+
+	payload, err := jsoniter.Marshal(map[string]any{
+		"plc":        name,
+		"model":      identity.Model,
+		"model_code": identity.Code,
+	})
+	if err != nil {
+		a.logger.Printf("JSON marshal error: %v", err)
+		return
+	}
+	mqtt.PublishRetained(a.mqttClient, prefix+name, string(payload), a.logger)
+}
@@ -0,0 +1,102 @@
+package plcservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/plc"
+)
+
+const (
+	// pingTimeout bounds one reachability check.
+	pingTimeout = 3 * time.Second
+	// pingRetryInterval is how long an unreachable PLC is left alone before it is pinged again.
+	pingRetryInterval = 5 * time.Second
+)
+
+// health is the reachability of one PLC as seen by the scan loop.
+type health struct {
+	// reachable is true once a ping succeeded and until a whole scan fails
+	reachable bool
+	// retryAt is when an unreachable PLC may be pinged again
+	retryAt time.Time
+	// rtt is the round trip time of the last successful ping
+	rtt time.Duration
+}
+
+// ready reports whether plcName should be scanned now. A PLC that is not known to be
+// reachable is pinged first; while it does not answer, it is skipped and pinged again
+// every pingRetryInterval instead of failing every single device read.
+func (s *Service) ready(ctx context.Context, plcName string) bool {
+	s.mu.Lock()
+	client, ok := s.clients[plcName]
+	h := s.health[plcName]
+	s.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	if h.reachable {
+		return true
+	}
+	if time.Now().Before(h.retryAt) {
+		return false
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	rtt, err := client.Ping(pingCtx)
+	cancel()
+
+	// a client backing off from failed dials would fail the ping right away until then
+	retryAt := time.Now().Add(pingRetryInterval)
+	if cr, ok := client.(plc.ConnectionReporter); ok {
+		if conn := cr.Connection(); conn.RetryAt.After(retryAt) {
+			retryAt = conn.RetryAt
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.health[plcName] = health{retryAt: retryAt}
+		s.logger.Printf("[%s] PLC unreachable, skipping scan: %v", plcName, err)
+		return false
+	}
+	s.health[plcName] = health{reachable: true, rtt: rtt}
+	s.logger.Printf("[%s] PLC reachable (loopback %v)", plcName, rtt)
+	return true
+}
+
+// markUnreachable records that a whole scan of plcName failed, so the next
+// scan pings it before reading again.
+func (s *Service) markUnreachable(plcName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.health[plcName].reachable {
+		s.logger.Printf("[%s] every read failed, checking PLC with loopback before next scan", plcName)
+	}
+	s.health[plcName] = health{}
+}
+
+// Reachable reports whether plcName answered its last ping and the round trip time of that ping.
+func (s *Service) Reachable(plcName string) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.health[plcName]
+	return h.reachable, h.rtt
+}
+
+// Connection returns the connection state of plcName. It fails for clients
+// that do not keep a connection (plc.ConnectionReporter).
+func (s *Service) Connection(plcName string) (plc.Connection, error) {
+	client, err := s.Client(plcName)
+	if err != nil {
+		return plc.Connection{}, err
+	}
+	cr, ok := client.(plc.ConnectionReporter)
+	if !ok {
+		return plc.Connection{}, fmt.Errorf("plcservice: PLC %q does not report its connection", plcName)
+	}
+	return cr.Connection(), nil
+}
@@ -0,0 +1,107 @@
+package plcservice
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/plc"
+)
+
+const (
+	// identifyTimeout bounds one CPU model read.
+	identifyTimeout = 5 * time.Second
+	// identifyRetryInterval is how long a failed CPU model read is left alone before it is tried again.
+	identifyRetryInterval = 30 * time.Second
+)
+
+// identification is when a PLC was last identified, as seen by the scan loop.
+type identification struct {
+	// conn is the Since of the connection the model was read on; a new connection reads it again
+	conn time.Time
+	// done is true once the model was read on conn
+	done bool
+	// retryAt is when a failed read may be tried again
+	retryAt time.Time
+}
+
+// OnIdentity sets fn to be called from the scan loop whenever a PLC is identified
+// with a model that differs from the one read before, e.g. to publish it.
+func (s *Service) OnIdentity(fn func(plcName string, identity plc.Identity)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onIdentity = fn
+}
+
+// identify asks plcName for its model when it was not read on the current connection yet,
+// logs it and remembers it for Identity. It runs from the scan loop without s.mu held,
+// so a PLC that comes up or reconnects later is identified then.
+// A failure is only logged and tried again after identifyRetryInterval.
+func (s *Service) identify(ctx context.Context, plcName string) {
+	s.mu.Lock()
+	client, ok := s.clients[plcName]
+	fx := s.fx[plcName]
+	id := s.identified[plcName]
+	s.mu.Unlock()
+
+	identifier, isIdentifier := client.(plc.Identifier)
+	if !ok || !isIdentifier || fx {
+		// 1E frames (FX) have no CPU model name read command
+		return
+	}
+	var conn time.Time
+	if cr, ok := client.(plc.ConnectionReporter); ok {
+		conn = cr.Connection().Since
+	}
+	if id.done && id.conn.Equal(conn) || time.Now().Before(id.retryAt) {
+		return
+	}
+
+	idCtx, cancel := context.WithTimeout(ctx, identifyTimeout)
+	identity, err := identifier.Identify(idCtx)
+	cancel()
+
+	s.mu.Lock()
+	if err != nil {
+		s.identified[plcName] = identification{retryAt: time.Now().Add(identifyRetryInterval)}
+		s.mu.Unlock()
+		s.logger.Printf("PLC %s model read failed: %v", plcName, err)
+		return
+	}
+	previous, known := s.identities[plcName]
+	s.identities[plcName] = identity
+	s.identified[plcName] = identification{conn: conn, done: true}
+	onIdentity := s.onIdentity
+	s.mu.Unlock()
+
+	if known && previous == identity {
+		return
+	}
+	s.logger.Printf("PLC %s is %s (model code %s)", plcName, identity.Model, identity.Code)
+	if strings.HasPrefix(strings.ToUpper(identity.Model), "FX") {
+		s.logger.Printf("⚠️ PLC %s reports FX model %s but PLC_MODEL is false — check the FX setting and the IP address", plcName, identity.Model)
+	}
+	if onIdentity != nil {
+		onIdentity(plcName, identity)
+	}
+}
+
+// Identity returns the model last read from plcName.
+// ok is false when the PLC could not be identified yet.
+func (s *Service) Identity(plcName string) (plc.Identity, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity, ok := s.identities[plcName]
+	return identity, ok
+}
+
+// Identities returns the models of every identified PLC by name.
+func (s *Service) Identities() map[string]plc.Identity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identities := make(map[string]plc.Identity, len(s.identities))
+	for name, identity := range s.identities {
+		identities[name] = identity
+	}
+	return identities
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/mochigome-git/msp-go/pkg/plc"
 	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
 )
 
@@ -16,28 +17,91 @@ type WorkerPool interface {
 // ReadAndEnqueue reads all devices from all PLCs and enqueues to worker pool
 func (s *Service) ReadAndEnqueue(ctx context.Context, wp WorkerPool) {
 	for plcName, devList := range s.devices {
-		for _, device := range devList {
-			devCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-			val, err := s.ReadDevice(devCtx, plcName, device)
-			cancel()
-
-			if err != nil {
-				if err == context.DeadlineExceeded {
-					s.logger.Printf("[%s] Timeout reading %s, skipping", plcName, device.DeviceType+device.DeviceNumber)
-					continue
+		if len(devList) == 0 || !s.ready(ctx, plcName) {
+			continue
+		}
+		s.identify(ctx, plcName)
+
+		// a plc.MultiReader batches the devices into as few requests as it can and
+		// issues them concurrently when its connection allows; other clients are
+		// read one device at a time
+		ok, read := s.readMulti(ctx, wp, plcName, devList)
+		if !ok {
+			for _, device := range devList {
+				if s.readAndEnqueueDevice(ctx, wp, plcName, device) {
+					read++
 				}
-				s.logger.Printf("[%s] Failed reading %s: %v", plcName, device.DeviceType+device.DeviceNumber, err)
-				continue
 			}
+		}
+		if read == 0 {
+			s.markUnreachable(plcName)
+		}
+	}
+}
+
+// readMulti reads all devices of a PLC through plc.MultiReader when the client
+// supports it, enqueueing each value. It reports false when the client does not,
+// and how many devices were read successfully.
+func (s *Service) readMulti(ctx context.Context, wp WorkerPool, plcName string, devList []PLC_Utils.Device) (bool, int) {
+	s.mu.Lock()
+	client := s.clients[plcName]
+	fx := s.fx[plcName]
+	s.mu.Unlock()
 
-			msg := map[string]any{
-				"address": device.DeviceType + device.DeviceNumber,
-				"value":   val,
-				"source":  plcName,
+	mr, ok := client.(plc.MultiReader)
+	if !ok {
+		return false, 0
+	}
+
+	scanCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	results := mr.ReadDevices(scanCtx, devList, fx)
+	cancel()
+
+	read := 0
+	for i, res := range results {
+		device := devList[i]
+		if res.Err != nil {
+			if res.Err == context.DeadlineExceeded {
+				s.logger.Printf("[%s] Timeout reading %s, skipping", plcName, device.DeviceType+device.DeviceNumber)
+				continue
 			}
-			wp.Enqueue(msg)
+			s.logger.Printf("[%s] Failed reading %s: %v", plcName, device.DeviceType+device.DeviceNumber, res.Err)
+			continue
 		}
+
+		wp.Enqueue(map[string]any{
+			"address": device.DeviceType + device.DeviceNumber,
+			"value":   res.Value,
+			"source":  plcName,
+		})
+		read++
+	}
+	return true, read
+}
+
+// readAndEnqueueDevice reads one device and enqueues its value, logging failures.
+// It reports whether the device was read.
+func (s *Service) readAndEnqueueDevice(ctx context.Context, wp WorkerPool, plcName string, device PLC_Utils.Device) bool {
+	devCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	val, err := s.ReadDevice(devCtx, plcName, device)
+	cancel()
+
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			s.logger.Printf("[%s] Timeout reading %s, skipping", plcName, device.DeviceType+device.DeviceNumber)
+			return false
+		}
+		s.logger.Printf("[%s] Failed reading %s: %v", plcName, device.DeviceType+device.DeviceNumber, err)
+		return false
+	}
+
+	msg := map[string]any{
+		"address": device.DeviceType + device.DeviceNumber,
+		"value":   val,
+		"source":  plcName,
 	}
+	wp.Enqueue(msg)
+	return true
 }
 
 // ReadDevice reads a single device from a specific PLC
@@ -0,0 +1,26 @@
+package plcservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mochigome-git/msp-go/pkg/plc"
+)
+
+// RemoteOperation runs a remote RUN/STOP/PAUSE/latch clear/reset on plcName.
+// It is never called from the scan or write-map path; the PLC driver refuses it
+// unless remote operation is enabled for that PLC in config.
+func (s *Service) RemoteOperation(ctx context.Context, plcName, op, reason string) error {
+	s.mu.Lock()
+	client, ok := s.clients[plcName]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("PLC client %s not found", plcName)
+	}
+
+	operator, ok := client.(plc.RemoteOperator)
+	if !ok {
+		return fmt.Errorf("PLC %s does not support remote operation", plcName)
+	}
+	return operator.RemoteOperation(ctx, op, reason)
+}
@@ -3,6 +3,7 @@ package plcservice
 import (
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,10 @@ type Service struct {
 	clients      map[string]plc.PLCClient
 	devices      map[string][]PLC_Utils.Device
 	fx           map[string]bool // ← per-PLC, not a single bool
+	identities   map[string]plc.Identity
+	identified   map[string]identification
+	onIdentity   func(plcName string, identity plc.Identity)
+	health       map[string]health
 	deviceValues map[string]any
 	logger       *log.Logger
 	mu           sync.Mutex
@@ -29,6 +34,9 @@ func NewService(logger *log.Logger) *Service {
 		clients:      make(map[string]plc.PLCClient),
 		devices:      make(map[string][]PLC_Utils.Device),
 		fx:           make(map[string]bool),
+		identities:   make(map[string]plc.Identity),
+		identified:   make(map[string]identification),
+		health:       make(map[string]health),
 		deviceValues: make(map[string]any),
 		logger:       logger,
 	}
@@ -43,7 +51,28 @@ func (s *Service) InitPLC(cfg config.PLCConfig, deviceStrs []string) error {
 	case "shibaura":
 		client = shibaura.NewClient(cfg.Host, cfg.Port, 1)
 	default: // "mitsubishi" or empty
-		c, err := mitsubishi.NewMSPClient(cfg.Host, cfg.Port)
+		frame := cfg.Frame
+		if cfg.FxModel && frame == "" {
+			// FX3U-ENET / FX3U-ENET-ADP only speak the 1E frame
+			frame = "1E"
+		}
+		password, err := readSecret(cfg.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read remote password of PLC %s: %w", cfg.Name, err)
+		}
+		c, err := mitsubishi.NewMSPClientWithOptions(cfg.Host, cfg.Port, mitsubishi.Options{
+			Frame:                 frame,
+			Transport:             cfg.Transport,
+			SerialStation:         cfg.SerialStation,
+			SerialFormat:          cfg.SerialFormat,
+			Code:                  cfg.Code,
+			Series:                cfg.Series,
+			Route:                 cfg.Route,
+			TargetCPU:             cfg.TargetCPU,
+			RemotePassword:        password,
+			EnableRemoteOperation: cfg.RemoteOp,
+			Logger:                s.logger,
+		})
 		if err != nil {
 			return fmt.Errorf("failed to connect to PLC %s: %w", cfg.Name, err)
 		}
@@ -80,11 +109,25 @@ func (s *Service) InitPLC(cfg config.PLCConfig, deviceStrs []string) error {
 		}
 	}
 
-	s.logger.Printf("PLC %s initialized at %s:%d brand=%s fx=%v devices=%d",
-		cfg.Name, cfg.Host, cfg.Port, cfg.Brand, cfg.FxModel, len(s.devices[cfg.Name]))
+	// the remote password itself is never logged, only whether one is configured
+	s.logger.Printf("PLC %s initialized at %s:%d brand=%s fx=%v frame=%s transport=%s code=%s series=%s route=%q cpu=%q remote_op=%v remote_password=%v devices=%d",
+		cfg.Name, cfg.Host, cfg.Port, cfg.Brand, cfg.FxModel, cfg.Frame, cfg.Transport, cfg.Code, cfg.Series, cfg.Route, cfg.TargetCPU, cfg.RemoteOp, cfg.PasswordFile != "", len(s.devices[cfg.Name]))
 	return nil
 }
 
+// readSecret returns the content of the secret file at path without its trailing line break,
+// or "" when path is empty. Errors name the file, never its content.
+func readSecret(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
 // FX returns the fx flag for a specific PLC by name.
 // Replace all uses of s.fx with s.FX("main") / s.FX("secondary").
 func (s *Service) FX(plcName string) bool {
@@ -0,0 +1,267 @@
+package plcservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/plc"
+	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient is a plc.PLCClient that also identifies, reports its connection and reads many devices.
+type fakeClient struct {
+	pingErr    error
+	pings      int
+	conn       plc.Connection
+	identity   plc.Identity
+	idErr      error
+	identifies int
+	results    []plc.ReadResult
+	readFx     bool
+}
+
+func (f *fakeClient) ReadData(ctx context.Context, deviceType, deviceNumber string, numberRegisters uint16, fx bool) (any, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) WriteData(ctx context.Context, deviceType, deviceNumber string, writeData []byte, numberRegisters uint16) error {
+	return nil
+}
+
+func (f *fakeClient) BatchWrite(ctx context.Context, deviceType, startDevice string, writeData []byte, maxRegistersPerWrite uint16, logger *log.Logger) error {
+	return nil
+}
+
+func (f *fakeClient) EncodeData(valueStr string, processNumber int) ([]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) Ping(ctx context.Context) (time.Duration, error) {
+	f.pings++
+	if f.pingErr != nil {
+		return 0, f.pingErr
+	}
+	return time.Millisecond, nil
+}
+
+func (f *fakeClient) Connection() plc.Connection {
+	return f.conn
+}
+
+func (f *fakeClient) Identify(ctx context.Context) (plc.Identity, error) {
+	f.identifies++
+	return f.identity, f.idErr
+}
+
+func (f *fakeClient) ReadDevices(ctx context.Context, devices []PLC_Utils.Device, fx bool) []plc.ReadResult {
+	f.readFx = fx
+	return f.results
+}
+
+// plainClient hides every method of a client but plc.PLCClient.
+type plainClient struct {
+	plc.PLCClient
+}
+
+type fakePool struct {
+	msgs []map[string]any
+}
+
+func (p *fakePool) Enqueue(msg map[string]any) {
+	p.msgs = append(p.msgs, msg)
+}
+
+func newTestService(client plc.PLCClient) *Service {
+	s := NewService(log.New(io.Discard, "", 0))
+	s.clients["main"] = client
+	return s
+}
+
+func TestService_Ready(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		unknown   bool
+		health    health
+		pingErr   error
+		retryAt   time.Time
+		want      bool
+		wantPings int
+		// wantRetry is the earliest expected retryAt after a failed ping
+		wantRetry time.Time
+	}{
+		{name: "unknown PLC", unknown: true},
+		{name: "reachable", health: health{reachable: true}, want: true},
+		{name: "waiting for retry", health: health{retryAt: now.Add(time.Minute)}},
+		{name: "ping answers", want: true, wantPings: 1},
+		{name: "ping fails", pingErr: errors.New("refused"), wantPings: 1, wantRetry: now.Add(pingRetryInterval)},
+		{name: "ping fails during dial backoff", pingErr: errors.New("refused"), retryAt: now.Add(time.Minute), wantPings: 1, wantRetry: now.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{pingErr: tt.pingErr, conn: plc.Connection{RetryAt: tt.retryAt}}
+			s := newTestService(client)
+			if tt.unknown {
+				delete(s.clients, "main")
+			}
+			s.health["main"] = tt.health
+
+			assert.Equal(t, tt.want, s.ready(context.Background(), "main"))
+			assert.Equal(t, tt.wantPings, client.pings)
+			if tt.wantPings == 0 {
+				return
+			}
+			h := s.health["main"]
+			assert.Equal(t, tt.want, h.reachable)
+			if tt.pingErr != nil {
+				assert.False(t, h.retryAt.Before(tt.wantRetry), "retryAt %v before %v", h.retryAt, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestService_MarkUnreachable(t *testing.T) {
+	client := &fakeClient{}
+	s := newTestService(client)
+	s.health["main"] = health{reachable: true, rtt: time.Millisecond}
+
+	s.markUnreachable("main")
+	reachable, _ := s.Reachable("main")
+	assert.False(t, reachable)
+
+	// the next scan pings before reading again
+	assert.True(t, s.ready(context.Background(), "main"))
+	assert.Equal(t, 1, client.pings)
+}
+
+func TestService_Identify(t *testing.T) {
+	conn := time.Now()
+	r04 := plc.Identity{Model: "R04CPU", Code: "4800"}
+	tests := []struct {
+		name string
+		// plain hides Identify from the service
+		plain      bool
+		fx         bool
+		identified identification
+		known      *plc.Identity
+		idErr      error
+		wantCalls  int
+		wantNotify int
+		wantRetry  bool
+	}{
+		{name: "not an identifier", plain: true},
+		{name: "fx", fx: true},
+		{name: "first connection", wantCalls: 1, wantNotify: 1},
+		{name: "already read on this connection", identified: identification{conn: conn, done: true}, known: &r04},
+		{name: "reconnected with the same model", identified: identification{conn: conn.Add(-time.Hour), done: true}, known: &r04, wantCalls: 1},
+		{name: "reconnected with another model", identified: identification{conn: conn.Add(-time.Hour), done: true}, known: &plc.Identity{Model: "Q03UDVCPU", Code: "0366"}, wantCalls: 1, wantNotify: 1},
+		{name: "read fails", idErr: errors.New("timeout"), wantCalls: 1, wantRetry: true},
+		{name: "waiting for retry", identified: identification{retryAt: conn.Add(time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{conn: plc.Connection{Since: conn}, identity: r04, idErr: tt.idErr}
+			var s *Service
+			if tt.plain {
+				s = newTestService(plainClient{client})
+			} else {
+				s = newTestService(client)
+			}
+			s.fx["main"] = tt.fx
+			s.identified["main"] = tt.identified
+			if tt.known != nil {
+				s.identities["main"] = *tt.known
+			}
+			notified := 0
+			s.OnIdentity(func(plcName string, identity plc.Identity) {
+				assert.Equal(t, "main", plcName)
+				assert.Equal(t, r04, identity)
+				notified++
+			})
+
+			s.identify(context.Background(), "main")
+			assert.Equal(t, tt.wantCalls, client.identifies)
+			assert.Equal(t, tt.wantNotify, notified)
+			if tt.wantCalls == 0 {
+				return
+			}
+			id := s.identified["main"]
+			if tt.wantRetry {
+				assert.False(t, id.done)
+				assert.True(t, id.retryAt.After(time.Now()))
+				return
+			}
+			assert.Equal(t, identification{conn: conn, done: true}, id)
+			identity, ok := s.Identity("main")
+			assert.True(t, ok)
+			assert.Equal(t, r04, identity)
+		})
+	}
+}
+
+func TestService_ReadMulti(t *testing.T) {
+	devices := []PLC_Utils.Device{
+		{DeviceType: "D", DeviceNumber: "100", NumberRegisters: 1},
+		{DeviceType: "M", DeviceNumber: "0", NumberRegisters: 1},
+	}
+	tests := []struct {
+		name     string
+		plain    bool
+		fx       bool
+		results  []plc.ReadResult
+		wantOK   bool
+		wantRead int
+		wantAddr []string
+	}{
+		{name: "not a multi reader", plain: true},
+		{
+			name:     "all read",
+			results:  []plc.ReadResult{{Value: uint16(7)}, {Value: uint16(1)}},
+			wantOK:   true,
+			wantRead: 2,
+			wantAddr: []string{"D100", "M0"},
+		},
+		{
+			name:     "one fails",
+			fx:       true,
+			results:  []plc.ReadResult{{Err: errors.New("end code C051")}, {Value: uint16(1)}},
+			wantOK:   true,
+			wantRead: 1,
+			wantAddr: []string{"M0"},
+		},
+		{
+			name:    "all time out",
+			results: []plc.ReadResult{{Err: context.DeadlineExceeded}, {Err: context.DeadlineExceeded}},
+			wantOK:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{results: tt.results}
+			var s *Service
+			if tt.plain {
+				s = newTestService(plainClient{client})
+			} else {
+				s = newTestService(client)
+			}
+			s.fx["main"] = tt.fx
+			wp := &fakePool{}
+
+			ok, read := s.readMulti(context.Background(), wp, "main", devices)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantRead, read)
+			assert.Equal(t, tt.fx, client.readFx)
+			var addrs []string
+			for _, msg := range wp.msgs {
+				assert.Equal(t, "main", msg["source"])
+				addrs = append(addrs, msg["address"].(string))
+			}
+			assert.Equal(t, tt.wantAddr, addrs)
+		})
+	}
+}
@@ -46,22 +46,12 @@ func (s *Service) WriteDevice(ctx context.Context, plcName string, device PLC_Ut
 		if err != nil {
 			return err
 		}
-		done := make(chan error, 1)
-		go func() {
-			// CHANGED: was client.Write(device, data)
-			// Now call WriteData or BatchWrite directly on PLCClient
-			if device.NumberRegisters == 1 {
-				done <- client.WriteData(device.DeviceType, device.DeviceNumber, data, device.NumberRegisters)
-			} else {
-				done <- client.BatchWrite(device.DeviceType, device.DeviceNumber, data, device.NumberRegisters, log.Default())
-			}
-		}()
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case err := <-done:
-			return err
+		// CHANGED: was client.Write(device, data)
+		// Now call WriteData or BatchWrite directly on PLCClient; both return once ctx is done
+		if device.NumberRegisters == 1 {
+			return client.WriteData(ctx, device.DeviceType, device.DeviceNumber, data, device.NumberRegisters)
 		}
+		return client.BatchWrite(ctx, device.DeviceType, device.DeviceNumber, data, device.NumberRegisters, log.Default())
 	}
 
 	switch v := value.(type) {
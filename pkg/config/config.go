@@ -14,6 +14,7 @@ type AppConfig struct {
 	Profilling    int    // pprof server port
 	MqttHost      string // mqtthost stores the MQTT broker's hostname
 	MqttTopic     string // topic stores the topic of the MQTT broker
+	MqttMetaTopic string // topic prefix of PLC identity metadata, defaults to MqttTopic + "metadata/"
 	MqttsStr      string // Turn on for TLS connection
 	MqttSkip      bool   //Skip Mqtt use direct mode
 	ECScaCert     string // ESC verion direct read from params store
@@ -25,19 +26,29 @@ type AppConfig struct {
 }
 
 type PLCConfig struct {
-	Name         string // "main", "secondary", "backup"
-	Host         string // plcHost stores the PLC's hostname
-	Port         int    // plcPort stores the PLC's port number
-	FxModel      bool   // Mitsubishi PLC FX series true =1 false =0
-	Devices2     string // store 2bit device for SLMP(Seamless Message Protocol) query
-	Devices16    string // store 16bit device for SLMP(Seamless Message Protocol) query
-	Devices32    string // store 32bit device for SLMP(Seamless Message Protocol) query
-	DevicesAscii string // convert Ascii to text
-	DeviceUpsert string
-	Data         string
-	WriteMap     string
-	CondMap      string // store conditional rules, e.g., "M64==D71,M30!=D80"
-	Brand        string // "shibaura",
+	Name          string // "main", "secondary", "backup"
+	Host          string // plcHost stores the PLC's hostname
+	Port          int    // plcPort stores the PLC's port number
+	FxModel       bool   // Mitsubishi PLC FX series true =1 false =0
+	Frame         string // Mitsubishi MC protocol frame "3E" (default), "4E", "1E" (default when FxModel), "3C" or "4C"
+	Transport     string // Mitsubishi transport "tcp" (default) or "udp" (3E and 1E frames)
+	SerialStation int    // Mitsubishi 3C/4C station number of the serial communication module (0-31)
+	SerialFormat  int    // Mitsubishi 3C/4C frame format 1 (default) or 4 (CR LF terminated)
+	Code          string // Mitsubishi communication data code "binary" (default) or "ascii"
+	Series        string // Mitsubishi CPU series "Q" (default, also L) or "iQ-R"
+	Route         string // Mitsubishi access route "network,station[,module i/o[,multidrop]]", empty = connected PLC
+	TargetCPU     string // Mitsubishi multiple CPU / redundant target "1"-"4", "control", "standby", "A", "B"
+	RemoteOp      bool   // allow remote RUN/STOP/PAUSE/latch clear/reset, false by default
+	PasswordFile  string // Mitsubishi secret file holding the remote password of a protected Ethernet port, empty = none
+	Devices2      string // store 2bit device for SLMP(Seamless Message Protocol) query
+	Devices16     string // store 16bit device for SLMP(Seamless Message Protocol) query
+	Devices32     string // store 32bit device for SLMP(Seamless Message Protocol) query
+	DevicesAscii  string // convert Ascii to text
+	DeviceUpsert  string
+	Data          string
+	WriteMap      string
+	CondMap       string // store conditional rules, e.g., "M64==D71,M30!=D80"
+	Brand         string // "shibaura",
 }
 
 var Cfg AppConfig
@@ -56,37 +67,58 @@ func Load(files ...string) {
 	}
 
 	mainPLC := PLCConfig{
-		Name:         "main",
-		Host:         os.Getenv("PLC_HOST"),
-		Port:         GetEnvAsInt("PLC_PORT", 5011),
-		FxModel:      GetEnvAsBool("PLC_MODEL", false),
-		Devices2:     os.Getenv("DEVICES_2bit"),
-		Devices16:    os.Getenv("DEVICES_16bit"),
-		Devices32:    os.Getenv("DEVICES_32bit"),
-		DevicesAscii: os.Getenv("DEVICES_ASCII"),
-		WriteMap:     os.Getenv("WRITE_MAP_SEC_TO_PRIM"),
-		CondMap:      os.Getenv("WRITE_MAP_SEC_TO_PRIM_CONDITION"),
-		Brand:        strings.ToLower(strings.TrimSpace(os.Getenv("MAIN_PLC_BRAND"))),
+		Name:          "main",
+		Host:          os.Getenv("PLC_HOST"),
+		Port:          GetEnvAsInt("PLC_PORT", 5011),
+		FxModel:       GetEnvAsBool("PLC_MODEL", false),
+		Frame:         strings.ToUpper(strings.TrimSpace(os.Getenv("PLC_FRAME"))),
+		Transport:     strings.ToLower(strings.TrimSpace(os.Getenv("PLC_TRANSPORT"))),
+		SerialStation: GetEnvAsInt("PLC_SERIAL_STATION", 0),
+		SerialFormat:  GetEnvAsInt("PLC_SERIAL_FORMAT", 1),
+		Code:          strings.ToLower(strings.TrimSpace(os.Getenv("PLC_CODE"))),
+		Series:        strings.TrimSpace(os.Getenv("PLC_SERIES")),
+		Route:         strings.TrimSpace(os.Getenv("PLC_ROUTE")),
+		TargetCPU:     strings.TrimSpace(os.Getenv("PLC_TARGET_CPU")),
+		RemoteOp:      GetEnvAsBool("PLC_REMOTE_OPERATION", false),
+		PasswordFile:  strings.TrimSpace(os.Getenv("PLC_REMOTE_PASSWORD_FILE")),
+		Devices2:      os.Getenv("DEVICES_2bit"),
+		Devices16:     os.Getenv("DEVICES_16bit"),
+		Devices32:     os.Getenv("DEVICES_32bit"),
+		DevicesAscii:  os.Getenv("DEVICES_ASCII"),
+		WriteMap:      os.Getenv("WRITE_MAP_SEC_TO_PRIM"),
+		CondMap:       os.Getenv("WRITE_MAP_SEC_TO_PRIM_CONDITION"),
+		Brand:         strings.ToLower(strings.TrimSpace(os.Getenv("MAIN_PLC_BRAND"))),
 	}
 
 	secondaryPLC := PLCConfig{
-		Name:         "secondary",
-		Host:         os.Getenv("SEC_PLC_HOST"),
-		Port:         GetEnvAsInt("SEC_PLC_PORT", 5011),
-		FxModel:      GetEnvAsBool("PLC_MODEL", false),
-		Devices2:     os.Getenv("SEC_DEVICES_2bit"),
-		Devices16:    os.Getenv("SEC_DEVICES_16bit"),
-		Devices32:    os.Getenv("SEC_DEVICES_32bit"),
-		DevicesAscii: os.Getenv("SEC_DEVICES_ASCII"),
-		WriteMap:     os.Getenv("WRITE_MAP_PRIM_TO_SEC"),
-		CondMap:      os.Getenv("WRITE_MAP_PRIM_TO_SEC_CONDITION"),
-		Brand:        strings.ToLower(strings.TrimSpace(os.Getenv("SUB_PLC_BRAND"))),
+		Name:          "secondary",
+		Host:          os.Getenv("SEC_PLC_HOST"),
+		Port:          GetEnvAsInt("SEC_PLC_PORT", 5011),
+		FxModel:       GetEnvAsBool("PLC_MODEL", false),
+		Frame:         strings.ToUpper(strings.TrimSpace(os.Getenv("SEC_PLC_FRAME"))),
+		Transport:     strings.ToLower(strings.TrimSpace(os.Getenv("SEC_PLC_TRANSPORT"))),
+		SerialStation: GetEnvAsInt("SEC_PLC_SERIAL_STATION", 0),
+		SerialFormat:  GetEnvAsInt("SEC_PLC_SERIAL_FORMAT", 1),
+		Code:          strings.ToLower(strings.TrimSpace(os.Getenv("SEC_PLC_CODE"))),
+		Series:        strings.TrimSpace(os.Getenv("SEC_PLC_SERIES")),
+		Route:         strings.TrimSpace(os.Getenv("SEC_PLC_ROUTE")),
+		TargetCPU:     strings.TrimSpace(os.Getenv("SEC_PLC_TARGET_CPU")),
+		RemoteOp:      GetEnvAsBool("SEC_PLC_REMOTE_OPERATION", false),
+		PasswordFile:  strings.TrimSpace(os.Getenv("SEC_PLC_REMOTE_PASSWORD_FILE")),
+		Devices2:      os.Getenv("SEC_DEVICES_2bit"),
+		Devices16:     os.Getenv("SEC_DEVICES_16bit"),
+		Devices32:     os.Getenv("SEC_DEVICES_32bit"),
+		DevicesAscii:  os.Getenv("SEC_DEVICES_ASCII"),
+		WriteMap:      os.Getenv("WRITE_MAP_PRIM_TO_SEC"),
+		CondMap:       os.Getenv("WRITE_MAP_PRIM_TO_SEC_CONDITION"),
+		Brand:         strings.ToLower(strings.TrimSpace(os.Getenv("SUB_PLC_BRAND"))),
 	}
 
 	Cfg = AppConfig{
 		Profilling:    GetEnvAsInt("PPROFT_PORT", 6060),
 		MqttHost:      os.Getenv("MQTT_HOST"),
 		MqttTopic:     os.Getenv("MQTT_TOPIC"),
+		MqttMetaTopic: os.Getenv("MQTT_METADATA_TOPIC"),
 		MqttsStr:      os.Getenv("MQTTS_ON"),
 		MqttSkip:      strings.ToLower(os.Getenv("MQTT_SKIP")) == "true",
 		ECScaCert:     os.Getenv("ECS_MQTT_CA_CERTIFICATE"),
@@ -1,20 +1,149 @@
 package mcp
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AccessRoute is the request destination of a frame: network number, PC number (station number on
+// the network), request destination module I/O number and request destination module station number
+// (multidrop station). It follows the sub header of every 3E/4E frame.
 type AccessRoute struct {
 	Sts  station
 	Code Code
 }
 
-func (r *AccessRoute) BinaryRoute() []byte {
+// Append appends the route to dst in r.Code. Module i/o is stored lower byte first in binary,
+// from upper byte to lower byte in ascii like every other field. The frame builders append
+// their station's route with it.
+func (r *AccessRoute) Append(dst []byte) []byte {
+	h := station{code: r.Code}
+	dst = h.appendField(dst, r.Sts.networkNum)
+	dst = h.appendField(dst, r.Sts.pcNum)
+	dst = h.appendField(dst, r.Sts.unitIONum)
+	return h.appendField(dst, r.Sts.unitStationNum)
+}
 
-	return nil
+// BinaryRoute returns the route in binary code: network(1) + pc(1) + module i/o(2, lower byte first) + station(1).
+func (r *AccessRoute) BinaryRoute() []byte {
+	return (&AccessRoute{Sts: r.Sts, Code: Binary}).Append(nil)
 }
 
+// AsciiRoute returns the route in ascii code. module i/o is stored from upper byte to lower byte.
 func (r *AccessRoute) AsciiRoute() []byte {
-
-	return nil
+	return (&AccessRoute{Sts: r.Sts, Code: Ascii}).Append(nil)
 }
 
+// Len returns the length of the route on the wire: 5 byte in binary, 10 character in ascii.
 func (r *AccessRoute) Len() int64 {
-	return 0
+	if r.Code == Ascii {
+		return 10
+	}
+	return 5
+}
+
+// ParseStation parses a route configuration "network,station[,module i/o[,multidrop station]]".
+// network, station and multidrop station are decimal, module i/o is hex.
+// "1,2" reaches station 2 of network 1 through the connected Ethernet module,
+// "0,255,0000,3" reaches multidrop station 3 of the serial module at I/O 0000.
+// An empty route is the local station (NewLocalStation).
+func ParseStation(route string) (*station, error) {
+	stn := NewLocalStation()
+	route = strings.TrimSpace(route)
+	if route == "" {
+		return stn, nil
+	}
+
+	parts := strings.Split(route, ",")
+	if len(parts) < 2 || len(parts) > 4 {
+		return nil, fmt.Errorf("mcp: route %q must be network,station[,module i/o[,multidrop station]]", route)
+	}
+
+	field := func(i, base, bits int, name string) (uint64, error) {
+		v, err := strconv.ParseUint(strings.TrimSpace(parts[i]), base, bits)
+		if err != nil {
+			return 0, fmt.Errorf("mcp: route %q has invalid %s %q", route, name, parts[i])
+		}
+		return v, nil
+	}
+
+	network, err := field(0, 10, 8, "network number")
+	if err != nil {
+		return nil, err
+	}
+	pc, err := field(1, 10, 8, "station number")
+	if err != nil {
+		return nil, err
+	}
+	stn.networkNum = fmt.Sprintf("%02X", network)
+	stn.pcNum = fmt.Sprintf("%02X", pc)
+
+	if len(parts) > 2 {
+		ioNum, err := field(2, 16, 16, "module i/o number")
+		if err != nil {
+			return nil, err
+		}
+		stn.SetModuleIO(uint16(ioNum))
+	}
+	if len(parts) > 3 {
+		multidrop, err := field(3, 10, 8, "multidrop station number")
+		if err != nil {
+			return nil, err
+		}
+		stn.unitStationNum = fmt.Sprintf("%02X", multidrop)
+	}
+	return stn, nil
+}
+
+// Request destination module I/O numbers of CPU modules.
+const (
+	MODULE_IO_OWN_CPU     = 0x03FF // 自局CPU (接続先のCPU)
+	MODULE_IO_MULTI_CPU1  = 0x03E0 // マルチCPU No.1
+	MODULE_IO_MULTI_CPU2  = 0x03E1 // マルチCPU No.2
+	MODULE_IO_MULTI_CPU3  = 0x03E2 // マルチCPU No.3
+	MODULE_IO_MULTI_CPU4  = 0x03E3 // マルチCPU No.4
+	MODULE_IO_CONTROL_CPU = 0x03D0 // 二重化システム 制御系CPU
+	MODULE_IO_STANDBY_CPU = 0x03D1 // 二重化システム 待機系CPU
+	MODULE_IO_SYSTEM_A    = 0x03D2 // 二重化システム A系CPU
+	MODULE_IO_SYSTEM_B    = 0x03D3 // 二重化システム B系CPU
+)
+
+// ParseTargetCPU converts a configuration value into the request destination module I/O number of a CPU.
+// It accepts "1"-"4" (multiple CPU No.), "control", "standby", "A", "B" (redundant system)
+// or a raw hex module I/O number such as "03E1". An empty value is the connected CPU (03FF).
+func ParseTargetCPU(s string) (uint16, error) {
+	switch v := strings.ToLower(strings.TrimSpace(s)); v {
+	case "", "own", "self":
+		return MODULE_IO_OWN_CPU, nil
+	case "1", "cpu1":
+		return MODULE_IO_MULTI_CPU1, nil
+	case "2", "cpu2":
+		return MODULE_IO_MULTI_CPU2, nil
+	case "3", "cpu3":
+		return MODULE_IO_MULTI_CPU3, nil
+	case "4", "cpu4":
+		return MODULE_IO_MULTI_CPU4, nil
+	case "control":
+		return MODULE_IO_CONTROL_CPU, nil
+	case "standby":
+		return MODULE_IO_STANDBY_CPU, nil
+	case "a", "system-a", "systema":
+		return MODULE_IO_SYSTEM_A, nil
+	case "b", "system-b", "systemb":
+		return MODULE_IO_SYSTEM_B, nil
+	default:
+		ioNum, err := strconv.ParseUint(v, 16, 16)
+		if err != nil || len(v) != 4 {
+			return 0, fmt.Errorf("mcp: unknown target CPU %q", s)
+		}
+		return uint16(ioNum), nil
+	}
+}
+
+// SetModuleIO sets the request destination module I/O number, e.g. MODULE_IO_MULTI_CPU2
+// to reach CPU No.2 of a multiple CPU system or MODULE_IO_STANDBY_CPU of a redundant system.
+func (h *station) SetModuleIO(ioNum uint16) *station {
+	h.unitIONum = fmt.Sprintf("%02X%02X", ioNum&0xFF, ioNum>>8) // lower byte first
+	return h
 }
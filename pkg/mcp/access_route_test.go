@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseStation(t *testing.T) {
+	local, err := ParseStation("")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if path := local.BuildAccessPath(); path != "00FFFF0300" {
+		t.Fatalf("expected %v but actual is %v", "00FFFF0300", path)
+	}
+
+	remote, err := ParseStation("1,2")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	request := remote.BuildReadRequest("D", 300, 3)
+	if request != "50000102FF03000C001000010400002C0100A80300" {
+		t.Fatalf("expected %v but actual is %v", "50000102FF03000C001000010400002C0100A80300", request)
+	}
+
+	multidrop, err := ParseStation("0,255,0010,3")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	route := AccessRoute{Sts: *multidrop, Code: Binary}
+	if !bytes.Equal(route.BinaryRoute(), []byte{0x00, 0xFF, 0x10, 0x00, 0x03}) {
+		t.Fatalf("unexpected binary route % X", route.BinaryRoute())
+	}
+	if path := multidrop.SetCode(Ascii).BuildAccessPath(); path != "00FF001003" {
+		t.Fatalf("expected %v but actual is %v", "00FF001003", path)
+	}
+	route.Code = Ascii
+	if route.Len() != 10 {
+		t.Fatalf("expected ascii route length 10 but actual is %v", route.Len())
+	}
+	// the frames carry the same route: BuildAccessPath appends it through AccessRoute
+	if path := string(route.AsciiRoute()); path != multidrop.BuildAccessPath() {
+		t.Fatalf("expected %v but actual is %v", multidrop.BuildAccessPath(), path)
+	}
+
+	for _, bad := range []string{"1", "1,2,3,4,5", "256,1", "1,x", "1,2,GGGG"} {
+		if _, err := ParseStation(bad); err == nil {
+			t.Fatalf("expected error for route %q", bad)
+		}
+	}
+}
+
+func TestParseTargetCPU(t *testing.T) {
+	cases := map[string]uint16{
+		"":        MODULE_IO_OWN_CPU,
+		"2":       MODULE_IO_MULTI_CPU2,
+		"cpu4":    MODULE_IO_MULTI_CPU4,
+		"Standby": MODULE_IO_STANDBY_CPU,
+		"B":       MODULE_IO_SYSTEM_B,
+		"03E1":    MODULE_IO_MULTI_CPU2,
+	}
+	for s, expected := range cases {
+		ioNum, err := ParseTargetCPU(s)
+		if err != nil || ioNum != expected {
+			t.Fatalf("ParseTargetCPU(%q): expected %04X but actual is %04X (%v)", s, expected, ioNum, err)
+		}
+	}
+	if _, err := ParseTargetCPU("5"); err == nil {
+		t.Fatalf("expected error for CPU No.5")
+	}
+
+	request := NewLocalStation().SetModuleIO(MODULE_IO_MULTI_CPU2).BuildReadRequest("D", 300, 3)
+	if request != "500000FFE103000C001000010400002C0100A80300" {
+		t.Fatalf("expected %v but actual is %v", "500000FFE103000C001000010400002C0100A80300", request)
+	}
+}
@@ -1,30 +1,61 @@
 package mcp
 
 import (
-	"encoding/hex"
+	"context"
 	"fmt"
-	"log"
 	"net"
-	"sync"
+	"time"
 )
 
+// Client sends MC protocol requests to one PLC.
+// Every request is bounded by ctx: when ctx is done the request returns ctx.Err() right away,
+// the socket I/O it was blocked in is interrupted and no goroutine is left behind.
 type Client interface {
-	Read(deviceName string, offset, numPoints int64, fx bool) ([]byte, error)
-	Write(deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error)
+	Read(ctx context.Context, deviceName string, offset, numPoints int64, fx bool) ([]byte, error)
+	Write(ctx context.Context, deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error)
+	// ReadBits reads numPoints consecutive bit devices in bit units.
+	ReadBits(ctx context.Context, deviceName string, offset, numPoints int64) ([]bool, error)
+	// WriteBits sets (true) or resets (false) consecutive bit devices in bit units.
+	WriteBits(ctx context.Context, deviceName string, offset int64, values []bool) ([]byte, error)
+	// RandomRead reads scattered devices in one request. words are read as one word each,
+	// dwords as one double word each. Parse the response with parser.DoRandom.
+	RandomRead(ctx context.Context, words, dwords []Device) ([]byte, error)
+	// RandomWrite writes scattered devices in word and double word units in one request.
+	RandomWrite(ctx context.Context, words, dwords []DeviceValue) ([]byte, error)
+	// RandomWriteBits sets or resets scattered bit devices in one request.
+	RandomWriteBits(ctx context.Context, bits []DeviceValue) ([]byte, error)
+	// ReadBlocks reads several ranges of devices in one request. Parse the response with parser.DoBlocks.
+	ReadBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error)
+	// WriteBlocks writes several ranges of devices in one request.
+	WriteBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error)
+	// ReadBuffer reads numPoints words of the buffer memory of an intelligent function module
+	// (U<module>\G<address>). Parse the response with parser.Do.
+	ReadBuffer(ctx context.Context, module uint16, address, numPoints int64) ([]byte, error)
+	// WriteBuffer writes numPoints words to the buffer memory of an intelligent function module.
+	WriteBuffer(ctx context.Context, module uint16, address, numPoints int64, writeData []byte) ([]byte, error)
+	// Ping runs the loopback test (1906), checks the echoed data and returns the round trip time.
+	Ping(ctx context.Context) (time.Duration, error)
+	// ReadCPUModel reads the model name and model code of the CPU.
+	ReadCPUModel(ctx context.Context) (*CPUModel, error)
+	// Remote runs a remote operation (RUN/STOP/PAUSE/latch clear/reset) on the CPU.
+	Remote(ctx context.Context, op RemoteOp) ([]byte, error)
+	// Status returns the state of the connection to the PLC.
+	Status() ConnStatus
 	Close() error
 }
 
 type client3E struct {
-	// PLC address
-	tcpAddr *net.TCPAddr
+	// PLC address, dialed with timeout and reconnect backoff
+	dialer *connector
 	// PLC station
 	stn *station
 	// TCP connection
 	conn net.Conn
-	// Mutex to synchronize access to conn
-	mu sync.Mutex
-
-	fx bool
+	// lock is held (a value sent) while a request uses conn. It is a channel so that
+	// waiting for the connection can be abandoned when the request context is done.
+	lock chan struct{}
+	// request is the frame buffer every request is built into, reused while lock is held
+	request []byte
 }
 
 func New3EClient(host string, port int, stn *station) (Client, error) {
@@ -32,114 +63,357 @@ func New3EClient(host string, port int, stn *station) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &client3E{tcpAddr: tcpAddr, stn: stn}, nil
+	return &client3E{dialer: newConnector(tcpAddr), stn: stn, lock: make(chan struct{}, 1)}, nil
 }
 
-func (c *client3E) Read(deviceName string, offset int64, numPoints int64, fx bool) ([]byte, error) {
-	requestStr := c.stn.BuildReadRequest(deviceName, offset, numPoints)
+func (c *client3E) Read(ctx context.Context, deviceName string, offset int64, numPoints int64, fx bool) ([]byte, error) {
 	if fx {
-		requestStr = c.stn.BuildReadRequestFx(deviceName, offset, numPoints)
+		// 1E frames are accepted on the same port; see New1EClient for a client that writes them too
+		return (&client1E{c}).Read(ctx, deviceName, offset, numPoints, fx)
 	}
-
-	// TODO binary protocol
-	payload, err := hex.DecodeString(requestStr)
-	if err != nil {
+	if err := c.stn.checkDevice(deviceName, offset); err != nil {
 		return nil, err
 	}
+	return readSplit(ctx, offset, numPoints, c.stn.maxWordPoints(false), wordStep(deviceName, false), false, c.stn.code, func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendReadRequest(dst, deviceName, offset, numPoints)
+		})
+	})
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *client3E) Close() error {
+	c.lock <- struct{}{}
+	defer func() { <-c.lock }()
 
-	// Create connection if it's not already created
-	if c.conn == nil {
-		conn, err := net.DialTCP("tcp", nil, c.tcpAddr)
+	if c.conn != nil {
+		c.lockPassword()
+		err := c.conn.Close()
+		c.conn = nil
+		c.dialer.lost(ErrClientClosed)
+		return err
+	}
+	return nil
+}
+
+// Status returns the state of the connection to the PLC.
+func (c *client3E) Status() ConnStatus {
+	return c.dialer.Status()
+}
+
+// Write is send write command to remote plc by mc protocol
+// deviceName is device code name like 'D' register.
+// offset is device offset addr.
+// writeData is data to write.
+// numPoints is number of write device points.
+// writeData is the data to be written. If writeData is larger than 2*numPoints bytes,
+// data larger than 2*numPoints bytes is ignored.
+func (c *client3E) Write(ctx context.Context, deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error) {
+	if err := checkWrite(deviceName, offset, numPoints, writeData, c.stn); err != nil {
+		return nil, err
+	}
+	return writeSplit(ctx, offset, numPoints, c.stn.maxWordPoints(false), wordStep(deviceName, false), false, writeData, func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendWriteRequest(dst, deviceName, offset, numPoints, writeData)
+		})
+	})
+}
+
+// ReadBits is send read command in bit units to remote plc by mc protocol.
+func (c *client3E) ReadBits(ctx context.Context, deviceName string, offset, numPoints int64) ([]bool, error) {
+	if err := checkBitPoints(deviceName, offset, numPoints, c.stn); err != nil {
+		return nil, err
+	}
+	return readBitsSplit(ctx, offset, numPoints, MAX_BIT_POINTS, func(ctx context.Context, offset, numPoints int64) ([]bool, error) {
+		resp, err := c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitReadRequest(dst, deviceName, offset, numPoints)
+		})
 		if err != nil {
-			log.Fatalf("❌ Failed to connect to PLC at %s: %v", c.tcpAddr.String(), err)
+			return nil, err
 		}
-		c.conn = conn
+		return NewParser().DoBits(resp, int(numPoints))
+	})
+}
+
+// WriteBits is send write command in bit units to remote plc by mc protocol.
+func (c *client3E) WriteBits(ctx context.Context, deviceName string, offset int64, values []bool) ([]byte, error) {
+	if err := checkBitPoints(deviceName, offset, int64(len(values)), c.stn); err != nil {
+		return nil, err
 	}
+	return writeBitsSplit(ctx, offset, values, MAX_BIT_POINTS, false, func(ctx context.Context, offset int64, values []bool) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitWriteRequest(dst, deviceName, offset, values)
+		})
+	})
+}
 
-	// Send message
-	if _, err = c.conn.Write(payload); err != nil {
-		// Close connection on error
-		c.conn.Close()
-		c.conn = nil
+// RandomRead is send random read command to remote plc by mc protocol.
+func (c *client3E) RandomRead(ctx context.Context, words, dwords []Device) ([]byte, error) {
+	if err := checkRandomRead(words, dwords, c.stn); err != nil {
 		return nil, err
 	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomReadRequest(dst, words, dwords)
+	})
+}
 
-	// Receive message
-	readBuff := make([]byte, 22+2*numPoints) // 22 is response header size. [sub header + network num + unit i/o num + unit station num + response length + response code]
-	readLen, err := c.conn.Read(readBuff)
-	if err != nil {
-		// Close connection on error
-		c.conn.Close()
-		c.conn = nil
+// RandomWrite is send random write command in word units to remote plc by mc protocol.
+func (c *client3E) RandomWrite(ctx context.Context, words, dwords []DeviceValue) ([]byte, error) {
+	if err := checkRandomWrite(words, dwords, c.stn); err != nil {
 		return nil, err
 	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomWriteRequest(dst, words, dwords)
+	})
+}
 
-	//return readBuff[:readLen], nil
-	// Process response in a separate goroutine
-	response := readBuff[:readLen]
-	resultChan := make(chan []byte)
-	go func() {
-		resultChan <- response
-	}()
+// RandomWriteBits is send random write command in bit units to remote plc by mc protocol.
+func (c *client3E) RandomWriteBits(ctx context.Context, bits []DeviceValue) ([]byte, error) {
+	if err := checkRandomWriteBits(bits, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomBitWriteRequest(dst, bits)
+	})
+}
 
-	return <-resultChan, nil
+// ReadBlocks is send multiple block batch read command to remote plc by mc protocol.
+func (c *client3E) ReadBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	if err := checkBlocks(wordBlocks, bitBlocks, false, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendMultiBlockReadRequest(dst, wordBlocks, bitBlocks)
+	})
+}
 
+// WriteBlocks is send multiple block batch write command to remote plc by mc protocol.
+func (c *client3E) WriteBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	if err := checkBlocks(wordBlocks, bitBlocks, true, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendMultiBlockWriteRequest(dst, wordBlocks, bitBlocks)
+	})
 }
 
-func (c *client3E) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// ReadBuffer is send intelligent function module buffer memory batch read command to remote plc by mc protocol.
+// module is U of U\G (the start I/O number without its last digit), address is G in words.
+// Reads beyond MAX_BUFFER_POINTS are split.
+func (c *client3E) ReadBuffer(ctx context.Context, module uint16, address, numPoints int64) ([]byte, error) {
+	if err := checkBuffer(module, address, numPoints); err != nil {
+		return nil, err
+	}
+	return readSplit(ctx, address, numPoints, MAX_BUFFER_POINTS, 1, false, c.stn.code, func(ctx context.Context, address, numPoints int64) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBufferReadRequest(dst, module, address, numPoints)
+		})
+	})
+}
 
-	if c.conn != nil {
-		return c.conn.Close()
+// WriteBuffer is send intelligent function module buffer memory batch write command to remote plc by mc protocol.
+// writeData holds 2 byte per point; data larger than 2*numPoints bytes is ignored.
+func (c *client3E) WriteBuffer(ctx context.Context, module uint16, address, numPoints int64, writeData []byte) ([]byte, error) {
+	if err := checkBuffer(module, address, numPoints); err != nil {
+		return nil, err
 	}
-	return nil
+	if int64(len(writeData)) < 2*numPoints {
+		return nil, fmt.Errorf("mcp: %d bytes of data for %d points", len(writeData), numPoints)
+	}
+	return writeSplit(ctx, address, numPoints, MAX_BUFFER_POINTS, 1, false, writeData, func(ctx context.Context, address, numPoints int64, writeData []byte) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBufferWriteRequest(dst, module, address, numPoints, writeData)
+		})
+	})
 }
 
-// Write is send write command to remote plc by mc protocol
-// deviceName is device code name like 'D' register.
-// offset is device offset addr.
-// writeData is data to write.
-// numPoints is number of write device points.
-// writeData is the data to be written. If writeData is larger than 2*numPoints bytes,
-// data larger than 2*numPoints bytes is ignored.
-func (c *client3E) Write(deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error) {
-	requestStr := c.stn.BuildWriteRequest(deviceName, offset, numPoints, writeData)
-	payload, err := hex.DecodeString(requestStr)
+// Ping is send loopback test command to remote plc by mc protocol and returns the round trip time.
+func (c *client3E) Ping(ctx context.Context) (time.Duration, error) {
+	return ping(func() ([]byte, error) {
+		return c.exchange(ctx, c.stn.AppendHealthCheckRequest)
+	}, NewParser().DoHealthCheck)
+}
+
+// ReadCPUModel is send read cpu model name command to remote plc by mc protocol.
+func (c *client3E) ReadCPUModel(ctx context.Context) (*CPUModel, error) {
+	resp, err := c.exchange(ctx, c.stn.AppendCPUModelRequest)
 	if err != nil {
 		return nil, err
 	}
+	return NewParser().DoCPUModel(resp)
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Remote is send remote operation command to remote plc by mc protocol.
+func (c *client3E) Remote(ctx context.Context, op RemoteOp) ([]byte, error) {
+	request, err := c.stn.AppendRemoteRequest(nil, op)
+	if err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return append(dst, request...)
+	})
+}
 
+// exchange sends a 3E request frame appended by build and receives exactly one response frame.
+// 3C/4C responses are returned as the equivalent 3E ascii response, see readFrameSerial.
+func (c *client3E) exchange(ctx context.Context, build func(dst []byte) []byte) ([]byte, error) {
+	return c.roundTrip(ctx, build, c.readResponse)
+}
+
+// readResponse reads one 3E response frame, or one 3C/4C frame converted by readFrameSerial.
+func (c *client3E) readResponse(conn net.Conn) ([]byte, error) {
+	if c.stn.serial != nil {
+		return c.stn.readFrameSerial(conn)
+	}
+	return readFrame3E(conn, c.stn.code)
+}
+
+// exchangeFx sends a 1E request frame appended by build and receives exactly one response frame.
+// dataLen is the size of the response data in bytes (binary) or characters (ascii), see fxDataLen.
+func (c *client3E) exchangeFx(ctx context.Context, build func(dst []byte) []byte, dataLen int) ([]byte, error) {
+	return c.roundTrip(ctx, build, func(conn net.Conn) ([]byte, error) {
+		return readFrameFx(conn, c.stn.code, dataLen)
+	})
+}
+
+// roundTrip sends the request frame appended by build and receives its response with readFrame
+// within responseTimeout or the deadline of ctx, whichever is earlier. Over UDP each attempt
+// waits udpResponseTimeout and the request is sent again up to udpRetransmits times.
+// When ctx is done the blocked socket I/O is interrupted through the connection deadline.
+// The connection is closed on any error, so a partly read or garbled response never
+// leaves bytes behind that the next request would take for its own response.
+func (c *client3E) roundTrip(ctx context.Context, build func(dst []byte) []byte, readFrame func(net.Conn) ([]byte, error)) ([]byte, error) {
+	select {
+	case c.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.lock }()
+
+	// the frame is built in wire format into the buffer of the previous request
+	c.request = build(c.request[:0])
+	payload := c.request
+
+	if !c.dialer.udp() {
+		return c.send(ctx, payload, readFrame, responseTimeout)
+	}
+	// UDP: a lost request or response is sent again. send drops the socket after a timeout,
+	// so every attempt goes out from a new socket.
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, payload, readFrame, udpResponseTimeout)
+		if err == nil || attempt == udpRetransmits || !isTimeout(err) || contextError(ctx) != nil {
+			return resp, err
+		}
+	}
+}
+
+// send sends one request frame and receives its response within timeout or the deadline of ctx,
+// whichever is earlier. c.lock must be held.
+func (c *client3E) send(ctx context.Context, payload []byte, readFrame func(net.Conn) ([]byte, error), timeout time.Duration) ([]byte, error) {
 	// Create connection if it's not already created
+	dialed := false
 	if c.conn == nil {
-		conn, err := net.DialTCP("tcp", nil, c.tcpAddr)
+		conn, err := c.dialer.dial(ctx)
 		if err != nil {
 			return nil, err
 		}
-		c.conn = conn
+		c.conn, dialed = conn, true
 	}
 
-	// Send message
-	if _, err = c.conn.Write(payload); err != nil {
-		c.conn.Close()
-		c.conn = nil
+	deadline := time.Now().Add(timeout)
+	if d := requestDeadline(ctx); d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.drop(err)
 		return nil, err
 	}
+	stop := interruptOnDone(ctx, c.conn)
+	defer stop()
+
+	// a protected port takes no other command before the remote password unlock
+	if dialed {
+		if err := c.unlock(); err != nil {
+			if contextError(ctx) != nil {
+				return nil, c.fail(ctx, err)
+			}
+			c.conn.Close()
+			c.conn = nil
+			c.dialer.rejected(err)
+			return nil, err
+		}
+	}
+
+	// Send message
+	if _, err := c.conn.Write(payload); err != nil {
+		return nil, c.fail(ctx, err)
+	}
 
 	// Receive message
-	readBuff := make([]byte, 22) // 22 = fixed response header size
-	readLen, err := c.conn.Read(readBuff)
+	resp, err := readFrame(c.conn)
 	if err != nil {
-		c.conn.Close()
-		c.conn = nil
-		return nil, err
+		return nil, c.fail(ctx, err)
 	}
 
-	return readBuff[:readLen], nil
+	return resp, nil
+}
+
+// fail drops the connection after a failed request and returns the error to report:
+// ctx.Err() when the request was interrupted because ctx is done. c.lock must be held.
+func (c *client3E) fail(ctx context.Context, err error) error {
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		err = ctxErr
+	}
+	c.drop(err)
+	return err
+}
+
+// drop closes the connection after err. The next request dials again. c.lock must be held.
+func (c *client3E) drop(err error) {
+	c.conn.Close()
+	c.conn = nil
+	c.dialer.lost(err)
+}
+
+// requestDeadline returns the deadline of a request: responseTimeout from now,
+// or the deadline of ctx if that is earlier.
+func requestDeadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(responseTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// contextError returns ctx.Err(), or context.DeadlineExceeded once the deadline of ctx
+// has passed. The socket deadline set from ctx may expire just before ctx itself reports it.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// interruptOnDone unblocks any read or write on conn as soon as ctx is done by moving the
+// connection deadline into the past. Call the returned stop when the request is finished.
+func interruptOnDone(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// ping runs a loopback exchange, validates the echo with check and measures the round trip.
+func ping(exchange func() ([]byte, error), check func(resp []byte) error) (time.Duration, error) {
+	start := time.Now()
+	resp, err := exchange()
+	rtt := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if err := check(resp); err != nil {
+		return 0, err
+	}
+	return rtt, nil
 }
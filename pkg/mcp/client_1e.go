@@ -0,0 +1,197 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupported1E is returned for requests that have no 1E frame command,
+// such as random read, multiple block access and CPU model name read.
+var ErrUnsupported1E = errors.New("mcp: request not available in 1E frames")
+
+// client1E sends 1E frames (MELSEC-F / A compatible) to FX3U-ENET, FX3U-ENET-ADP and
+// other modules that only speak the 1E frame. It shares the connection handling of client3E;
+// every request method is its own, so no 3E frame is ever sent.
+type client1E struct {
+	*client3E
+}
+
+// New1EClient returns a client that sends 1E frames. The fx argument of Read is ignored:
+// every request is a 1E frame. Device numbers of X and Y are the octal numbers converted,
+// see IsOctalDeviceFx.
+func New1EClient(host string, port int, stn *station) (Client, error) {
+	if stn.password != "" {
+		return nil, fmt.Errorf("%w: remote password", ErrUnsupported1E)
+	}
+	c, err := New3EClient(host, port, stn)
+	if err != nil {
+		return nil, err
+	}
+	return &client1E{c.(*client3E)}, nil
+}
+
+// Read is send batch read command in word units to remote plc by mc protocol.
+// Reads beyond MAX_WORD_POINTS_FX are split; parse the response with parser.DoFx.
+func (c *client1E) Read(ctx context.Context, deviceName string, offset, numPoints int64, fx bool) ([]byte, error) {
+	if err := CheckDeviceNumberFx(deviceName, offset); err != nil {
+		return nil, err
+	}
+	return readSplit(ctx, offset, numPoints, c.stn.maxWordPoints(true), wordStep(deviceName, true), true, c.stn.code, func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+		return c.exchangeFx(ctx, func(dst []byte) []byte {
+			return c.stn.AppendReadRequestFx(dst, deviceName, offset, numPoints)
+		}, c.stn.fxDataLen(false, numPoints))
+	})
+}
+
+// Write is send batch write command in word units to remote plc by mc protocol.
+// writeData holds 2 byte per point; data larger than 2*numPoints bytes is ignored.
+func (c *client1E) Write(ctx context.Context, deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error) {
+	if err := CheckDeviceNumberFx(deviceName, offset); err != nil {
+		return nil, err
+	}
+	if numPoints <= 0 {
+		return nil, fmt.Errorf("mcp: write needs at least one point")
+	}
+	if int64(len(writeData)) < 2*numPoints {
+		return nil, fmt.Errorf("mcp: write of %d points needs %d byte of data, got %d", numPoints, 2*numPoints, len(writeData))
+	}
+	return writeSplit(ctx, offset, numPoints, MAX_WORD_POINTS_FX, wordStep(deviceName, true), true, writeData, func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error) {
+		return c.exchangeFx(ctx, func(dst []byte) []byte {
+			return c.stn.AppendWriteRequestFx(dst, deviceName, offset, numPoints, writeData)
+		}, 0)
+	})
+}
+
+// ReadBits is send batch read command in bit units to remote plc by mc protocol.
+func (c *client1E) ReadBits(ctx context.Context, deviceName string, offset, numPoints int64) ([]bool, error) {
+	if err := checkBitDeviceFx(deviceName, offset); err != nil {
+		return nil, err
+	}
+	if numPoints <= 0 {
+		return nil, fmt.Errorf("mcp: bit access needs at least one point")
+	}
+	return readBitsSplit(ctx, offset, numPoints, MAX_BIT_READ_POINTS_FX, func(ctx context.Context, offset, numPoints int64) ([]bool, error) {
+		resp, err := c.exchangeFx(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitReadRequestFx(dst, deviceName, offset, numPoints)
+		}, c.stn.fxDataLen(true, numPoints))
+		if err != nil {
+			return nil, err
+		}
+		return NewParser().DoBitsFx(resp, int(numPoints))
+	})
+}
+
+// WriteBits is send batch write command in bit units to remote plc by mc protocol.
+func (c *client1E) WriteBits(ctx context.Context, deviceName string, offset int64, values []bool) ([]byte, error) {
+	if err := checkBitDeviceFx(deviceName, offset); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("mcp: bit access needs at least one point")
+	}
+	return writeBitsSplit(ctx, offset, values, MAX_BIT_WRITE_POINTS_FX, true, func(ctx context.Context, offset int64, values []bool) ([]byte, error) {
+		return c.exchangeFx(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitWriteRequestFx(dst, deviceName, offset, values)
+		}, 0)
+	})
+}
+
+// RandomWrite is send test (random write) command in word units to remote plc by mc protocol.
+// 1E frames have no double word access, so each of dwords is written as two words,
+// the lower word to the device and the upper word to the next one.
+func (c *client1E) RandomWrite(ctx context.Context, words, dwords []DeviceValue) ([]byte, error) {
+	points := append([]DeviceValue{}, words...)
+	for _, d := range dwords {
+		points = append(points,
+			DeviceValue{Device: d.Device, Value: d.Value & 0xFFFF},
+			DeviceValue{Device: Device{Name: d.Name, Offset: d.Offset + 1}, Value: d.Value >> 16})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("mcp: random write needs at least one device")
+	}
+	if len(points) > MAX_TEST_WORD_POINTS_FX {
+		return nil, fmt.Errorf("mcp: random write of %d words exceeds the limit of %d", len(points), MAX_TEST_WORD_POINTS_FX)
+	}
+	for _, d := range points {
+		if err := CheckDeviceNumberFx(d.Name, d.Offset); err != nil {
+			return nil, err
+		}
+	}
+	return c.exchangeFx(ctx, func(dst []byte) []byte {
+		return c.stn.AppendTestRequestFx(dst, points)
+	}, 0)
+}
+
+// RandomWriteBits is send test (random write) command in bit units to remote plc by mc protocol.
+func (c *client1E) RandomWriteBits(ctx context.Context, bits []DeviceValue) ([]byte, error) {
+	if len(bits) == 0 {
+		return nil, fmt.Errorf("mcp: random bit write needs at least one device")
+	}
+	if len(bits) > MAX_TEST_BIT_POINTS_FX {
+		return nil, fmt.Errorf("mcp: random bit write of %d points exceeds the limit of %d", len(bits), MAX_TEST_BIT_POINTS_FX)
+	}
+	for _, d := range bits {
+		if err := checkBitDeviceFx(d.Name, d.Offset); err != nil {
+			return nil, err
+		}
+	}
+	return c.exchangeFx(ctx, func(dst []byte) []byte {
+		return c.stn.AppendBitTestRequestFx(dst, bits)
+	}, 0)
+}
+
+// RandomRead is not available in 1E frames.
+func (c *client1E) RandomRead(ctx context.Context, words, dwords []Device) ([]byte, error) {
+	return nil, fmt.Errorf("%w: random read", ErrUnsupported1E)
+}
+
+// ReadBlocks is not available in 1E frames.
+func (c *client1E) ReadBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	return nil, fmt.Errorf("%w: multiple block batch read", ErrUnsupported1E)
+}
+
+// WriteBlocks is not available in 1E frames.
+func (c *client1E) WriteBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	return nil, fmt.Errorf("%w: multiple block batch write", ErrUnsupported1E)
+}
+
+// ReadBuffer is not available in 1E frames.
+func (c *client1E) ReadBuffer(ctx context.Context, module uint16, address, numPoints int64) ([]byte, error) {
+	return nil, fmt.Errorf("%w: intelligent function module buffer memory read", ErrUnsupported1E)
+}
+
+// WriteBuffer is not available in 1E frames.
+func (c *client1E) WriteBuffer(ctx context.Context, module uint16, address, numPoints int64, writeData []byte) ([]byte, error) {
+	return nil, fmt.Errorf("%w: intelligent function module buffer memory write", ErrUnsupported1E)
+}
+
+// ReadCPUModel is not available in 1E frames.
+func (c *client1E) ReadCPUModel(ctx context.Context) (*CPUModel, error) {
+	return nil, fmt.Errorf("%w: CPU model name read", ErrUnsupported1E)
+}
+
+// Ping is send loopback test command to remote plc by mc protocol and returns the round trip time.
+func (c *client1E) Ping(ctx context.Context) (time.Duration, error) {
+	// 応答データ: 折り返しデータ数(1byte) + 折り返しデータ "ABCDE"
+	dataLen := 1 + 5
+	if c.stn.code == Ascii {
+		dataLen = 2 + 5
+	}
+	return ping(func() ([]byte, error) {
+		return c.exchangeFx(ctx, c.stn.AppendHealthCheckRequestFx, dataLen)
+	}, NewParser().DoHealthCheckFx)
+}
+
+// Remote is send remote RUN/STOP command to remote plc by mc protocol.
+// Parse the response with parser.DoFx.
+func (c *client1E) Remote(ctx context.Context, op RemoteOp) ([]byte, error) {
+	request, err := c.stn.AppendRemoteRequestFx(nil, op)
+	if err != nil {
+		return nil, err
+	}
+	return c.exchangeFx(ctx, func(dst []byte) []byte {
+		return append(dst, request...)
+	}, 0)
+}
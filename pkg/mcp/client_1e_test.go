@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// serve1E accepts one connection and answers binary 1E batch read/write requests in bit and
+// word units and loopback tests from its own device memory, like an FX3U-ENET would.
+func serve1E(t *testing.T, ln net.Listener) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	type device struct {
+		code   uint16
+		number uint32
+	}
+	words := map[device]uint16{}
+	bits := map[device]bool{}
+
+	for {
+		header := make([]byte, 4) // サブヘッダ + PC番号 + 監視タイマ
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		command := header[0]
+		switch command {
+		case 0x13, 0x14: // リモートRUN/STOPには対応しない
+			conn.Write([]byte{command | 0x80, 0x50})
+			continue
+		case 0x16:
+			n := make([]byte, 1)
+			if _, err := io.ReadFull(conn, n); err != nil {
+				return
+			}
+			data := make([]byte, n[0])
+			if _, err := io.ReadFull(conn, data); err != nil {
+				return
+			}
+			conn.Write(append([]byte{0x96, 0x00, n[0]}, data...))
+			continue
+		}
+
+		spec := make([]byte, 8) // 先頭デバイス(4) + デバイスコード(2) + 点数(1) + 00H
+		if _, err := io.ReadFull(conn, spec); err != nil {
+			return
+		}
+		head := binary.LittleEndian.Uint32(spec[0:4])
+		code := binary.LittleEndian.Uint16(spec[4:6])
+		points := int(spec[6])
+		if points == 0 {
+			points = 256
+		}
+		resp := []byte{command | 0x80, 0x00}
+
+		switch command {
+		case 0x00: // bit read
+			packed := make([]byte, (points+1)/2)
+			for i := 0; i < points; i++ {
+				if bits[device{code, head + uint32(i)}] {
+					packed[i/2] |= 0x10 >> (4 * (i % 2))
+				}
+			}
+			resp = append(resp, packed...)
+		case 0x01: // word read
+			for i := 0; i < points; i++ {
+				resp = binary.LittleEndian.AppendUint16(resp, words[device{code, head + uint32(i)}])
+			}
+		case 0x02: // bit write
+			packed := make([]byte, (points+1)/2)
+			if _, err := io.ReadFull(conn, packed); err != nil {
+				return
+			}
+			for i := 0; i < points; i++ {
+				bits[device{code, head + uint32(i)}] = packed[i/2]&(0x10>>(4*(i%2))) != 0
+			}
+		case 0x03: // word write
+			data := make([]byte, 2*points)
+			if _, err := io.ReadFull(conn, data); err != nil {
+				return
+			}
+			for i := 0; i < points; i++ {
+				words[device{code, head + uint32(i)}] = binary.LittleEndian.Uint16(data[2*i:])
+			}
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient1E_WriteBack(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go serve1E(t, ln)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New1EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatalf("unexpected mcp ping err: %v", err)
+	}
+
+	// 300 words are written and read back in two requests each
+	data := make([]byte, 600)
+	for i := range 300 {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(i+1))
+	}
+	resp, err := client.Write(ctx, "D", 100, 300, data)
+	if err != nil {
+		t.Fatalf("unexpected mcp write err: %v", err)
+	}
+	if _, err := NewParser().DoFx(resp); err != nil {
+		t.Fatalf("unexpected write response %x: %v", resp, err)
+	}
+	resp, err = client.Read(ctx, "D", 100, 300, true)
+	if err != nil {
+		t.Fatalf("unexpected mcp read err: %v", err)
+	}
+	r, err := NewParser().DoFx(resp)
+	if err != nil {
+		t.Fatalf("unexpected read response: %v", err)
+	}
+	if string(r.Payload) != string(data) {
+		t.Fatalf("read back % X, want % X", r.Payload[:8], data[:8])
+	}
+
+	if _, err := client.WriteBits(ctx, "Y", 16, []bool{true, false, true}); err != nil {
+		t.Fatalf("unexpected mcp bit write err: %v", err)
+	}
+	values, err := client.ReadBits(ctx, "Y", 15, 5)
+	if err != nil {
+		t.Fatalf("unexpected mcp bit read err: %v", err)
+	}
+	if want := []bool{false, true, false, true, false}; string(boolBytes(values)) != string(boolBytes(want)) {
+		t.Fatalf("expected %v but actual is %v", want, values)
+	}
+
+	// the fake PLC does not know remote RUN and answers end code 50
+	resp, err = client.Remote(ctx, RemoteRun)
+	if err != nil {
+		t.Fatalf("unexpected mcp remote err: %v", err)
+	}
+	if _, err := NewParser().DoFx(resp); !errors.Is(err, ErrUnsupportedRequest) {
+		t.Fatalf("expected ErrUnsupportedRequest but actual is %v", err)
+	}
+
+	if _, err := client.ReadBits(ctx, "D", 0, 1); err == nil {
+		t.Fatalf("expected an error for a word device read in bit units")
+	}
+	if _, err := client.RandomRead(ctx, []Device{{Name: "D", Offset: 0}}, nil); !errors.Is(err, ErrUnsupported1E) {
+		t.Fatalf("expected ErrUnsupported1E but actual is %v", err)
+	}
+}
+
+func boolBytes(values []bool) []byte {
+	b := make([]byte, len(values))
+	for i, v := range values {
+		if v {
+			b[i] = 1
+		}
+	}
+	return b
+}
@@ -0,0 +1,496 @@
+package mcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SUB_HEADER_4E      = "5400" // 4Eフレーム要求。後ろにシリアル番号(2byte)と固定値0000が続く
+	SUB_HEADER_4E_RESP = "D400" // 4Eフレーム応答
+
+	// 4E response header: sub header(2) + serial(2) + fixed(2) + network(1) + pc(1) + unit i/o(2) + unit station(1) + data length(2)
+	responseHeaderLen4E = 13
+	// same header in ascii mode, 2 characters per byte
+	responseHeaderLen4EAscii = 2 * responseHeaderLen4E
+
+	// responseTimeout is how long a request waits for its response.
+	// Slightly longer than MONITORING_TIMER so the PLC reports its own timeout first.
+	responseTimeout = 5 * time.Second
+)
+
+// ErrClientClosed is returned for requests issued on, or pending at, a closed client.
+var ErrClientClosed = errors.New("mcp: client closed")
+
+// result4E is a response frame (or failure) delivered to a waiting request.
+type result4E struct {
+	resp []byte
+	err  error
+}
+
+// client4E talks 4E frames over a single TCP connection.
+// Every request carries a serial number, so several requests may be in flight
+// at once and responses are matched back to their caller by serial number.
+type client4E struct {
+	// PLC address, dialed with timeout and reconnect backoff
+	dialer *connector
+	// PLC station
+	stn *station
+
+	// mu guards conn, dialing, serial and pending
+	mu   sync.Mutex
+	conn net.Conn
+	// dialing is closed when the dial in progress is done, nil when none is
+	dialing chan struct{}
+	// last serial number handed out
+	serial uint16
+	// requests waiting for a response, keyed by serial number
+	pending map[uint16]chan result4E
+	closed  bool
+	// lastRecv is when conn last delivered a response frame
+	lastRecv time.Time
+
+	// wmu serializes frame writes so frames from concurrent requests never interleave
+	wmu sync.Mutex
+}
+
+func New4EClient(host string, port int, stn *station) (Client, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%v:%v", host, port))
+	if err != nil {
+		return nil, err
+	}
+	return &client4E{dialer: newConnector(tcpAddr), stn: stn, pending: make(map[uint16]chan result4E)}, nil
+}
+
+// Read is send read command to remote plc by mc protocol.
+// fx is accepted for interface compatibility; 1E frames are not carried in 4E.
+func (c *client4E) Read(ctx context.Context, deviceName string, offset, numPoints int64, fx bool) ([]byte, error) {
+	if fx {
+		return nil, errors.New("mcp: 4E client does not support FX (1E frame) requests")
+	}
+	if err := c.stn.checkDevice(deviceName, offset); err != nil {
+		return nil, err
+	}
+	return readSplit(ctx, offset, numPoints, c.stn.maxWordPoints(false), wordStep(deviceName, false), false, c.stn.code, func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendReadRequest(dst, deviceName, offset, numPoints)
+		})
+	})
+}
+
+// Write is send write command to remote plc by mc protocol.
+// See client3E.Write for the meaning of the arguments.
+func (c *client4E) Write(ctx context.Context, deviceName string, offset, numPoints int64, writeData []byte) ([]byte, error) {
+	if err := checkWrite(deviceName, offset, numPoints, writeData, c.stn); err != nil {
+		return nil, err
+	}
+	return writeSplit(ctx, offset, numPoints, c.stn.maxWordPoints(false), wordStep(deviceName, false), false, writeData, func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendWriteRequest(dst, deviceName, offset, numPoints, writeData)
+		})
+	})
+}
+
+// ReadBits is send read command in bit units to remote plc by mc protocol.
+func (c *client4E) ReadBits(ctx context.Context, deviceName string, offset, numPoints int64) ([]bool, error) {
+	if err := checkBitPoints(deviceName, offset, numPoints, c.stn); err != nil {
+		return nil, err
+	}
+	return readBitsSplit(ctx, offset, numPoints, MAX_BIT_POINTS, func(ctx context.Context, offset, numPoints int64) ([]bool, error) {
+		resp, err := c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitReadRequest(dst, deviceName, offset, numPoints)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewParser().DoBits(resp, int(numPoints))
+	})
+}
+
+// WriteBits is send write command in bit units to remote plc by mc protocol.
+func (c *client4E) WriteBits(ctx context.Context, deviceName string, offset int64, values []bool) ([]byte, error) {
+	if err := checkBitPoints(deviceName, offset, int64(len(values)), c.stn); err != nil {
+		return nil, err
+	}
+	return writeBitsSplit(ctx, offset, values, MAX_BIT_POINTS, false, func(ctx context.Context, offset int64, values []bool) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBitWriteRequest(dst, deviceName, offset, values)
+		})
+	})
+}
+
+// RandomRead is send random read command to remote plc by mc protocol.
+func (c *client4E) RandomRead(ctx context.Context, words, dwords []Device) ([]byte, error) {
+	if err := checkRandomRead(words, dwords, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomReadRequest(dst, words, dwords)
+	})
+}
+
+// RandomWrite is send random write command in word units to remote plc by mc protocol.
+func (c *client4E) RandomWrite(ctx context.Context, words, dwords []DeviceValue) ([]byte, error) {
+	if err := checkRandomWrite(words, dwords, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomWriteRequest(dst, words, dwords)
+	})
+}
+
+// RandomWriteBits is send random write command in bit units to remote plc by mc protocol.
+func (c *client4E) RandomWriteBits(ctx context.Context, bits []DeviceValue) ([]byte, error) {
+	if err := checkRandomWriteBits(bits, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendRandomBitWriteRequest(dst, bits)
+	})
+}
+
+// ReadBlocks is send multiple block batch read command to remote plc by mc protocol.
+func (c *client4E) ReadBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	if err := checkBlocks(wordBlocks, bitBlocks, false, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendMultiBlockReadRequest(dst, wordBlocks, bitBlocks)
+	})
+}
+
+// WriteBlocks is send multiple block batch write command to remote plc by mc protocol.
+func (c *client4E) WriteBlocks(ctx context.Context, wordBlocks, bitBlocks []Block) ([]byte, error) {
+	if err := checkBlocks(wordBlocks, bitBlocks, true, c.stn); err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return c.stn.AppendMultiBlockWriteRequest(dst, wordBlocks, bitBlocks)
+	})
+}
+
+// ReadBuffer is send intelligent function module buffer memory batch read command to remote plc by mc protocol.
+// See client3E.ReadBuffer for the meaning of the arguments.
+func (c *client4E) ReadBuffer(ctx context.Context, module uint16, address, numPoints int64) ([]byte, error) {
+	if err := checkBuffer(module, address, numPoints); err != nil {
+		return nil, err
+	}
+	return readSplit(ctx, address, numPoints, MAX_BUFFER_POINTS, 1, false, c.stn.code, func(ctx context.Context, address, numPoints int64) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBufferReadRequest(dst, module, address, numPoints)
+		})
+	})
+}
+
+// WriteBuffer is send intelligent function module buffer memory batch write command to remote plc by mc protocol.
+func (c *client4E) WriteBuffer(ctx context.Context, module uint16, address, numPoints int64, writeData []byte) ([]byte, error) {
+	if err := checkBuffer(module, address, numPoints); err != nil {
+		return nil, err
+	}
+	if int64(len(writeData)) < 2*numPoints {
+		return nil, fmt.Errorf("mcp: %d bytes of data for %d points", len(writeData), numPoints)
+	}
+	return writeSplit(ctx, address, numPoints, MAX_BUFFER_POINTS, 1, false, writeData, func(ctx context.Context, address, numPoints int64, writeData []byte) ([]byte, error) {
+		return c.exchange(ctx, func(dst []byte) []byte {
+			return c.stn.AppendBufferWriteRequest(dst, module, address, numPoints, writeData)
+		})
+	})
+}
+
+// Ping is send loopback test command to remote plc by mc protocol and returns the round trip time.
+func (c *client4E) Ping(ctx context.Context) (time.Duration, error) {
+	return ping(func() ([]byte, error) {
+		return c.exchange(ctx, c.stn.AppendHealthCheckRequest)
+	}, NewParser().DoHealthCheck)
+}
+
+// ReadCPUModel is send read cpu model name command to remote plc by mc protocol.
+func (c *client4E) ReadCPUModel(ctx context.Context) (*CPUModel, error) {
+	resp, err := c.exchange(ctx, c.stn.AppendCPUModelRequest)
+	if err != nil {
+		return nil, err
+	}
+	return NewParser().DoCPUModel(resp)
+}
+
+// Remote is send remote operation command to remote plc by mc protocol.
+func (c *client4E) Remote(ctx context.Context, op RemoteOp) ([]byte, error) {
+	request, err := c.stn.AppendRemoteRequest(nil, op)
+	if err != nil {
+		return nil, err
+	}
+	return c.exchange(ctx, func(dst []byte) []byte {
+		return append(dst, request...)
+	})
+}
+
+// Status returns the state of the connection to the PLC.
+func (c *client4E) Status() ConnStatus {
+	return c.dialer.Status()
+}
+
+func (c *client4E) Close() error {
+	c.lockPassword()
+
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn != nil {
+		c.fail(conn, ErrClientClosed)
+	}
+	return nil
+}
+
+// exchange sends the 3E request frame appended by build as a 4E frame and waits for the
+// response carrying the same serial number. It is safe for concurrent use.
+// When ctx is done the request stops waiting; its late response is dropped by readLoop.
+func (c *client4E) exchange(ctx context.Context, build func(dst []byte) []byte) ([]byte, error) {
+	if err := c.connect(ctx); err != nil {
+		return nil, err
+	}
+	conn := c.conn
+	serial := c.nextSerial()
+	ch := make(chan result4E, 1) // buffered so readLoop never blocks on an abandoned request
+	c.pending[serial] = ch
+	c.mu.Unlock()
+
+	buf := requestPool.Get().(*[]byte)
+	*buf = c.frame4E((*buf)[:0], serial, build)
+	deadline := requestDeadline(ctx)
+	c.wmu.Lock()
+	err := conn.SetWriteDeadline(deadline)
+	if err == nil {
+		_, err = conn.Write(*buf)
+	}
+	c.wmu.Unlock()
+	requestPool.Put(buf)
+	if err != nil {
+		// a partly written frame leaves the stream unusable
+		c.fail(conn, err)
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	sent := time.Now()
+	timer := time.NewTimer(responseTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-ch:
+		return r.resp, r.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, serial)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	case <-timer.C:
+		err := fmt.Errorf("mcp: no response for serial %d within %v", serial, responseTimeout)
+		c.mu.Lock()
+		delete(c.pending, serial)
+		// nothing at all arrived since the request was sent: the connection is dead, not just slow
+		dead := c.lastRecv.Before(sent)
+		c.mu.Unlock()
+		if dead {
+			c.fail(conn, err)
+		}
+		return nil, err
+	}
+}
+
+// connect returns with c.mu held and c.conn open. Without a connection, one request dials
+// and unlocks it without c.mu held, so requests of an open connection are never blocked by
+// a dial; concurrent requests wait for that dial instead of dialing too.
+func (c *client4E) connect(ctx context.Context) error {
+	c.mu.Lock()
+	for {
+		if c.closed {
+			c.mu.Unlock()
+			return ErrClientClosed
+		}
+		if c.conn != nil {
+			return nil
+		}
+		if dialing := c.dialing; dialing != nil {
+			c.mu.Unlock()
+			select {
+			case <-dialing:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			c.mu.Lock()
+			continue
+		}
+
+		dialing := make(chan struct{})
+		c.dialing = dialing
+		c.mu.Unlock()
+		conn, err := c.dial(ctx)
+		c.mu.Lock()
+		c.dialing = nil
+		close(dialing)
+		if err != nil {
+			c.mu.Unlock()
+			return err
+		}
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			c.dialer.lost(ErrClientClosed)
+			return ErrClientClosed
+		}
+		c.conn = conn
+		go c.readLoop(conn)
+		return nil
+	}
+}
+
+// dial connects to the PLC and sends the remote password unlock.
+func (c *client4E) dial(ctx context.Context) (net.Conn, error) {
+	conn, err := c.dialer.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.unlock(ctx, conn); err != nil {
+		conn.Close()
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			c.dialer.lost(ctxErr)
+			return nil, ctxErr
+		}
+		c.dialer.rejected(err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// requestPool holds the frame buffers 4E requests are built into. Requests run concurrently,
+// so each one takes its own buffer for the time of the write.
+var requestPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+// frame4E appends the 4E frame of the 3E request frame appended by build to dst:
+// 5400 + serial + 0000 + the 3E frame without its sub header.
+func (c *client4E) frame4E(dst []byte, serial uint16, build func(dst []byte) []byte) []byte {
+	start := len(dst)
+	// the 3E frame is built behind room for the serial number and 0000; writing the 4E
+	// sub header, serial number and 0000 in place over its front makes it the 4E frame
+	frame := build(c.stn.appendUint(dst, 0, 4))
+	head := c.stn.appendWire(frame[start:start], SUB_HEADER_4E)
+	head = c.stn.appendUint(head, uint64(serial), 2)
+	c.stn.appendUint(head, 0, 2)
+	return frame
+}
+
+// nextSerial returns a serial number that is not used by any pending request.
+// c.mu must be held.
+func (c *client4E) nextSerial() uint16 {
+	for {
+		c.serial++
+		if _, busy := c.pending[c.serial]; !busy {
+			return c.serial
+		}
+	}
+}
+
+// readLoop reads response frames from conn and hands each one to the request
+// waiting on its serial number. It exits when conn fails or is closed.
+func (c *client4E) readLoop(conn net.Conn) {
+	headerLen := responseHeaderLen4E
+	if c.stn.code == Ascii {
+		headerLen = responseHeaderLen4EAscii
+	}
+
+	header := make([]byte, headerLen)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			c.fail(conn, err)
+			return
+		}
+
+		serial, dataLen, err := c.parseHeader(header)
+		if err != nil {
+			c.fail(conn, err)
+			return
+		}
+
+		resp := make([]byte, headerLen+dataLen)
+		copy(resp, header)
+		if _, err := io.ReadFull(conn, resp[headerLen:]); err != nil {
+			c.fail(conn, err)
+			return
+		}
+
+		c.mu.Lock()
+		c.lastRecv = time.Now()
+		ch, ok := c.pending[serial]
+		delete(c.pending, serial)
+		c.mu.Unlock()
+
+		// a response whose request already timed out is dropped
+		if ok {
+			ch <- result4E{resp: resp}
+		}
+	}
+}
+
+// parseHeader returns the serial number and the length of the data following a 4E response header.
+func (c *client4E) parseHeader(header []byte) (uint16, int, error) {
+	if c.stn.code == Ascii {
+		if string(header[0:4]) != SUB_HEADER_4E_RESP {
+			return 0, 0, fmt.Errorf("mcp: unexpected 4E response sub header %q", header[0:4])
+		}
+		serial, err := strconv.ParseUint(string(header[4:8]), 16, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("mcp: invalid 4E serial number %q", header[4:8])
+		}
+		dataLen, err := strconv.ParseUint(string(header[22:26]), 16, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("mcp: invalid 4E data length %q", header[22:26])
+		}
+		if dataLen > maxResponseDataLen {
+			return 0, 0, fmt.Errorf("%w: data length %d too large", ErrFrame, dataLen)
+		}
+		return uint16(serial), int(dataLen), nil
+	}
+
+	if header[0] != 0xD4 || header[1] != 0x00 {
+		return 0, 0, fmt.Errorf("mcp: unexpected 4E response sub header %X", header[0:2])
+	}
+	dataLen := int(binary.LittleEndian.Uint16(header[11:13]))
+	if dataLen > maxResponseDataLen {
+		return 0, 0, fmt.Errorf("%w: data length %d too large", ErrFrame, dataLen)
+	}
+	return binary.LittleEndian.Uint16(header[2:4]), dataLen, nil
+}
+
+// fail closes conn and fails every request still waiting on it.
+// The next request dials a fresh connection.
+func (c *client4E) fail(conn net.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return
+	}
+	conn.Close()
+	c.conn = nil
+	c.dialer.lost(err)
+	for serial, ch := range c.pending {
+		ch <- result4E{err: err}
+		delete(c.pending, serial)
+	}
+}
@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serve4E accepts one connection and answers each 4E read request with the
+// request serial number as the register value. Requests are answered in
+// reverse order of arrival in groups of n, so matching by serial is exercised.
+func serve4E(t *testing.T, ln net.Listener, n int) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var serials []uint16
+		for len(serials) < n {
+			header := make([]byte, 13) // 5400 + serial + 0000 + route(5) + data length(2)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			body := make([]byte, binary.LittleEndian.Uint16(header[11:13]))
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			serials = append(serials, binary.LittleEndian.Uint16(header[2:4]))
+		}
+		for i := len(serials) - 1; i >= 0; i-- {
+			s := serials[i]
+			resp := []byte{0xD4, 0x00, byte(s), byte(s >> 8), 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00, 0x04, 0x00, 0x00, 0x00, byte(s), byte(s >> 8)}
+			if _, err := conn.Write(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestClient4E_PipelinedRead(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	const inFlight = 4
+	go serve4E(t, ln, inFlight)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New4EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, inFlight)
+	for i := 0; i < inFlight; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Read(context.Background(), "D", 100, 1, false)
+			if err != nil {
+				errs <- err
+				return
+			}
+			r, err := NewParser().Do(resp)
+			if err != nil {
+				errs <- err
+				return
+			}
+			// the fake PLC echoes the serial number as data
+			if r.SerialNum != fmt.Sprintf("%X", r.Payload) {
+				t.Errorf("response for serial %v carried data %X", r.SerialNum, r.Payload)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("unexpected mcp read err: %v", err)
+	}
+}
+
+func TestClient4E_ContextCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// answers nothing until the request is abandoned
+	go serve4E(t, ln, 2)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New4EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := client.Read(ctx, "D", 100, 1, false); err != context.Canceled {
+		t.Fatalf("expected canceled but actual is %v", err)
+	}
+
+	c := client.(*client4E)
+	c.mu.Lock()
+	pending := len(c.pending)
+	c.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("expected no pending request after cancel, got %d", pending)
+	}
+
+	// the second request completes the group, so both are answered; the first answer is dropped
+	resp, err := client.Read(context.Background(), "D", 100, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected mcp read err: %v", err)
+	}
+	if r, err := NewParser().Do(resp); err != nil || r.SerialNum != "0200" {
+		t.Fatalf("expected response for serial 2, got %x (%v)", resp, err)
+	}
+}
+
+func TestClient4E_DialOutsideLock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// accepts the connection but never answers the remote password unlock
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New4EClient(addr.IP.String(), addr.Port, NewLocalStation().SetRemotePassword("ab12"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+	c := client.(*client4E)
+
+	dialCtx, cancelDial := context.WithCancel(context.Background())
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := client.Read(dialCtx, "D", 100, 1, false)
+		dialed <- err
+	}()
+	conn := <-accepted
+	defer conn.Close()
+
+	// the unlock in progress holds no lock
+	c.mu.Lock()
+	dialing := c.dialing != nil
+	c.mu.Unlock()
+	if !dialing {
+		t.Fatalf("expected a dial in progress")
+	}
+
+	// a second request waits for that dial instead of dialing again, and gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Read(ctx, "D", 100, 1, false); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded but actual is %v", err)
+	}
+
+	cancelDial()
+	if err := <-dialed; err != context.Canceled {
+		t.Fatalf("expected canceled but actual is %v", err)
+	}
+}
@@ -1,11 +1,16 @@
 package mcp
 
 import (
+	"context"
 	"encoding/hex"
+	"io"
+	"net"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -45,7 +50,7 @@ func TestClient3E_Read(t *testing.T) {
 	}
 
 	// 1 device
-	resp1, err := client.Read("D", 100, 1, false)
+	resp1, err := client.Read(context.Background(), "D", 100, 1, false)
 	if err != nil {
 		t.Fatalf("unexpected mcp read err: %v", err)
 	}
@@ -58,7 +63,7 @@ func TestClient3E_Read(t *testing.T) {
 	}
 
 	// 3 device
-	resp2, err := client.Read("D", 100, 5, false)
+	resp2, err := client.Read(context.Background(), "D", 100, 5, false)
 	if err != nil {
 		t.Fatalf("unexpected mcp read err: %v", err)
 	}
@@ -88,7 +93,7 @@ func TestClient3E_BitRead(t *testing.T) {
 	}
 
 	// 1 device
-	resp1, err := client.Read("B", 100, 1, false)
+	resp1, err := client.Read(context.Background(), "B", 100, 1, false)
 	if err != nil {
 		t.Fatalf("unexpected mcp read err: %v", err)
 	}
@@ -101,7 +106,7 @@ func TestClient3E_BitRead(t *testing.T) {
 	}
 
 	// 3 device
-	resp2, err := client.Read("B", 0, 5, false)
+	resp2, err := client.Read(context.Background(), "B", 0, 5, false)
 	if err != nil {
 		t.Fatalf("unexpected mcp read err: %v", err)
 	}
@@ -115,7 +120,7 @@ func TestClient3E_BitRead(t *testing.T) {
 	}
 
 	// numpoints 5 and 6 will return same responce length
-	resp3, err := client.Read("B", 0, 6, false)
+	resp3, err := client.Read(context.Background(), "B", 0, 6, false)
 	if err != nil {
 		t.Fatalf("unexpected mcp read err: %v", err)
 	}
@@ -148,19 +153,156 @@ func TestClient3E_BitRead(t *testing.T) {
 //		t.Fatalf("unexpected mcp write err: %v", err)
 //	}
 //}
-//
-//func TestClient3E_Ping(t *testing.T) {
-//	// running only when there is and plc that can be accepted mc protocol
-//	if testPLCHost == "" {
-//		t.Skip("environment variable PLC_TEST_HOST is not set")
-//	}
-//	if testPLCPort == 0 {
-//		t.Skip("environment variable PLC_TEST_PORT is not set")
-//	}
-//
-//	client, err := New3EClient(testPLCHost, testPLCPort, NewLocalStation())
-//	if err != nil {
-//		t.Fatalf("PLC does not exists? %v", err)
-//	}
-//
-//}
+
+func TestClient3E_Ping(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// answer one loopback request with the echoed "ABCDE"
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 22)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		resp, _ := hex.DecodeString("d00000ffff030009000000" + "0500" + "4142434445")
+		conn.Write(resp)
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New3EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("PLC does not exists? %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rtt, err := client.Ping(ctx)
+	if err != nil {
+		t.Fatalf("unexpected mcp ping err: %v", err)
+	}
+	if rtt <= 0 {
+		t.Fatalf("expected positive round trip time but actual is %v", rtt)
+	}
+}
+
+func TestClient3E_ContextCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// the first connection never answers, the second answers one read
+	go func() {
+		silent, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer silent.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 21)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		resp, _ := hex.DecodeString("d00000ffff0300040000002a00")
+		conn.Write(resp)
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New3EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("PLC does not exists? %v", err)
+	}
+	defer client.Close()
+
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Read(ctx, "D", 100, 1, false); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded but actual is %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("read returned %v after its deadline", elapsed)
+	}
+	// the AfterFunc that interrupted the read may still be finishing
+	for i := 0; runtime.NumGoroutine() > goroutines; i++ {
+		if i == 100 {
+			t.Fatalf("%d goroutines left behind by the cancelled read", runtime.NumGoroutine()-goroutines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the interrupted connection was dropped, so the next read gets its own response
+	resp, err := client.Read(context.Background(), "D", 100, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected mcp read err: %v", err)
+	}
+	if hex.EncodeToString(resp) != "d00000ffff0300040000002a00" {
+		t.Fatalf("unexpected response %x", resp)
+	}
+}
+
+// serveBench answers every 3E binary request on conn with resp until conn is closed.
+func serveBench(ln net.Listener, resp []byte) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	header := make([]byte, 9)
+	body := make([]byte, 4096)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, body[:int(header[7])|int(header[8])<<8]); err != nil {
+			return
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// BenchmarkClient3E_Read is a scan cycle: a batch read of 64 words and the parse of its payload.
+func BenchmarkClient3E_Read(b *testing.B) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go serveBench(ln, benchResponse())
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New3EClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		b.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Read(ctx, "D", 100, 64, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := NewParser().DoPayload(resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,9 @@
 package mcp
 
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
 // PLC Data communication code.
@@ -20,17 +20,37 @@ const (
 	Binary
 )
 
-func (c Code) EncodeHex(s string) ([]byte, error) {
+// ParseCode converts a configuration value ("ascii" or "binary") into a Code.
+// An empty value selects Binary.
+func ParseCode(s string) (Code, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "binary", "bin":
+		return Binary, nil
+	case "ascii":
+		return Ascii, nil
+	default:
+		return Binary, fmt.Errorf("mcp: unknown communication code %q", s)
+	}
+}
+
+func (c Code) String() string {
 	if c == Ascii {
-		return []byte(s), nil
+		return "ascii"
 	}
+	return "binary"
+}
 
-	decode, err := hex.DecodeString(s)
+// EncodeHex converts a single field written upper byte first (e.g. command "0401")
+// into its representation on the wire.
+//
+// Deprecated: request frames are appended in wire format by the station Append* methods.
+func (c Code) EncodeHex(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("mcp: field %q is not whole bytes", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
 	if err != nil {
 		return nil, err
 	}
-
-	buff := new(bytes.Buffer)
-	_ = binary.Write(buff, binary.LittleEndian, decode)
-	return buff.Bytes(), nil
+	return NewLocalStation().SetCode(c).appendUint(nil, v, len(s)/2), nil
 }
@@ -0,0 +1,164 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// dialTimeout bounds connecting to the PLC, so a powered off PLC fails a request
+	// quickly instead of waiting for the operating system TCP timeout.
+	dialTimeout = 3 * time.Second
+
+	// reconnectBackoffMin is the wait after the first failed dial. It doubles with
+	// every further failure up to reconnectBackoffMax.
+	reconnectBackoffMin = 500 * time.Millisecond
+	reconnectBackoffMax = 30 * time.Second
+)
+
+// ErrReconnectBackoff is returned for requests issued while the client waits to dial the PLC again.
+var ErrReconnectBackoff = errors.New("mcp: waiting to reconnect")
+
+// ConnState is the state of the connection of a client to the PLC.
+type ConnState int
+
+const (
+	// Disconnected is the state before the first request and after the connection was lost or a dial failed.
+	Disconnected ConnState = iota
+	// Connecting is the state while dialing the PLC.
+	Connecting
+	// Connected is the state while a connection is open.
+	Connected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+// ConnStatus is a snapshot of the connection of a client.
+type ConnStatus struct {
+	State ConnState
+	// Since is when State was entered.
+	Since time.Time
+	// Failures is the number of consecutive failed dials.
+	Failures int
+	// LastError is why the last connection was lost or the last dial failed.
+	LastError error
+	// RetryAt is the earliest time the next dial is attempted after a failed dial.
+	RetryAt time.Time
+}
+
+// connector dials the PLC for a client and tracks the connection state.
+// After a failed dial it backs off exponentially with jitter: requests fail fast with
+// ErrReconnectBackoff until RetryAt instead of every request waiting for a dead PLC.
+// addr is a *net.TCPAddr or a *net.UDPAddr; UDP connections are wrapped in datagramConn.
+type connector struct {
+	addr net.Addr
+
+	mu     sync.Mutex
+	status ConnStatus
+}
+
+func newConnector(addr net.Addr) *connector {
+	return &connector{addr: addr, status: ConnStatus{Since: time.Now()}}
+}
+
+// dial connects to the PLC unless the connector is backing off.
+// A dial abandoned because ctx is done does not count as a failure.
+func (d *connector) dial(ctx context.Context) (net.Conn, error) {
+	d.mu.Lock()
+	if now := time.Now(); now.Before(d.status.RetryAt) {
+		err := fmt.Errorf("%w to PLC at %s for %v: %v", ErrReconnectBackoff, d.addr, d.status.RetryAt.Sub(now).Round(time.Millisecond), d.status.LastError)
+		d.mu.Unlock()
+		return nil, err
+	}
+	d.status.State, d.status.Since = Connecting, time.Now()
+	d.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, d.addr.Network(), d.addr.String())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if err != nil && ctx.Err() != nil {
+		d.status.State, d.status.Since = Disconnected, now
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		d.fail(now, err)
+		return nil, fmt.Errorf("failed to connect to PLC at %s: %w", d.addr, err)
+	}
+	d.status = ConnStatus{State: Connected, Since: now}
+	if d.udp() {
+		return newDatagramConn(conn), nil
+	}
+	return conn, nil
+}
+
+// udp reports whether the connector dials UDP. A UDP "connection" is only the socket bound to the PLC
+// address: dialing never fails for a PLC that is down, its requests time out instead.
+func (d *connector) udp() bool {
+	return d.addr.Network() == "udp"
+}
+
+// fail records a failed dial and backs off. d.mu must be held.
+func (d *connector) fail(now time.Time, err error) {
+	failures := d.status.Failures + 1
+	d.status = ConnStatus{
+		State:     Disconnected,
+		Since:     now,
+		Failures:  failures,
+		LastError: err,
+		RetryAt:   now.Add(reconnectBackoff(failures)),
+	}
+}
+
+// rejected records that the connection just dialed was closed because the PLC refused it,
+// like a remote password unlock that failed. It counts as a failed dial and backs off, so
+// a wrong password is not sent again on every request until the CPU locks the port out.
+func (d *connector) rejected(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fail(time.Now(), err)
+}
+
+// lost records that the open connection was closed because of err.
+// The next request dials again right away; only failed dials back off.
+func (d *connector) lost(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = ConnStatus{State: Disconnected, Since: time.Now(), LastError: err}
+}
+
+// Status returns the current connection status.
+func (d *connector) Status() ConnStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// reconnectBackoff returns the wait after failures consecutive failed dials.
+// Half of it is random (equal jitter), so gateways restarted together or clients of
+// several PLCs behind one switch do not reconnect in lockstep.
+func reconnectBackoff(failures int) time.Duration {
+	backoff := reconnectBackoffMax
+	if failures < 16 {
+		backoff = min(reconnectBackoffMin<<(failures-1), reconnectBackoffMax)
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
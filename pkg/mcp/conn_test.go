@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	for failures, max := range map[int]time.Duration{
+		1:   reconnectBackoffMin,
+		2:   2 * reconnectBackoffMin,
+		10:  reconnectBackoffMax,
+		100: reconnectBackoffMax,
+	} {
+		for i := 0; i < 20; i++ {
+			if d := reconnectBackoff(failures); d < max/2 || d > max {
+				t.Fatalf("backoff after %d failures is %v, want between %v and %v", failures, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestConnector(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+
+	d := newConnector(addr)
+	conn, err := d.dial(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected dial err: %v", err)
+	}
+	conn.Close()
+	if s := d.Status(); s.State != Connected {
+		t.Errorf("expected connected, got %v", s.State)
+	}
+
+	d.lost(ErrClientClosed)
+	l.Close()
+
+	// the PLC is gone: the dial fails and the next request fails fast until RetryAt
+	if _, err := d.dial(context.Background()); err == nil {
+		t.Fatalf("expected dial error")
+	}
+	s := d.Status()
+	if s.State != Disconnected || s.Failures != 1 || s.LastError == nil || !s.RetryAt.After(time.Now()) {
+		t.Errorf("unexpected status after failed dial %+v", s)
+	}
+	if _, err := d.dial(context.Background()); !errors.Is(err, ErrReconnectBackoff) {
+		t.Errorf("expected backoff error, got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package mcp
+
+import "fmt"
+
+// CPUModel is the answer of the CPU model name read command (0101).
+type CPUModel struct {
+	// Name is the model name, e.g. "Q03UDVCPU" or "R04CPU" (trailing spaces trimmed)
+	Name string
+	// Code is the model code, e.g. 0x4800 for R04CPU
+	Code uint16
+}
+
+func (m CPUModel) String() string {
+	return fmt.Sprintf("%s (%04X)", m.Name, m.Code)
+}
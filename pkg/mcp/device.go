@@ -0,0 +1,240 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Device is a single device address such as D100 or M64.
+type Device struct {
+	// Name is device code name like 'D' register.
+	Name string
+	// Offset is device offset addr.
+	Offset int64
+}
+
+// DeviceValue is a device and the value to write to it.
+// For word devices the lower 16 bits of Value are written, for double words all 32 bits,
+// and for bits any non-zero Value turns the device ON.
+type DeviceValue struct {
+	Device
+	Value uint32
+}
+
+// Block is a range of Points consecutive devices starting at Device, used by the
+// multiple block batch read and write commands. Bit device blocks count points in words (16 bits).
+type Block struct {
+	Device
+	Points int64
+	// Data is the word data to write (2 byte per point, lower byte first). Unused for reads.
+	Data []byte
+}
+
+// ErrUnknownDevice is returned for a device name that is not in the MC protocol device table.
+var ErrUnknownDevice = errors.New("mcp: unknown device")
+
+// CheckDeviceNumber validates that deviceName is a device of series and that offset fits in the
+// device number field of code: 3 byte (MELSEC-Q/L binary), 6 digits (MELSEC-Q/L ascii, decimal
+// devices) or 4 byte (iQ-R). Clients run it on every request, so a malformed frame is never sent.
+func CheckDeviceNumber(deviceName string, offset int64, series Series, code Code) error {
+	device, ok := devices[deviceName]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownDevice, deviceName)
+	}
+	if !series.HasDevice(deviceName) {
+		return fmt.Errorf("mcp: device %s is only available on MELSEC iQ-R", deviceName)
+	}
+
+	// binary Q/L: 3 byte, iQ-R: 4 byte. ascii Q/L: 6 digits in the device's radix
+	limit := int64(0xFFFFFFFF)
+	if series != IQRSeries {
+		limit = 0xFFFFFF
+		if code == Ascii && !device.hex {
+			limit = 999999
+		}
+	}
+	if offset < 0 || offset > limit {
+		return fmt.Errorf("mcp: device number %s%d is out of range for %s series", deviceName, offset, series)
+	}
+	return nil
+}
+
+// checkDevice validates deviceName and offset for the station's frames, see CheckDeviceNumber.
+func (h *station) checkDevice(deviceName string, offset int64) error {
+	return CheckDeviceNumber(deviceName, offset, h.series, h.code)
+}
+
+// checkBitDevice validates a device accessed in bit units.
+func (h *station) checkBitDevice(deviceName string, offset int64) error {
+	if err := h.checkDevice(deviceName, offset); err != nil {
+		return err
+	}
+	if !IsBitDevice(deviceName) {
+		return fmt.Errorf("mcp: %s is a word device and cannot be accessed in bit units", deviceName)
+	}
+	return nil
+}
+
+// checkWrite validates the device and data size of a batch write in word units.
+func checkWrite(deviceName string, offset, numPoints int64, writeData []byte, stn *station) error {
+	if err := stn.checkDevice(deviceName, offset); err != nil {
+		return err
+	}
+	if numPoints <= 0 {
+		return fmt.Errorf("mcp: write needs at least one point")
+	}
+	if int64(len(writeData)) < 2*numPoints {
+		return fmt.Errorf("mcp: %d bytes of data for %d points", len(writeData), numPoints)
+	}
+	return nil
+}
+
+// checkBuffer validates the module and buffer memory range of an intelligent function module access.
+func checkBuffer(module uint16, address, numPoints int64) error {
+	if module > MAX_BUFFER_MODULE {
+		return fmt.Errorf("mcp: module U%X is out of range U0-U%X", module, MAX_BUFFER_MODULE)
+	}
+	if numPoints <= 0 {
+		return fmt.Errorf("mcp: buffer memory access needs at least one point")
+	}
+	// the byte address of the last point must fit in the 4 byte address field
+	if address < 0 || 2*(address+numPoints) > 0xFFFFFFFF {
+		return fmt.Errorf("mcp: buffer memory U%X\\G%d is out of range", module, address)
+	}
+	return nil
+}
+
+// checkBlocks validates the block and point counts of a multiple block batch request.
+func checkBlocks(wordBlocks, bitBlocks []Block, write bool, stn *station) error {
+	blocks := len(wordBlocks) + len(bitBlocks)
+	if blocks == 0 {
+		return fmt.Errorf("mcp: multiple block request needs at least one block")
+	}
+	if blocks > stn.series.MaxBlocks() {
+		return fmt.Errorf("mcp: %d blocks exceeds the limit of %d", blocks, stn.series.MaxBlocks())
+	}
+	for _, b := range wordBlocks {
+		if err := stn.checkDevice(b.Name, b.Offset); err != nil {
+			return err
+		}
+	}
+	for _, b := range bitBlocks {
+		if err := stn.checkBitDevice(b.Name, b.Offset); err != nil {
+			return err
+		}
+	}
+
+	var points int64
+	for _, b := range append(append([]Block{}, wordBlocks...), bitBlocks...) {
+		if b.Points <= 0 {
+			return fmt.Errorf("mcp: block %s%d has no points", b.Name, b.Offset)
+		}
+		if write && int64(len(b.Data)) < 2*b.Points {
+			return fmt.Errorf("mcp: block %s%d has %d bytes of data for %d points", b.Name, b.Offset, len(b.Data), b.Points)
+		}
+		points += b.Points
+	}
+	if write {
+		points += 4 * int64(blocks)
+	}
+	if points > MAX_BLOCK_POINTS {
+		return fmt.Errorf("mcp: multiple block request of %d points exceeds the limit of %d", points, MAX_BLOCK_POINTS)
+	}
+	return nil
+}
+
+// checkBitPoints validates the device and point count of a batch read/write in bit units.
+// Accesses beyond MAX_BIT_POINTS are split by the client.
+func checkBitPoints(deviceName string, offset, numPoints int64, stn *station) error {
+	if err := stn.checkBitDevice(deviceName, offset); err != nil {
+		return err
+	}
+	if numPoints <= 0 {
+		return fmt.Errorf("mcp: bit access needs at least one point")
+	}
+	return nil
+}
+
+// checkRandomRead validates the point count of a random read request.
+func checkRandomRead(words, dwords []Device, stn *station) error {
+	points := len(words) + len(dwords)
+	if points == 0 {
+		return fmt.Errorf("mcp: random read needs at least one device")
+	}
+	if points > stn.series.MaxRandomReadPoints() {
+		return fmt.Errorf("mcp: random read of %d points exceeds the limit of %d", points, stn.series.MaxRandomReadPoints())
+	}
+	for _, d := range append(append([]Device{}, words...), dwords...) {
+		if err := stn.checkDevice(d.Name, d.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRandomWrite validates the size of a random write request in word units.
+func checkRandomWrite(words, dwords []DeviceValue, stn *station) error {
+	if len(words)+len(dwords) == 0 {
+		return fmt.Errorf("mcp: random write needs at least one device")
+	}
+	if size := len(words)*12 + len(dwords)*14; size > stn.series.MaxRandomWriteSize() {
+		return fmt.Errorf("mcp: random write of %d words and %d double words exceeds the limit", len(words), len(dwords))
+	}
+	for _, d := range append(append([]DeviceValue{}, words...), dwords...) {
+		if err := stn.checkDevice(d.Name, d.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRandomWriteBits validates the point count of a random write request in bit units.
+func checkRandomWriteBits(bits []DeviceValue, stn *station) error {
+	if len(bits) == 0 {
+		return fmt.Errorf("mcp: random bit write needs at least one device")
+	}
+	if len(bits) > stn.series.MaxRandomWriteBitPoints() {
+		return fmt.Errorf("mcp: random bit write of %d points exceeds the limit of %d", len(bits), stn.series.MaxRandomWriteBitPoints())
+	}
+	for _, d := range bits {
+		if err := stn.checkBitDevice(d.Name, d.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockPoints returns the total points of all blocks.
+func blockPoints(wordBlocks, bitBlocks []Block) int64 {
+	var points int64
+	for _, b := range wordBlocks {
+		points += b.Points
+	}
+	for _, b := range bitBlocks {
+		points += b.Points
+	}
+	return points
+}
+
+// CheckDeviceNumberFx validates that deviceName is a device of 1E frames and that offset fits
+// in the 4 byte device number field.
+func CheckDeviceNumberFx(deviceName string, offset int64) error {
+	if _, ok := devicesFx[deviceName]; !ok {
+		return fmt.Errorf("%w %q for FX series", ErrUnknownDevice, deviceName)
+	}
+	if offset < 0 || offset > 0xFFFFFFFF {
+		return fmt.Errorf("mcp: device number %s%d is out of range for FX series", deviceName, offset)
+	}
+	return nil
+}
+
+// checkBitDeviceFx validates a device accessed in bit units by 1E frames.
+func checkBitDeviceFx(deviceName string, offset int64) error {
+	if err := CheckDeviceNumberFx(deviceName, offset); err != nil {
+		return err
+	}
+	if !devicesFx[deviceName].bit {
+		return fmt.Errorf("mcp: %s is a word device and cannot be accessed in bit units", deviceName)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStation_CheckDevice(t *testing.T) {
+	q := NewLocalStation()
+	if err := q.checkDevice("ZR", 0xFFFFFF); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := q.checkDevice("D", 0x1000000); err == nil {
+		t.Fatalf("expected out of range error for 4 byte offset on Q series")
+	}
+	if err := q.checkDevice("LZ", 0); err == nil {
+		t.Fatalf("expected error for iQ-R only device on Q series")
+	}
+	if err := q.checkDevice("QQ", 0); !errors.Is(err, ErrUnknownDevice) {
+		t.Fatalf("expected ErrUnknownDevice but actual is %v", err)
+	}
+	if err := NewLocalStation().SetCode(Ascii).checkDevice("D", 1000000); err == nil {
+		t.Fatalf("expected out of range error for 7 digits in ascii mode")
+	}
+
+	r := NewLocalStation().SetSeries(IQRSeries)
+	if err := r.checkDevice("D", 0x1000000); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := r.checkDevice("LZ", 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := r.checkBitDevice("D", 0); err == nil {
+		t.Fatalf("expected error for word device in bit units")
+	}
+}
+
+func TestCheckBuffer(t *testing.T) {
+	if err := checkBuffer(0xFF, 0, MAX_BUFFER_POINTS); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := checkBuffer(0x100, 0, 1); err == nil {
+		t.Fatalf("expected out of range error for module U100")
+	}
+	if err := checkBuffer(3, -1, 1); err == nil {
+		t.Fatalf("expected error for negative buffer memory address")
+	}
+	if err := checkBuffer(3, 0, 0); err == nil {
+		t.Fatalf("expected error for zero points")
+	}
+}
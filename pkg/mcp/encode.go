@@ -0,0 +1,144 @@
+package mcp
+
+import "strings"
+
+// Request frames are appended directly into byte slices in wire format, strconv.AppendInt style.
+// Clients build every request into a buffer they reuse, so a request costs no hex strings and no
+// decoding. The Build* methods render the same bytes as the hex string (binary) or text (ascii).
+
+const hexDigits = "0123456789ABCDEF"
+
+// appendUint appends v as a size byte field in the station's code:
+// binary stores from lower byte to upper byte, ascii stores hex characters from upper byte to lower byte.
+func (h *station) appendUint(dst []byte, v uint64, size int) []byte {
+	if h.code == Ascii {
+		for i := 2*size - 1; i >= 0; i-- {
+			dst = append(dst, hexDigits[v>>(4*i)&0x0F])
+		}
+		return dst
+	}
+	for i := 0; i < size; i++ {
+		dst = append(dst, byte(v>>(8*i)))
+	}
+	return dst
+}
+
+// appendField appends a field written in binary layout (lower byte first), like READ_COMMAND
+// or the route numbers of station, in the station's code.
+func (h *station) appendField(dst []byte, hexLE string) []byte {
+	return h.appendUint(dst, fieldValue(hexLE), len(hexLE)/2)
+}
+
+// appendWire appends s, a part of a frame that is the same in every code (e.g. SUB_HEADER):
+// decoded in binary, as is in ascii.
+func (h *station) appendWire(dst []byte, s string) []byte {
+	if h.code == Ascii {
+		return append(dst, s...)
+	}
+	for i := 0; i+1 < len(s); i += 2 {
+		dst = append(dst, nibble(s[i])<<4|nibble(s[i+1]))
+	}
+	return dst
+}
+
+// fieldValue is the value of a field written in binary layout: "0100" is 1.
+func fieldValue(hexLE string) uint64 {
+	var v uint64
+	for i := len(hexLE) - 2; i >= 0; i -= 2 {
+		v = v<<8 | uint64(nibble(hexLE[i])<<4|nibble(hexLE[i+1]))
+	}
+	return v
+}
+
+// nibble is the value of hex character c. Fields are constants and validated route numbers.
+func nibble(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
+
+// appendDigits appends v in base as upper case digits, zero padded to width.
+func appendDigits(dst []byte, v uint64, base uint64, width int) []byte {
+	digits := 1
+	for x := v / base; x > 0; x /= base {
+		digits++
+	}
+	start := len(dst)
+	for i := 0; i < max(width, digits); i++ {
+		dst = append(dst, '0')
+	}
+	for i := len(dst) - 1; i >= start && v > 0; i-- {
+		dst[i] = hexDigits[v%base]
+		v /= base
+	}
+	return dst
+}
+
+// upperHex renders b as upper case hex characters, like fmt.Sprintf("%X", b) in a single allocation.
+func upperHex(b []byte) string {
+	var s strings.Builder
+	s.Grow(2 * len(b))
+	for _, v := range b {
+		s.WriteByte(hexDigits[v>>4])
+		s.WriteByte(hexDigits[v&0x0F])
+	}
+	return s.String()
+}
+
+// frameString renders a frame in wire format as the Build* methods return it:
+// upper case hex in binary, the characters themselves in ascii.
+func (h *station) frameString(frame []byte) string {
+	if h.code == Ascii {
+		return string(frame)
+	}
+	return upperHex(frame)
+}
+
+// beginFrame appends the header of a request frame: sub header, route, data length and
+// monitoring timer of 3E, or the control code and header of 3C/4C. The command and request
+// data follow, then endFrame closes the frame with the returned mark.
+func (h *station) beginFrame(dst []byte) ([]byte, int) {
+	if h.serial != nil {
+		return h.appendSerialHeader(append(dst, ENQ)), len(dst)
+	}
+	dst = h.appendWire(dst, SUB_HEADER)
+	dst = h.appendAccessPath(dst)
+	mark := len(dst)
+	dst = h.appendUint(dst, 0, 2) // data length(2byte固定), set by endFrame
+	return h.appendField(dst, MONITORING_TIMER), mark
+}
+
+// endFrame closes a frame begun by beginFrame. 3E sets the data length, counted from the
+// monitoring timer in bytes (binary) or characters (ascii); 3C/4C appends the sum check.
+func (h *station) endFrame(dst []byte, mark int) []byte {
+	if h.serial != nil {
+		var sum byte
+		for _, b := range dst[mark+1:] {
+			sum += b
+		}
+		dst = append(dst, hexDigits[sum>>4], hexDigits[sum&0x0F])
+		if h.serial.format == Format4 {
+			dst = append(dst, CR, LF)
+		}
+		return dst
+	}
+
+	lenSize := 2
+	if h.code == Ascii {
+		lenSize = 4
+	}
+	// appending to the empty slice at the data length field overwrites it in place
+	h.appendUint(dst[mark:mark], uint64(len(dst)-mark-lenSize), 2)
+	return dst
+}
+
+// appendAccessPath appends the access route (network, pc, module i/o, module station) in the station's code.
+func (h *station) appendAccessPath(dst []byte) []byte {
+	route := AccessRoute{Sts: *h, Code: h.code}
+	return route.Append(dst)
+}
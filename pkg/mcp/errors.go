@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Error classes of PLC end codes. Use errors.Is to test an error returned by the parser:
+//
+//	if errors.Is(err, mcp.ErrDeviceOutOfRange) { ... }
+var (
+	// ErrDeviceOutOfRange is a request for a device number beyond the range set in the PLC parameters.
+	ErrDeviceOutOfRange = errors.New("mcp: device out of range")
+	// ErrPointsOutOfRange is a request for more points than the command allows.
+	ErrPointsOutOfRange = errors.New("mcp: number of points out of range")
+	// ErrUnsupportedRequest is a command, sub command or device the PLC cannot process.
+	ErrUnsupportedRequest = errors.New("mcp: request not supported by PLC")
+	// ErrLocked is a request refused because of the remote password.
+	ErrLocked = errors.New("mcp: locked by remote password")
+	// ErrCPU is an error detected by the CPU module (end code 4000-4FFF).
+	ErrCPU = errors.New("mcp: error detected by CPU module")
+)
+
+// ErrorInfo is the error information of a 3E/4E response with a non-zero end code:
+// the access route and command of the request that failed.
+type ErrorInfo struct {
+	NetworkNum     byte
+	PCNum          byte
+	UnitIONum      uint16
+	UnitStationNum byte
+	Command        uint16
+	SubCommand     uint16
+}
+
+// errorInfoSize is the size of the error information in binary code. ascii code uses 2 characters per byte.
+const errorInfoSize = 9
+
+// parseErrorInfo decodes error information stored in binary layout (lower byte first).
+func parseErrorInfo(b []byte) *ErrorInfo {
+	if len(b) < errorInfoSize {
+		return nil
+	}
+	return &ErrorInfo{
+		NetworkNum:     b[0],
+		PCNum:          b[1],
+		UnitIONum:      binary.LittleEndian.Uint16(b[2:4]),
+		UnitStationNum: b[4],
+		Command:        binary.LittleEndian.Uint16(b[5:7]),
+		SubCommand:     binary.LittleEndian.Uint16(b[7:9]),
+	}
+}
+
+// asciiErrorInfo converts ascii error information (upper byte first) into binary layout.
+// Data that is not complete error information is returned as nil.
+func asciiErrorInfo(data []byte) []byte {
+	if len(data) < 2*errorInfoSize {
+		return nil
+	}
+	b, err := hex.DecodeString(string(data[:2*errorInfoSize]))
+	if err != nil {
+		return nil
+	}
+	// module i/o, command and sub command are 2 byte fields
+	for _, i := range []int{2, 5, 7} {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+	return b
+}
+
+// EndCodeError is a response with a non-zero end code.
+type EndCodeError struct {
+	// EndCode is the end code, e.g. 0xC056. 1E frame end codes are one byte, e.g. 0x5B.
+	EndCode uint16
+	// Fx is set for a 1E frame end code.
+	Fx bool
+	// Info is the error information of a 3E/4E response. nil when the PLC sent none.
+	Info *ErrorInfo
+	// AbnormalCode is the abnormal code following the 1E frame end code 5B.
+	AbnormalCode byte
+}
+
+func (e *EndCodeError) Error() string {
+	if e.Fx {
+		msg := fmt.Sprintf("mcp: PLC returned end code %02X (%s)", e.EndCode, e.Description())
+		if e.EndCode == 0x5B {
+			msg += fmt.Sprintf(", abnormal code %02X", e.AbnormalCode)
+		}
+		return msg
+	}
+	msg := fmt.Sprintf("mcp: PLC returned end code %04X (%s)", e.EndCode, e.Description())
+	if e.Info != nil {
+		msg += fmt.Sprintf(" at network %d station %d module i/o %04X/%d, command %04X/%04X",
+			e.Info.NetworkNum, e.Info.PCNum, e.Info.UnitIONum, e.Info.UnitStationNum, e.Info.Command, e.Info.SubCommand)
+	}
+	return msg
+}
+
+// Description returns a human readable description of the end code.
+func (e *EndCodeError) Description() string {
+	if e.Fx {
+		if d, ok := endCodesFx[byte(e.EndCode)]; ok {
+			return d
+		}
+		return "unknown error"
+	}
+	if d, ok := endCodes[e.EndCode]; ok {
+		return d
+	}
+	if e.EndCode >= 0x4000 && e.EndCode <= 0x4FFF {
+		return "error detected by CPU module, see the CPU module manual"
+	}
+	return "unknown error"
+}
+
+// Is reports whether the end code belongs to target, one of the ErrDeviceOutOfRange,
+// ErrPointsOutOfRange, ErrUnsupportedRequest, ErrLocked or ErrCPU classes.
+func (e *EndCodeError) Is(target error) bool {
+	if e.Fx {
+		return endCodeClassesFx[byte(e.EndCode)] == target
+	}
+	if target == ErrCPU {
+		return e.EndCode >= 0x4000 && e.EndCode <= 0x4FFF
+	}
+	return target != nil && endCodeClasses[e.EndCode] == target
+}
+
+// 3E/4Eフレーム 終了コード (Ethernetインタフェースユニットが検出したエラー)
+var endCodes = map[uint16]string{
+	0xC050: "ascii data that cannot be converted to binary was received",
+	0xC051: "number of bit device points out of range",
+	0xC052: "number of word device points out of range",
+	0xC053: "number of random bit points out of range",
+	0xC054: "number of random word points out of range",
+	0xC055: "number of file data points out of range",
+	0xC056: "device out of range",
+	0xC057: "request data length does not match the number of data",
+	0xC058: "request data length after ascii to binary conversion does not match",
+	0xC059: "command or sub command not supported",
+	0xC05A: "device cannot be read or written by this module",
+	0xC05B: "device cannot be read or written by the CPU module",
+	0xC05C: "request content error",
+	0xC05D: "monitor registration is not performed",
+	0xC05E: "CPU monitoring timer expired",
+	0xC05F: "request cannot be executed by the target CPU",
+	0xC060: "request content error in bit device data",
+	0xC061: "request data length does not match the number of data",
+	0xC06F: "communication data code (binary/ascii) does not match the setting",
+	0xC070: "device memory extension cannot be specified for the target station",
+	0xC0B5: "data the CPU module cannot handle was specified",
+	0xC200: "remote password does not match",
+	0xC201: "port is locked by remote password",
+	0xC204: "request was not sent by the device that unlocked the remote password",
+}
+
+var endCodeClasses = map[uint16]error{
+	0xC051: ErrPointsOutOfRange,
+	0xC052: ErrPointsOutOfRange,
+	0xC053: ErrPointsOutOfRange,
+	0xC054: ErrPointsOutOfRange,
+	0xC055: ErrPointsOutOfRange,
+	0xC056: ErrDeviceOutOfRange,
+	0xC059: ErrUnsupportedRequest,
+	0xC05A: ErrUnsupportedRequest,
+	0xC05B: ErrUnsupportedRequest,
+	0xC05F: ErrUnsupportedRequest,
+	0xC070: ErrUnsupportedRequest,
+	0xC200: ErrLocked,
+	0xC201: ErrLocked,
+	0xC204: ErrLocked,
+}
+
+// 1Eフレーム 終了コード
+var endCodesFx = map[byte]string{
+	0x50: "command or response type not supported",
+	0x51: "CPU cannot communicate with the module",
+	0x52: "device out of range",
+	0x54: "ascii data that cannot be converted to binary was received",
+	0x55: "data cannot be written while the CPU is in RUN",
+	0x56: "device not supported",
+	0x57: "number of points out of range",
+	0x58: "start or last device out of range",
+	0x5B: "CPU module error",
+	0x60: "CPU monitoring timer expired",
+}
+
+var endCodeClassesFx = map[byte]error{
+	0x50: ErrUnsupportedRequest,
+	0x52: ErrDeviceOutOfRange,
+	0x56: ErrUnsupportedRequest,
+	0x57: ErrPointsOutOfRange,
+	0x58: ErrDeviceOutOfRange,
+	0x5B: ErrCPU,
+}
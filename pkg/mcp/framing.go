@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// 3E response header: sub header(2) + network(1) + pc(1) + unit i/o(2) + unit station(1) + data length(2)
+	responseHeaderLen3E = 9
+	// same header in ascii mode, 2 characters per byte
+	responseHeaderLen3EAscii = 2 * responseHeaderLen3E
+
+	// maxResponseDataLen bounds the data length field. The largest response, 960 words in ascii,
+	// is far below it, so a larger value means the stream is out of sync.
+	maxResponseDataLen = 8192
+
+	// responseDataHint is the data length a response buffer is allocated for before the header
+	// tells the actual one: a batch read of 120 words in ascii, enough for typical scan requests.
+	responseDataHint = 512
+)
+
+// ErrFrame is returned when a response does not start with a valid header. The connection is
+// out of sync with the PLC and must be closed so the next request starts on a fresh stream.
+var ErrFrame = errors.New("mcp: invalid response frame")
+
+// readFrame3E reads exactly one 3E response frame from r, using the data length field of
+// the header to know where the frame ends. TCP may deliver a frame in several pieces,
+// so nothing of the next frame is consumed and nothing of this frame is left behind.
+func readFrame3E(r io.Reader, code Code) ([]byte, error) {
+	headerLen := responseHeaderLen3E
+	if code == Ascii {
+		headerLen = responseHeaderLen3EAscii
+	}
+
+	// the header is read into the frame itself; most responses fit the capacity it starts with
+	resp := make([]byte, headerLen, headerLen+responseDataHint)
+	if _, err := io.ReadFull(r, resp); err != nil {
+		return nil, err
+	}
+	header := resp
+
+	var dataLen uint64
+	if code == Ascii {
+		if string(header[0:4]) != "D000" {
+			return nil, fmt.Errorf("%w: unexpected 3E sub header %q", ErrFrame, header[0:4])
+		}
+		var err error
+		if dataLen, err = strconv.ParseUint(string(header[14:18]), 16, 16); err != nil {
+			return nil, fmt.Errorf("%w: invalid data length %q", ErrFrame, header[14:18])
+		}
+	} else {
+		if header[0] != 0xD0 || header[1] != 0x00 {
+			return nil, fmt.Errorf("%w: unexpected 3E sub header %X", ErrFrame, header[0:2])
+		}
+		dataLen = uint64(binary.LittleEndian.Uint16(header[7:9]))
+	}
+	if dataLen > maxResponseDataLen {
+		return nil, fmt.Errorf("%w: data length %d too large", ErrFrame, dataLen)
+	}
+
+	resp = append(resp, make([]byte, dataLen)...)
+	if _, err := io.ReadFull(r, resp[headerLen:]); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// readFrameFx reads exactly one 1E response frame from r. 1E responses carry no length,
+// so dataLen is the size of the response data of a normal end in bytes (binary) or characters (ascii);
+// see fxDataLen. An abnormal end has no data, except the abnormal code following end code 5B.
+func readFrameFx(r io.Reader, code Code, dataLen int) ([]byte, error) {
+	// サブヘッダ(1byte) + 終了コード(1byte)。ascii は2文字ずつ
+	unit := 1
+	if code == Ascii {
+		unit = 2
+	}
+
+	header := make([]byte, 2*unit)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	var subHeader, endCode byte
+	if code == Ascii {
+		v, err := strconv.ParseUint(string(header), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid 1E header %q", ErrFrame, header)
+		}
+		subHeader, endCode = byte(v>>8), byte(v)
+	} else {
+		subHeader, endCode = header[0], header[1]
+	}
+	// 応答のサブヘッダは要求のサブヘッダ + 80H
+	if subHeader&0x80 == 0 {
+		return nil, fmt.Errorf("%w: unexpected 1E sub header %02X", ErrFrame, subHeader)
+	}
+
+	switch {
+	case endCode == 0x5B:
+		dataLen = unit
+	case endCode != 0:
+		dataLen = 0
+	}
+
+	resp := make([]byte, len(header)+dataLen)
+	copy(resp, header)
+	if _, err := io.ReadFull(r, resp[len(header):]); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// fxDataLen returns the size of the data of a 1E batch read response of numPoints points
+// in bytes (binary) or characters (ascii). Words are 2 byte or 4 characters, bits are
+// packed 2 points per byte in binary and 1 character per point in ascii.
+func (h *station) fxDataLen(bit bool, numPoints int64) int {
+	switch {
+	case bit && h.code == Ascii:
+		return int(numPoints)
+	case bit:
+		return int((numPoints + 1) / 2)
+	case h.code == Ascii:
+		return int(4 * numPoints)
+	default:
+		return int(2 * numPoints)
+	}
+}
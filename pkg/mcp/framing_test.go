@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadFrame3E(t *testing.T) {
+	frame, _ := hex.DecodeString("d00000ffff030006000000" + "34120200")
+	next, _ := hex.DecodeString("d00000ffff030002000000")
+
+	// the frame arrives one byte at a time and is followed by the next response
+	r := iotest.OneByteReader(bytes.NewReader(append(append([]byte{}, frame...), next...)))
+	resp, err := readFrame3E(r, Binary)
+	if err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if diff := cmp.Diff(resp, frame); diff != "" {
+		t.Errorf("frame differs: (-got +want)\n%s", diff)
+	}
+	resp, err = readFrame3E(r, Binary)
+	if err != nil || !bytes.Equal(resp, next) {
+		t.Errorf("expected next frame intact, got %X (%v)", resp, err)
+	}
+
+	ascii := []byte("D00000FF03FF00000C000012340002")
+	resp, err = readFrame3E(iotest.HalfReader(bytes.NewReader(append(ascii, "D000"...))), Ascii)
+	if err != nil || !bytes.Equal(resp, ascii) {
+		t.Errorf("expected ascii frame, got %q (%v)", resp, err)
+	}
+
+	if _, err := readFrame3E(bytes.NewReader(append([]byte{0x00}, frame...)), Binary); !errors.Is(err, ErrFrame) {
+		t.Errorf("expected frame error for garbage, got %v", err)
+	}
+	if _, err := readFrame3E(bytes.NewReader(frame[:13]), Binary); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF for truncated frame, got %v", err)
+	}
+}
+
+func TestReadFrameFx(t *testing.T) {
+	r := iotest.OneByteReader(bytes.NewReader([]byte{0x81, 0x00, 0x34, 0x12, 0x81, 0x00}))
+	resp, err := readFrameFx(r, Binary, NewLocalStation().fxDataLen(false, 1))
+	if err != nil || !bytes.Equal(resp, []byte{0x81, 0x00, 0x34, 0x12}) {
+		t.Errorf("unexpected word frame %X (%v)", resp, err)
+	}
+
+	// abnormal end: only the abnormal code follows end code 5B
+	resp, err = readFrameFx(bytes.NewReader([]byte{0x81, 0x5B, 0x10, 0xFF}), Binary, 4)
+	if err != nil || !bytes.Equal(resp, []byte{0x81, 0x5B, 0x10}) {
+		t.Errorf("unexpected abnormal frame %X (%v)", resp, err)
+	}
+
+	stn := NewLocalStation().SetCode(Ascii)
+	resp, err = readFrameFx(bytes.NewReader([]byte("8000101")), Ascii, stn.fxDataLen(true, 3))
+	if err != nil || string(resp) != "8000101" {
+		t.Errorf("unexpected ascii bit frame %q (%v)", resp, err)
+	}
+
+	if _, err := readFrameFx(bytes.NewReader([]byte{0x01, 0x00}), Binary, 0); !errors.Is(err, ErrFrame) {
+		t.Errorf("expected frame error for request sub header, got %v", err)
+	}
+}
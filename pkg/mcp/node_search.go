@@ -0,0 +1,235 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	NODE_SEARCH_COMMAND     = "300E" // binary mode expression. if ascii mode then 0E30
+	NODE_SEARCH_SUB_COMMAND = "0000"
+
+	// NodeSearchPort is the UDP port SLMP compatible devices listen on for node search.
+	NodeSearchPort = 45237
+	// NodeSearchBroadcast is the default destination of SearchNodes: every device on the local network.
+	NodeSearchBroadcast = "255.255.255.255:45237"
+)
+
+// Node is a device that answered the SLMP node search (0E30).
+type Node struct {
+	// MAC is the MAC address of the device
+	MAC net.HardwareAddr
+	// IP is the IP address of the device, the PLC_HOST to connect to
+	IP net.IP
+	// SubnetMask and Gateway are the network settings of the device
+	SubnetMask net.IPMask
+	Gateway    net.IP
+	// Hostname is the host name set on the device, often empty
+	Hostname string
+	// VendorCode is the maker of the device (0000 is Mitsubishi Electric)
+	VendorCode uint16
+	// ModelCode identifies the model of the device, see CPUModel.Code for CPUs
+	ModelCode uint32
+	// Version is the equipment version of the device
+	Version uint16
+	// Port is the SLMP port number of the device and Protocol its protocol setting (1 TCP, 2 UDP),
+	// zero when the response ends before them
+	Port     uint16
+	Protocol uint8
+}
+
+func (n Node) String() string {
+	return fmt.Sprintf("%s %s (model %08X)", n.IP, n.MAC, n.ModelCode)
+}
+
+// BuildNodeSearchRequest represents SLMP node search command. Node search is binary only.
+// mac and ip are the address of the sender; devices echo them back and may answer to them.
+// 要求データ: 要求元MACアドレス(6byte) + 要求元IPアドレスサイズ(1byte) + 要求元IPアドレス(4byte)
+func (h *station) BuildNodeSearchRequest(mac net.HardwareAddr, ip net.IP) string {
+	return h.frameString(h.AppendNodeSearchRequest(nil, mac, ip))
+}
+
+// AppendNodeSearchRequest appends the BuildNodeSearchRequest frame to dst in wire format.
+func (h *station) AppendNodeSearchRequest(dst []byte, mac net.HardwareAddr, ip net.IP) []byte {
+	macField := make([]byte, 6)
+	copy(macField, mac)
+	ipField := make([]byte, 4)
+	copy(ipField, ip.To4())
+
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, NODE_SEARCH_COMMAND)
+	dst = h.appendField(dst, NODE_SEARCH_SUB_COMMAND)
+	dst = append(dst, reversed(macField)...)
+	dst = h.appendUint(dst, 4, 1)
+	dst = append(dst, reversed(ipField)...)
+	return h.endFrame(dst, mark)
+}
+
+// reversed returns a copy of b in reverse order. Addresses in node search frames are stored lower byte first.
+func reversed(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
+
+// DoNodeSearch parses a binary node search response.
+// 応答データ: 要求元MACアドレス | 要求元IPアドレス | 応答元MACアドレス | 応答元IPアドレス | サブネットマスク |
+// デフォルトゲートウェイ | ホスト名 | ベンダーコード | 機種コード | 機器バージョン | 対象IPアドレス | 対象ポート番号 |
+// 応答元ステータス | 応答元ポート番号 | 応答元プロトコル
+func (p *parser) DoNodeSearch(resp []byte) (*Node, error) {
+	r, err := p.Do(resp)
+	if err != nil {
+		return nil, err
+	}
+	d := &nodeSearchData{data: r.Payload}
+
+	d.skip(6) // 要求元MACアドレス
+	d.ip()    // 要求元IPアドレス
+	node := &Node{MAC: net.HardwareAddr(d.bytes(6))}
+	node.IP = d.ip()
+	node.SubnetMask = net.IPMask(d.bytes(4))
+	node.Gateway = net.IP(d.bytes(4))
+	node.Hostname = string(reversed(d.bytes(int(d.uint(1)))))
+	node.VendorCode = uint16(d.uint(2))
+	node.ModelCode = uint32(d.uint(4))
+	node.Version = uint16(d.uint(2))
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	// 対象IPアドレス, 対象ポート番号, 応答元ステータス are not kept
+	d.ip()
+	d.skip(2 + 2)
+	port, protocol := uint16(d.uint(2)), uint8(d.uint(1))
+	if d.err == nil {
+		node.Port, node.Protocol = port, protocol
+	}
+	return node, nil
+}
+
+// nodeSearchData reads the fields of a node search response in order. Addresses are stored
+// lower byte first and returned in network order. The first field beyond the data sets err.
+type nodeSearchData struct {
+	data []byte
+	err  error
+}
+
+func (d *nodeSearchData) bytes(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: node search response too short", ErrFrame)
+		}
+		return make([]byte, n)
+	}
+	field := reversed(d.data[:n])
+	d.data = d.data[n:]
+	return field
+}
+
+func (d *nodeSearchData) skip(n int) {
+	d.bytes(n)
+}
+
+func (d *nodeSearchData) uint(n int) uint64 {
+	var v uint64
+	for _, b := range d.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// ip reads an IP address size (1byte) and the address.
+func (d *nodeSearchData) ip() net.IP {
+	return net.IP(d.bytes(int(d.uint(1))))
+}
+
+// SearchNodes sends the SLMP node search request to addr, NodeSearchBroadcast for the local network,
+// and returns the devices that answer within wait. Responses that are not node search responses are ignored.
+// Devices answer over UDP from port NodeSearchPort, so the search needs no connection to any PLC.
+func SearchNodes(ctx context.Context, addr string, wait time.Duration) ([]Node, error) {
+	dst, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := interruptOnDone(ctx, conn)
+	defer stop()
+
+	mac, ip := localEndpoint(dst)
+	if _, err := conn.WriteToUDP(NewLocalStation().AppendNodeSearchRequest(nil, mac, ip), dst); err != nil {
+		return nil, contextOr(ctx, err)
+	}
+
+	var nodes []Node
+	seen := map[string]bool{}
+	buf := make([]byte, maxDatagramLen)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if isTimeout(err) && contextError(ctx) == nil {
+				return nodes, nil // wait is over
+			}
+			return nodes, contextOr(ctx, err)
+		}
+		node, err := NewParser().DoNodeSearch(buf[:n])
+		if err != nil {
+			continue
+		}
+		if key := node.MAC.String() + node.IP.String(); !seen[key] {
+			seen[key] = true
+			nodes = append(nodes, *node)
+		}
+	}
+}
+
+// contextOr returns ctx.Err() when the socket I/O failed because ctx is done, and err otherwise.
+func contextOr(ctx context.Context, err error) error {
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// localEndpoint returns the MAC and IP address this host sends from to reach dst. Both are
+// best effort: a host without a route to dst sends zeros, which devices answer by broadcast.
+func localEndpoint(dst *net.UDPAddr) (net.HardwareAddr, net.IP) {
+	conn, err := net.DialUDP("udp4", nil, dst) // no datagram is sent
+	if err != nil {
+		return nil, net.IPv4zero
+	}
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, ip
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.HardwareAddr, ip
+			}
+		}
+	}
+	return nil, ip
+}
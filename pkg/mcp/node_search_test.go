@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStation_BuildNodeSearchRequest(t *testing.T) {
+	mac, _ := net.ParseMAC("00:26:92:01:02:03")
+	request := NewLocalStation().BuildNodeSearchRequest(mac, net.ParseIP("192.168.3.10"))
+	if want := "500000FFFF0300" + "1100" + "1000" + "300E" + "0000" + "030201922600" + "04" + "0A03A8C0"; request != want {
+		t.Fatalf("expected %v but actual is %v", want, request)
+	}
+}
+
+// nodeSearchResponse is the response of a device with mac and ip to the node search request req.
+func nodeSearchResponse(req []byte, mac net.HardwareAddr, ip net.IP, hostname string) []byte {
+	data := append([]byte{}, req[15:]...) // 要求元MACアドレス + 要求元IPアドレス
+	data = append(data, reversed(mac)...)
+	data = append(data, 4)
+	data = append(data, reversed(ip.To4())...)
+	data = append(data, 0x00, 0xFF, 0xFF, 0xFF) // 255.255.255.0
+	data = append(data, 0x00, 0x00, 0x00, 0x00) // ゲートウェイなし
+	data = append(data, byte(len(hostname)))
+	data = append(data, hostname...)
+	data = append(data, 0x00, 0x00)             // ベンダーコード
+	data = append(data, 0x00, 0x48, 0x00, 0x00) // 機種コード 00004800
+	data = append(data, 0x01, 0x00)             // 機器バージョン
+	data = append(data, 4, 0, 0, 0, 0)          // 対象IPアドレス
+	data = append(data, 0, 0, 0, 0)             // 対象ポート番号 + 応答元ステータス
+	data = append(data, 0x88, 0x13, 0x01)       // 応答元ポート番号 5000 + TCP
+
+	resp := []byte{0xD0, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00, 0, 0, 0x00, 0x00}
+	binary.LittleEndian.PutUint16(resp[7:9], uint16(2+len(data)))
+	return append(resp, data...)
+}
+
+func TestSearchNodes(t *testing.T) {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+
+	mac, _ := net.ParseMAC("00:26:92:01:02:03")
+	go func() {
+		buf := make([]byte, 64)
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(buf[11:15], []byte{0x30, 0x0E, 0x00, 0x00}) {
+			t.Errorf("unexpected node search request % X", buf[:n])
+			return
+		}
+		resp := nodeSearchResponse(buf[:n], mac, net.ParseIP("127.0.0.1"), "")
+		pc.WriteTo([]byte("garbage"), from)
+		pc.WriteTo(resp, from)
+		pc.WriteTo(resp, from) // duplicates are reported once
+	}()
+
+	nodes, err := SearchNodes(context.Background(), pc.LocalAddr().String(), 300*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected search err: %v", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node but found %v", nodes)
+	}
+	n := nodes[0]
+	if n.MAC.String() != mac.String() || !n.IP.Equal(net.ParseIP("127.0.0.1")) || n.SubnetMask.String() != "ffffff00" {
+		t.Fatalf("unexpected node %+v", n)
+	}
+	if n.ModelCode != 0x4800 || n.Version != 1 || n.Port != 5000 || n.Protocol != 1 {
+		t.Fatalf("unexpected node %+v", n)
+	}
+}
+
+func TestParser_DoNodeSearch(t *testing.T) {
+	mac, _ := net.ParseMAC("00:26:92:01:02:03")
+	req := NewLocalStation().AppendNodeSearchRequest(nil, nil, net.IPv4zero)
+	resp := nodeSearchResponse(req, mac, net.ParseIP("192.168.3.39"), "LINE1")
+
+	n, err := NewParser().DoNodeSearch(resp)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if n.Hostname != "LINE1" || n.IP.String() != "192.168.3.39" {
+		t.Fatalf("unexpected node %+v", n)
+	}
+
+	// a response without the trailing port and protocol is still a node
+	n, err = NewParser().DoNodeSearch(resp[:len(resp)-3])
+	if err != nil || n.Port != 0 {
+		t.Fatalf("unexpected node %+v (%v)", n, err)
+	}
+
+	if _, err := NewParser().DoNodeSearch(resp[:20]); err == nil {
+		t.Fatalf("expected error for truncated response")
+	}
+}
@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	REMOTE_UNLOCK_COMMAND  = "3016" // binary mode expression. if ascii mode then 1630
+	REMOTE_LOCK_COMMAND    = "3116" // binary mode expression. if ascii mode then 1631
+	REMOTE_PASSWORD_SUB    = "0000"
+	REMOTE_PASSWORD_LEN_Q  = 4 // MELSEC-Q/L: 4 characters
+	MIN_REMOTE_PASSWORD_IQ = 6 // MELSEC iQ-R: 6 to 32 characters
+	MAX_REMOTE_PASSWORD_IQ = 32
+
+	// lockTimeout bounds the lock sent by Close, so closing never hangs on an unresponsive PLC.
+	lockTimeout = 1 * time.Second
+)
+
+// CheckRemotePassword validates the remote password of a PLC of series: 4 characters on MELSEC-Q/L,
+// 6 to 32 characters on MELSEC iQ-R, ASCII characters only. The error never contains the password.
+func CheckRemotePassword(password string, series Series) error {
+	for _, r := range password {
+		if r < 0x20 || r > 0x7E {
+			return fmt.Errorf("mcp: remote password must be ASCII characters")
+		}
+	}
+	if series == IQRSeries {
+		if len(password) < MIN_REMOTE_PASSWORD_IQ || len(password) > MAX_REMOTE_PASSWORD_IQ {
+			return fmt.Errorf("mcp: remote password must be %d to %d characters for %v, got %d",
+				MIN_REMOTE_PASSWORD_IQ, MAX_REMOTE_PASSWORD_IQ, series, len(password))
+		}
+		return nil
+	}
+	if len(password) != REMOTE_PASSWORD_LEN_Q {
+		return fmt.Errorf("mcp: remote password must be %d characters for %v, got %d", REMOTE_PASSWORD_LEN_Q, series, len(password))
+	}
+	return nil
+}
+
+// SetRemotePassword sets the remote password of a protected Ethernet port. Clients send the unlock
+// command with it on every new connection before any other request. Empty sends no unlock.
+// Set it before creating the client; 1E frames have no remote password.
+func (h *station) SetRemotePassword(password string) *station {
+	h.password = password
+	return h
+}
+
+// BuildUnlockRequest represents MCP remote password unlock command.
+// 要求データ: パスワード文字数(2byte) + パスワード(ASCII)
+func (h *station) BuildUnlockRequest() string {
+	return h.frameString(h.AppendUnlockRequest(nil))
+}
+
+// AppendUnlockRequest appends the BuildUnlockRequest frame to dst in wire format.
+func (h *station) AppendUnlockRequest(dst []byte) []byte {
+	return h.appendPasswordRequest(dst, REMOTE_UNLOCK_COMMAND)
+}
+
+// BuildLockRequest represents MCP remote password lock command.
+func (h *station) BuildLockRequest() string {
+	return h.frameString(h.AppendLockRequest(nil))
+}
+
+// AppendLockRequest appends the BuildLockRequest frame to dst in wire format.
+func (h *station) AppendLockRequest(dst []byte) []byte {
+	return h.appendPasswordRequest(dst, REMOTE_LOCK_COMMAND)
+}
+
+func (h *station) appendPasswordRequest(dst []byte, command string) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, command)
+	dst = h.appendField(dst, REMOTE_PASSWORD_SUB)
+	dst = h.appendUint(dst, uint64(len(h.password)), 2)
+	dst = append(dst, h.password...) // ASCII in both codes
+	return h.endFrame(dst, mark)
+}
+
+// unlock sends the remote password unlock on the connection just dialed, before the request
+// that dialed it. c.lock must be held and the connection deadline set.
+func (c *client3E) unlock() error {
+	if c.stn.password == "" {
+		return nil
+	}
+	// built apart from c.request, which holds the request that dialed
+	if _, err := c.conn.Write(c.stn.AppendUnlockRequest(nil)); err != nil {
+		return err
+	}
+	resp, err := c.readResponse(c.conn)
+	if err != nil {
+		return err
+	}
+	if _, err := NewParser().Do(resp); err != nil {
+		return fmt.Errorf("mcp: remote password unlock: %w", err)
+	}
+	return nil
+}
+
+// lockPassword sends the remote password lock before Close closes the connection. TCP ports lock again
+// when the connection closes; UDP ports stay unlocked until the lock. Failures are ignored:
+// the connection is closed anyway. c.lock must be held.
+func (c *client3E) lockPassword() {
+	if c.stn.password == "" || c.conn == nil {
+		return
+	}
+	if c.conn.SetDeadline(time.Now().Add(lockTimeout)) != nil {
+		return
+	}
+	if _, err := c.conn.Write(c.stn.AppendLockRequest(nil)); err == nil {
+		_, _ = c.readResponse(c.conn)
+	}
+}
+
+// unlock sends the remote password unlock on conn before readLoop starts and before any
+// other request, so no response can be taken for the unlock response. It runs while dialing,
+// without c.mu held.
+func (c *client4E) unlock(ctx context.Context, conn net.Conn) error {
+	if c.stn.password == "" {
+		return nil
+	}
+	c.mu.Lock()
+	serial := c.nextSerial()
+	c.mu.Unlock()
+	payload := c.frame4E(nil, serial, c.stn.AppendUnlockRequest)
+
+	deadline := time.Now().Add(responseTimeout)
+	if d := requestDeadline(ctx); d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := interruptOnDone(ctx, conn)
+	defer stop()
+	if _, err := conn.Write(payload); err != nil {
+		return err
+	}
+
+	headerLen := responseHeaderLen4E
+	if c.stn.code == Ascii {
+		headerLen = responseHeaderLen4EAscii
+	}
+	resp := make([]byte, headerLen)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	got, dataLen, err := c.parseHeader(resp)
+	if err != nil {
+		return err
+	}
+	resp = append(resp, make([]byte, dataLen)...)
+	if _, err := io.ReadFull(conn, resp[headerLen:]); err != nil {
+		return err
+	}
+	if got != serial {
+		return fmt.Errorf("%w: unlock response for serial %d, want %d", ErrFrame, got, serial)
+	}
+	if _, err := NewParser().Do(resp); err != nil {
+		return fmt.Errorf("mcp: remote password unlock: %w", err)
+	}
+	// readLoop waits for responses without deadline
+	stop()
+	return conn.SetDeadline(time.Time{})
+}
+
+// lockPassword sends the remote password lock before Close closes the connection, like
+// client3E.lockPassword. Failures are ignored: the connection is closed anyway.
+func (c *client4E) lockPassword() {
+	c.mu.Lock()
+	open := c.conn != nil && !c.closed
+	c.mu.Unlock()
+	if c.stn.password == "" || !open {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	_, _ = c.exchange(ctx, c.stn.AppendLockRequest)
+}
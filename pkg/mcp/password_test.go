@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/mcsim"
+)
+
+func TestStation_BuildUnlockRequest(t *testing.T) {
+	stn := NewLocalStation().SetRemotePassword("1234")
+	if unlock := stn.BuildUnlockRequest(); unlock != "500000FFFF03000C0010003016"+"0000"+"0400"+"31323334" {
+		t.Fatalf("unexpected unlock request %v", unlock)
+	}
+	if lock := stn.BuildLockRequest(); lock != "500000FFFF03000C0010003116"+"0000"+"0400"+"31323334" {
+		t.Fatalf("unexpected lock request %v", lock)
+	}
+
+	ascii := NewLocalStation().SetCode(Ascii).SetRemotePassword("1234").BuildUnlockRequest()
+	if ascii != "500000FF03FF00001400101630"+"0000"+"0004"+"1234" {
+		t.Fatalf("unexpected ascii unlock request %v", ascii)
+	}
+}
+
+func TestCheckRemotePassword(t *testing.T) {
+	if err := CheckRemotePassword("ab12", QSeries); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := CheckRemotePassword("abc123", IQRSeries); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := CheckRemotePassword("abc123", QSeries); err == nil || strings.Contains(err.Error(), "abc123") {
+		t.Fatalf("expected length error without the password, got %v", err)
+	}
+	if err := CheckRemotePassword("ab1", IQRSeries); err == nil {
+		t.Fatalf("expected length error for iQ-R")
+	}
+	if err := CheckRemotePassword("ab\n1", QSeries); err == nil {
+		t.Fatalf("expected error for control character")
+	}
+}
+
+// servePassword answers 3E binary requests on every connection it accepts. It records the command
+// of each request, refuses every command before a successful unlock with password, and closes the
+// connection after each loopback test like a PLC that drops the connection.
+func servePassword(ln net.Listener, password string, mu *sync.Mutex, commands *[]string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			unlocked := false
+			for {
+				header := make([]byte, 9)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				body := make([]byte, binary.LittleEndian.Uint16(header[7:9]))
+				if _, err := io.ReadFull(conn, body); err != nil {
+					return
+				}
+				command := fmt.Sprintf("%04X", binary.LittleEndian.Uint16(body[2:4]))
+				mu.Lock()
+				*commands = append(*commands, command)
+				mu.Unlock()
+
+				endCode, data := uint16(0), []byte(nil)
+				switch {
+				case command == "1630":
+					if string(body[8:]) == password {
+						unlocked = true
+					} else {
+						endCode = 0xC200 // リモートパスワード不一致
+					}
+				case !unlocked:
+					endCode = 0xC201 // ロック状態
+				case command == "0619":
+					data = body[6:]
+				}
+				resp := []byte{0xD0, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00, 0, 0, byte(endCode), byte(endCode >> 8)}
+				binary.LittleEndian.PutUint16(resp[7:9], uint16(2+len(data)))
+				conn.Write(append(resp, data...))
+				if command == "0619" {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestClient3E_RemotePassword(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	var mu sync.Mutex
+	var commands []string
+	go servePassword(ln, "ab12", &mu, &commands)
+	addr := ln.Addr().(*net.TCPAddr)
+
+	client, err := New3EClient(addr.IP.String(), addr.Port, NewLocalStation().SetRemotePassword("ab12"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	if _, err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected ping err: %v", err)
+	}
+	// the server closed the connection after the ping: the next request finds it lost
+	// and the one after it dials again and unlocks before the ping
+	if _, err := client.Ping(context.Background()); err == nil {
+		t.Fatalf("expected error on the closed connection")
+	}
+	if _, err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected ping err after reconnect: %v", err)
+	}
+	client.Close()
+
+	mu.Lock()
+	if got := strings.Join(commands, " "); got != "1630 0619 1630 0619" {
+		t.Errorf("commands = %v, want unlock before every ping", got)
+	}
+	commands = nil
+	mu.Unlock()
+
+	wrong, err := New3EClient(addr.IP.String(), addr.Port, NewLocalStation().SetRemotePassword("zz99"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer wrong.Close()
+	_, err = wrong.Ping(context.Background())
+	var endCodeErr *EndCodeError
+	if !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0xC200 {
+		t.Fatalf("expected end code C200 but actual is %v", err)
+	}
+	if strings.Contains(err.Error(), "zz99") {
+		t.Fatalf("error reveals the password: %v", err)
+	}
+
+	// a refused password is a failed dial: the next request backs off instead of sending it again
+	if _, err := wrong.Ping(context.Background()); !errors.Is(err, ErrReconnectBackoff) {
+		t.Fatalf("expected reconnect backoff but actual is %v", err)
+	}
+	if status := wrong.Status(); status.Failures != 1 || !status.RetryAt.After(time.Now()) {
+		t.Fatalf("expected 1 failure and a retry later, got %+v", status)
+	}
+	mu.Lock()
+	if got := strings.Join(commands, " "); got != "1630" {
+		t.Errorf("commands = %v, want a single unlock", got)
+	}
+	mu.Unlock()
+}
+
+func TestClient4E_RemotePassword(t *testing.T) {
+	var logged strings.Builder
+	var logMu sync.Mutex
+	sim := mcsim.New(mcsim.Options{Password: "ab12", Logger: log.New(lockedWriter{&logMu, &logged}, "", 0)})
+	defer sim.Close()
+	addr, err := sim.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host, port := addr.(*net.TCPAddr).IP.String(), addr.(*net.TCPAddr).Port
+
+	wrong, err := New4EClient(host, port, NewLocalStation().SetRemotePassword("zz99"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer wrong.Close()
+	var endCodeErr *EndCodeError
+	if _, err := wrong.Ping(context.Background()); !errors.As(err, &endCodeErr) || endCodeErr.EndCode != mcsim.EndCodePassword {
+		t.Fatalf("expected end code C200 but actual is %v", err)
+	}
+	if _, err := wrong.Ping(context.Background()); !errors.Is(err, ErrReconnectBackoff) {
+		t.Fatalf("expected reconnect backoff but actual is %v", err)
+	}
+
+	client, err := New4EClient(host, port, NewLocalStation().SetRemotePassword("ab12"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	if _, err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected ping err: %v", err)
+	}
+	// Close locks the port again like the 3E client
+	client.Close()
+	logMu.Lock()
+	defer logMu.Unlock()
+	if got := strings.Count(logged.String(), "1631/"); got != 1 {
+		t.Fatalf("expected one remote password lock on close, got %d in\n%s", got, logged.String())
+	}
+}
+
+// lockedWriter serializes writes to w, for a logger used by several goroutines.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func TestNew1EClient_RemotePassword(t *testing.T) {
+	if _, err := New1EClient("127.0.0.1", 5000, NewLocalStation().SetRemotePassword("ab12")); !errors.Is(err, ErrUnsupported1E) {
+		t.Fatalf("expected ErrUnsupported1E but actual is %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RemoteOp is a remote operation of the CPU module (command 1001-1006).
+type RemoteOp int
+
+const (
+	// RemoteRun switches the CPU to RUN (1001). It is not forced and keeps device memory.
+	RemoteRun RemoteOp = iota + 1
+	// RemoteStop switches the CPU to STOP (1002).
+	RemoteStop
+	// RemotePause switches the CPU to PAUSE (1003). It is not forced.
+	RemotePause
+	// RemoteLatchClear clears latched devices (1005). The CPU must be in STOP.
+	RemoteLatchClear
+	// RemoteReset resets the CPU (1006). The CPU must be in STOP and may reset before it responds.
+	RemoteReset
+)
+
+// ParseRemoteOp converts "run", "stop", "pause", "latch-clear" or "reset" into a RemoteOp.
+func ParseRemoteOp(s string) (RemoteOp, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "run":
+		return RemoteRun, nil
+	case "stop":
+		return RemoteStop, nil
+	case "pause":
+		return RemotePause, nil
+	case "latch-clear", "latchclear", "latch_clear":
+		return RemoteLatchClear, nil
+	case "reset":
+		return RemoteReset, nil
+	default:
+		return 0, fmt.Errorf("mcp: unknown remote operation %q", s)
+	}
+}
+
+func (op RemoteOp) String() string {
+	switch op {
+	case RemoteRun:
+		return "run"
+	case RemoteStop:
+		return "stop"
+	case RemotePause:
+		return "pause"
+	case RemoteLatchClear:
+		return "latch-clear"
+	case RemoteReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("RemoteOp(%d)", int(op))
+	}
+}
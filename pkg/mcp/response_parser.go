@@ -1,8 +1,12 @@
 package mcp
 
 import (
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type parser struct {
@@ -16,6 +20,8 @@ func NewParser() *parser {
 type Response struct {
 	// Sub header
 	SubHeader string
+	// Serial number (4E frame only)
+	SerialNum string
 	// network number
 	NetworkNum string
 	// PC number
@@ -30,51 +36,434 @@ type Response struct {
 	EndCode string
 	// Response data
 	Payload []byte
-	// error data
+	// error data: error information of a non-zero end code in binary layout
+	// (network, pc, unit i/o and unit station number, command, sub command). See ErrorInfo.
 	ErrInfo []byte
 }
 
+// Do parses a 3E or 4E response. Binary and ascii responses are told apart by the
+// first byte of the sub header (0xD0/0xD4 vs 'D').
+// Payload of an ascii response is converted to the binary layout (2 byte per word, lower byte first)
+// so callers decode both codes the same way.
+// A non-zero end code is returned as *EndCodeError together with the Response holding ErrInfo.
 func (p *parser) Do(resp []byte) (*Response, error) {
+	if len(resp) >= 1 && resp[0] == 'D' {
+		return p.doAscii(resp)
+	}
+	if len(resp) >= 2 && resp[0] == 0xD4 && resp[1] == 0x00 {
+		return p.do4E(resp)
+	}
 	if len(resp) < 11 {
 		return nil, errors.New("length must be larger than 22 byte")
 	}
 
-	subHeaderB := resp[0:2]
-	networkNumB := resp[2:3]
-	pcNumB := resp[3:4]
-	unitIONumB := resp[4:6]
-	unitStationNumB := resp[6:7]
-	dataLenB := resp[7:9]
-	endCodeB := resp[9:11]
-	payloadB := resp[11:]
+	// one string holds every header field
+	header := upperHex(resp[0:11])
+	r := &Response{
+		SubHeader:      header[0:4],
+		NetworkNum:     header[4:6],
+		PCNum:          header[6:8],
+		UnitIONum:      header[8:12],
+		UnitStationNum: header[12:14],
+		DataLen:        header[14:18],
+		EndCode:        header[18:22],
+		Payload:        resp[11:],
+	}
+	return r, r.binaryEndCode(binary.LittleEndian.Uint16(resp[9:11]))
+}
 
-	return &Response{
-		SubHeader:      fmt.Sprintf("%X", subHeaderB),
-		NetworkNum:     fmt.Sprintf("%X", networkNumB),
-		PCNum:          fmt.Sprintf("%X", pcNumB),
-		UnitIONum:      fmt.Sprintf("%X", unitIONumB),
-		UnitStationNum: fmt.Sprintf("%X", unitStationNumB),
-		DataLen:        fmt.Sprintf("%X", dataLenB),
-		EndCode:        fmt.Sprintf("%X", endCodeB),
-		Payload:        payloadB,
+// DoPayload parses a 3E or 4E response like Do and returns only its payload, without
+// rendering the header as strings: the payload of a binary response is a part of resp.
+// Scan loops that decode nothing but the data use it instead of Do.
+func (p *parser) DoPayload(resp []byte) ([]byte, error) {
+	header := 0
+	switch {
+	case len(resp) >= 2 && resp[0] == 0xD0 && resp[1] == 0x00:
+		header = 11
+	case len(resp) >= 2 && resp[0] == 0xD4 && resp[1] == 0x00:
+		header = 15
+	case len(resp) >= 4 && string(resp[0:4]) == "D000":
+		header = 22
+	case len(resp) >= 4 && string(resp[0:4]) == SUB_HEADER_4E_RESP:
+		header = 30
+	}
+
+	if header == 0 || len(resp) < header {
+		// Do reports what is wrong with the frame
+		return p.payload(resp)
+	}
+	if resp[0] != 'D' {
+		if resp[header-2] != 0 || resp[header-1] != 0 {
+			return p.payload(resp)
+		}
+		return resp[header:], nil
+	}
+	if string(resp[header-4:header]) != "0000" {
+		return p.payload(resp)
+	}
+	return asciiWords(resp[header:])
+}
+
+// payload is the Payload of Do, for responses DoPayload leaves to Do.
+func (p *parser) payload(resp []byte) ([]byte, error) {
+	r, err := p.Do(resp)
+	if err != nil {
+		return nil, err
+	}
+	return r.Payload, nil
+}
+
+// binaryEndCode moves the error information of a non-zero binary end code from Payload
+// to ErrInfo and returns the EndCodeError. It returns nil for a normal end.
+func (r *Response) binaryEndCode(endCode uint16) error {
+	if endCode == 0 {
+		return nil
+	}
+	if len(r.Payload) >= errorInfoSize {
+		r.ErrInfo = r.Payload[:errorInfoSize]
+	}
+	r.Payload = nil
+	return &EndCodeError{EndCode: endCode, Info: parseErrorInfo(r.ErrInfo)}
+}
+
+// DoBits parses a batch read response in bit units and returns the state of numPoints devices.
+// Binary data holds 2 points per byte (upper nibble first), ascii data 1 character per point.
+func (p *parser) DoBits(resp []byte, numPoints int) ([]bool, error) {
+	payload, err := p.DoPayload(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp[0] == 'D' {
+		// DoPayload converted the data as words; convert the raw characters as bits instead
+		header := 22
+		if string(resp[0:4]) == SUB_HEADER_4E_RESP {
+			header = 30
+		}
+		if payload, err = asciiBits(resp[header:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return unpackBits(payload, numPoints)
+}
+
+// unpackBits returns numPoints states from nibble packed data (2 points per byte, upper nibble first).
+func unpackBits(data []byte, numPoints int) ([]bool, error) {
+	if len(data) < (numPoints+1)/2 {
+		return nil, fmt.Errorf("bit response too short: got %d bytes for %d points", len(data), numPoints)
+	}
+	bits := make([]bool, numPoints)
+	for i := range bits {
+		if i%2 == 0 {
+			bits[i] = data[i/2]>>4 != 0
+		} else {
+			bits[i] = data[i/2]&0x0F != 0
+		}
+	}
+	return bits, nil
+}
+
+// DoRandom parses a random read response holding wordPoints words followed by dwordPoints double words.
+// Payload uses the binary layout for both codes: 2 byte per word and 4 byte per double word, lower byte first.
+func (p *parser) DoRandom(resp []byte, wordPoints, dwordPoints int) (*Response, error) {
+	r, err := p.Do(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 || resp[0] != 'D' {
+		return r, nil
+	}
+
+	// ascii double words are sent upper word first, Do only swapped bytes inside each word
+	for i := 0; i < dwordPoints; i++ {
+		off := 2*wordPoints + 4*i
+		if off+4 > len(r.Payload) {
+			break
+		}
+		dword := r.Payload[off : off+4]
+		dword[0], dword[1], dword[2], dword[3] = dword[2], dword[3], dword[0], dword[1]
+	}
+	return r, nil
+}
+
+// DoBlocks parses a multiple block batch read response and splits its payload per block:
+// every word block, then every bit block, in request order (2 byte per point, lower byte first).
+func (p *parser) DoBlocks(resp []byte, wordBlocks, bitBlocks []Block) (*Response, [][]byte, error) {
+	r, err := p.Do(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	need := 2 * blockPoints(wordBlocks, bitBlocks)
+	if int64(len(r.Payload)) < need {
+		return r, nil, fmt.Errorf("block response too short: got %d bytes, want %d", len(r.Payload), need)
+	}
+
+	data := make([][]byte, 0, len(wordBlocks)+len(bitBlocks))
+	var off int64
+	for _, b := range append(append([]Block{}, wordBlocks...), bitBlocks...) {
+		data = append(data, r.Payload[off:off+2*b.Points])
+		off += 2 * b.Points
+	}
+	return r, data, nil
+}
+
+// DoHealthCheck parses a loopback test response and checks that the PLC echoed "ABCDE".
+func (p *parser) DoHealthCheck(resp []byte) error {
+	payload, err := p.DoPayload(resp)
+	if err != nil {
+		return err
+	}
+
+	// binary: data count(2byte, lower byte first) + data. ascii: data count(4char) + data, left as is by DoPayload
+	expected := []byte{0x05, 0x00, 'A', 'B', 'C', 'D', 'E'}
+	if resp[0] == 'D' {
+		expected = []byte("0005ABCDE")
+	}
+	if string(payload) != string(expected) {
+		return fmt.Errorf("loopback echoed %q, want %q", payload, expected)
+	}
+	return nil
+}
+
+// DoCPUModel parses a CPU model name read response.
+// Model name is 16 characters in both codes; model code is 2 byte (lower byte first) in binary
+// and 4 hex characters in ascii.
+func (p *parser) DoCPUModel(resp []byte) (*CPUModel, error) {
+	if len(resp) > 0 && resp[0] == 'D' {
+		// ascii model name is not hex data, so read it from the raw response instead of Do
+		header := 22
+		if len(resp) >= 4 && string(resp[0:4]) == SUB_HEADER_4E_RESP {
+			header = 30
+		}
+		if len(resp) < header {
+			return nil, fmt.Errorf("length must be larger than %d characters", header)
+		}
+		if string(resp[header-4:header]) != "0000" {
+			// Do decodes the end code and error information
+			_, err := p.Do(resp)
+			if err == nil {
+				err = fmt.Errorf("invalid end code %q", resp[header-4:header])
+			}
+			return nil, err
+		}
+		data := resp[header:]
+		if len(data) < 20 {
+			return nil, fmt.Errorf("cpu model response too short: got %d characters, want 20", len(data))
+		}
+		code, err := strconv.ParseUint(string(data[16:20]), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu model code %q", data[16:20])
+		}
+		return &CPUModel{Name: strings.TrimRight(string(data[0:16]), " \x00"), Code: uint16(code)}, nil
+	}
+
+	r, err := p.Do(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Payload) < 18 {
+		return nil, fmt.Errorf("cpu model response too short: got %d bytes, want 18", len(r.Payload))
+	}
+	return &CPUModel{
+		Name: strings.TrimRight(string(r.Payload[0:16]), " \x00"),
+		Code: binary.LittleEndian.Uint16(r.Payload[16:18]),
 	}, nil
 }
 
+// 4Eフレーム:応答伝文 Binary
+// サブヘッダ | シリアル番号 | 固定値 | 3Eフレームと同じ応答(サブヘッダを除く)
+func (p *parser) do4E(resp []byte) (*Response, error) {
+	if len(resp) < 15 {
+		return nil, errors.New("length must be larger than 15 byte")
+	}
+
+	header := upperHex(resp[0:15])
+	r := &Response{
+		SubHeader:      header[0:4],
+		SerialNum:      header[4:8],
+		NetworkNum:     header[12:14],
+		PCNum:          header[14:16],
+		UnitIONum:      header[16:20],
+		UnitStationNum: header[20:22],
+		DataLen:        header[22:26],
+		EndCode:        header[26:30],
+		Payload:        resp[15:],
+	}
+	return r, r.binaryEndCode(binary.LittleEndian.Uint16(resp[13:15]))
+}
+
+// 3E/4Eフレーム:応答伝文 ASCII
+// 各項目はバイナリの2倍の文字数で、上位バイトから順に格納される
+func (p *parser) doAscii(resp []byte) (*Response, error) {
+	header := 22 // D000 + network(2) + pc(2) + unit i/o(4) + unit station(2) + data length(4) + end code(4)
+	serial := 0  // 4E: serial number and fixed 0000 follow the sub header, the rest lines up with 3E
+	if len(resp) >= 4 && string(resp[0:4]) == SUB_HEADER_4E_RESP {
+		if len(resp) < 12 {
+			return nil, errors.New("length must be larger than 30 characters")
+		}
+		serial = 8
+	}
+	if len(resp) < header+serial {
+		return nil, errors.New("length must be larger than 22 characters")
+	}
+
+	// one string holds every header field
+	head := string(resp[0 : header+serial])
+	r := &Response{
+		SubHeader:      head[0:4],
+		SerialNum:      head[4 : 4+serial/2],
+		NetworkNum:     head[serial+4 : serial+6],
+		PCNum:          head[serial+6 : serial+8],
+		UnitIONum:      head[serial+8 : serial+12],
+		UnitStationNum: head[serial+12 : serial+14],
+		DataLen:        head[serial+14 : serial+18],
+		EndCode:        head[serial+18 : serial+22],
+	}
+	resp = resp[serial:]
+
+	endCode, err := strconv.ParseUint(r.EndCode, 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end code %q", r.EndCode)
+	}
+	if endCode != 0 {
+		// エラー情報: ネットワーク番号 | PC番号 | 要求先ユニットI/O番号 | 要求先ユニット局番号 | コマンド | サブコマンド
+		r.ErrInfo = asciiErrorInfo(resp[header:])
+		return r, &EndCodeError{EndCode: uint16(endCode), Info: parseErrorInfo(r.ErrInfo)}
+	}
+
+	if r.Payload, err = asciiWords(resp[header:]); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// asciiWords converts ascii word data (4 characters per word, upper byte first)
+// into binary layout (2 byte per word, lower byte first).
+// Data that is not whole words is returned unchanged.
+func asciiWords(data []byte) ([]byte, error) {
+	if len(data)%4 != 0 {
+		return data, nil
+	}
+	words := make([]byte, len(data)/2)
+	if _, err := hex.Decode(words, data); err != nil {
+		return nil, fmt.Errorf("invalid ascii response data %q: %w", data, err)
+	}
+	for i := 0; i+1 < len(words); i += 2 {
+		words[i], words[i+1] = words[i+1], words[i]
+	}
+	return words, nil
+}
+
+// asciiBits converts ascii bit data (1 character per point) into binary layout
+// (2 points per byte, upper nibble first).
+func asciiBits(data []byte) ([]byte, error) {
+	s := string(data)
+	if len(s)%2 != 0 {
+		s += "0"
+	}
+	bits, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ascii response data %q: %w", data, err)
+	}
+	return bits, nil
+}
+
 // 1Eフレーム:応答伝文 Binary
 // ヘッダ | サブヘッダ | 終了コード |  応答データ
 // MELSECコミュニケーションプロトコルリファレンスマニュアル(p384)
 func (p *parser) DoFx(resp []byte) (*Response, error) {
-	if len(resp) < 3 {
-		return nil, errors.New("length must be larger than 22 byte")
+	if len(resp) >= 1 && (resp[0] == '8' || resp[0] == '9') {
+		return p.doFxAscii(resp)
+	}
+	// 書込み、テスト、リモート操作の応答はサブヘッダと終了コードだけ
+	if len(resp) < 2 {
+		return nil, errors.New("length must be larger than 2 byte")
 	}
 
-	subHeaderB := resp[0:1]
-	endCodeB := resp[1:2]
-	payloadB := resp[2:]
+	header := upperHex(resp[0:2])
+	r := &Response{
+		SubHeader: header[0:2],
+		EndCode:   header[2:4],
+		Payload:   resp[2:],
+	}
+	if resp[1] != 0 {
+		// 終了コード5Bの後には異常コードが続く
+		r.ErrInfo, r.Payload = r.Payload, nil
+		return r, fxEndCodeError(resp[1], r.ErrInfo)
+	}
+	return r, nil
+}
+
+// DoBitsFx parses a 1E batch read response in bit units and returns the state of numPoints devices.
+// DoFx converts ascii data (1 character per point) into the binary layout (2 points per byte, upper nibble first).
+func (p *parser) DoBitsFx(resp []byte, numPoints int) ([]bool, error) {
+	r, err := p.DoFx(resp)
+	if err != nil {
+		return nil, err
+	}
+	return unpackBits(r.Payload, numPoints)
+}
+
+// DoHealthCheckFx parses a 1E loopback test response and checks that the PLC echoed "ABCDE".
+func (p *parser) DoHealthCheckFx(resp []byte) error {
+	r, err := p.DoFx(resp)
+	if err != nil {
+		return err
+	}
+
+	// binary: data count(1byte) + data. ascii: data count(2char) + data, left as is by DoFx
+	expected := []byte{0x05, 'A', 'B', 'C', 'D', 'E'}
+	if resp[0] == '9' {
+		expected = []byte("05ABCDE")
+	}
+	if string(r.Payload) != string(expected) {
+		return fmt.Errorf("loopback echoed %q, want %q", r.Payload, expected)
+	}
+	return nil
+}
+
+// fxEndCodeError returns the EndCodeError of a non-zero 1E end code. errInfo is the abnormal code, if any.
+func fxEndCodeError(endCode byte, errInfo []byte) error {
+	e := &EndCodeError{EndCode: uint16(endCode), Fx: true}
+	if endCode == 0x5B && len(errInfo) > 0 {
+		e.AbnormalCode = errInfo[0]
+	}
+	return e
+}
+
+// 1Eフレーム:応答伝文 ASCII
+// サブヘッダ(2文字) | 終了コード(2文字) | 応答データ
+// ビット単位(サブヘッダ80)は1点1文字、ワード単位(81)は1点4文字。ループバック(96)などはそのまま
+func (p *parser) doFxAscii(resp []byte) (*Response, error) {
+	if len(resp) < 4 {
+		return nil, errors.New("length must be larger than 4 characters")
+	}
+
+	endCode, err := hex.DecodeString(string(resp[2:4]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid end code %q", resp[2:4])
+	}
+	if endCode[0] != 0 {
+		errInfo, _ := hex.DecodeString(string(resp[4:min(len(resp), 6)]))
+		return &Response{SubHeader: string(resp[0:2]), EndCode: string(resp[2:4]), ErrInfo: errInfo}, fxEndCodeError(endCode[0], errInfo)
+	}
+
+	payload := resp[4:]
+	switch string(resp[0:2]) {
+	case "80":
+		payload, err = asciiBits(payload)
+	case "81":
+		payload, err = asciiWords(payload)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	return &Response{
-		SubHeader: fmt.Sprintf("%X", subHeaderB),
-		EndCode:   fmt.Sprintf("%X", endCodeB),
-		Payload:   payloadB,
+		SubHeader: string(resp[0:2]),
+		EndCode:   string(resp[2:4]),
+		Payload:   payload,
 	}, nil
 }
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"encoding/hex"
+	"errors"
 	"github.com/google/go-cmp/cmp"
 	"testing"
 )
@@ -31,3 +32,297 @@ func TestParser_Do(t *testing.T) {
 		t.Errorf("parse Resp differs: (-got +want)\n%s", diff)
 	}
 }
+
+func TestParser_Do4E(t *testing.T) {
+	mcResp, _ := hex.DecodeString("d40034120000" + "00ffff0300" + "0400" + "0000" + "2a00")
+
+	p := NewParser()
+	response, err := p.Do(mcResp)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+
+	expected := &Response{
+		SubHeader:      "D400",
+		SerialNum:      "3412",
+		NetworkNum:     "00",
+		PCNum:          "FF",
+		UnitIONum:      "FF03",
+		UnitStationNum: "00",
+		DataLen:        "0400",
+		EndCode:        "0000",
+		Payload:        []uint8{0x2a, 0x00},
+	}
+
+	if diff := cmp.Diff(response, expected); diff != "" {
+		t.Errorf("parse Resp differs: (-got +want)\n%s", diff)
+	}
+}
+
+func TestParser_DoAscii(t *testing.T) {
+	p := NewParser()
+	response, err := p.Do([]byte("D00000FF03FF00000C000012340002"))
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+
+	expected := &Response{
+		SubHeader:      "D000",
+		NetworkNum:     "00",
+		PCNum:          "FF",
+		UnitIONum:      "03FF",
+		UnitStationNum: "00",
+		DataLen:        "000C",
+		EndCode:        "0000",
+		Payload:        []uint8{0x34, 0x12, 0x02, 0x00},
+	}
+
+	if diff := cmp.Diff(response, expected); diff != "" {
+		t.Errorf("parse Resp differs: (-got +want)\n%s", diff)
+	}
+}
+
+func TestParser_DoFxAscii(t *testing.T) {
+	p := NewParser()
+
+	words, err := p.DoFx([]byte("81001234"))
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(words.Payload, []byte{0x34, 0x12}); diff != "" {
+		t.Errorf("word payload differs: (-got +want)\n%s", diff)
+	}
+
+	bits, err := p.DoFx([]byte("8000101"))
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(bits.Payload, []byte{0x10, 0x10}); diff != "" {
+		t.Errorf("bit payload differs: (-got +want)\n%s", diff)
+	}
+}
+
+func TestParser_DoHealthCheckFx(t *testing.T) {
+	p := NewParser()
+	if err := p.DoHealthCheckFx([]byte{0x96, 0x00, 0x05, 'A', 'B', 'C', 'D', 'E'}); err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if err := p.DoHealthCheckFx([]byte("960005ABCDE")); err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if err := p.DoHealthCheckFx([]byte{0x96, 0x00, 0x05, 'A', 'B', 'C', 'D', 'X'}); err == nil {
+		t.Fatalf("expected an error for a wrong echo")
+	}
+
+	// a write response has no data
+	if r, err := p.DoFx([]byte{0x83, 0x00}); err != nil || len(r.Payload) != 0 {
+		t.Fatalf("unexpected write response %v: %v", r, err)
+	}
+}
+
+func TestParser_DoRandomAscii(t *testing.T) {
+	p := NewParser()
+	// one word 0x1234 and one double word 0x12345678
+	response, err := p.DoRandom([]byte("D00000FF03FF000010000012341234"+"5678"), 1, 1)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(response.Payload, []byte{0x34, 0x12, 0x78, 0x56, 0x34, 0x12}); diff != "" {
+		t.Errorf("payload differs: (-got +want)\n%s", diff)
+	}
+}
+
+func TestParser_DoBlocks(t *testing.T) {
+	mcResp, _ := hex.DecodeString("d00000ffff03000800000001000200" + "0500")
+	wordBlocks := []Block{{Device: Device{Name: "D", Offset: 0}, Points: 2}}
+	bitBlocks := []Block{{Device: Device{Name: "M", Offset: 0}, Points: 1}}
+
+	_, data, err := NewParser().DoBlocks(mcResp, wordBlocks, bitBlocks)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(data, [][]byte{{0x01, 0x00, 0x02, 0x00}, {0x05, 0x00}}); diff != "" {
+		t.Errorf("block data differs: (-got +want)\n%s", diff)
+	}
+
+	if _, _, err := NewParser().DoBlocks(mcResp, append(wordBlocks, wordBlocks...), bitBlocks); err == nil {
+		t.Errorf("expected error for a response shorter than the blocks")
+	}
+}
+
+func TestParser_DoBits(t *testing.T) {
+	mcResp, _ := hex.DecodeString("d00000ffff030004000000" + "1001")
+	bits, err := NewParser().DoBits(mcResp, 3)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(bits, []bool{true, false, false}); diff != "" {
+		t.Errorf("bits differ: (-got +want)\n%s", diff)
+	}
+
+	// ascii: 1 character per point, 4 points would also look like one word
+	bitsAscii, err := NewParser().DoBits([]byte("D00000FF03FF0000080000"+"0101"), 4)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(bitsAscii, []bool{false, true, false, true}); diff != "" {
+		t.Errorf("bits differ: (-got +want)\n%s", diff)
+	}
+
+	if _, err := NewParser().DoBits(mcResp, 5); err == nil {
+		t.Errorf("expected error for a response shorter than the points")
+	}
+}
+
+func TestParser_DoCPUModel(t *testing.T) {
+	name := hex.EncodeToString([]byte("R04CPU          "))
+	// data length 0x14 = end code(2) + name(16) + code(2)
+	mcResp, _ := hex.DecodeString("d00000ffff03001400" + "0000" + name + "0048")
+
+	model, err := NewParser().DoCPUModel(mcResp)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(model, &CPUModel{Name: "R04CPU", Code: 0x4800}); diff != "" {
+		t.Errorf("cpu model differs: (-got +want)\n%s", diff)
+	}
+
+	asciiResp := []byte("D00000FF03FF000018" + "0000" + "Q03UDVCPU       " + "0366")
+	model, err = NewParser().DoCPUModel(asciiResp)
+	if err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+	if diff := cmp.Diff(model, &CPUModel{Name: "Q03UDVCPU", Code: 0x0366}); diff != "" {
+		t.Errorf("cpu model differs: (-got +want)\n%s", diff)
+	}
+
+	failed, _ := hex.DecodeString("d00000ffff030002005ac0")
+	if _, err := NewParser().DoCPUModel(failed); err == nil {
+		t.Fatalf("expected error for end code C05A")
+	}
+}
+
+func TestParser_DoHealthCheck(t *testing.T) {
+	ok, _ := hex.DecodeString("d00000ffff030009000000" + "0500" + "4142434445")
+	if err := NewParser().DoHealthCheck(ok); err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+
+	okAscii := []byte("D00000FF03FF000016" + "0000" + "0005ABCDE")
+	if err := NewParser().DoHealthCheck(okAscii); err != nil {
+		t.Fatalf("unexpected parser err: %v", err)
+	}
+
+	wrong, _ := hex.DecodeString("d00000ffff030009000000" + "0500" + "4142434446")
+	if err := NewParser().DoHealthCheck(wrong); err == nil {
+		t.Fatalf("expected error for wrong echo")
+	}
+}
+
+func TestParser_DoEndCode(t *testing.T) {
+	info := &ErrorInfo{NetworkNum: 0x00, PCNum: 0xFF, UnitIONum: 0x03FF, UnitStationNum: 0x00, Command: 0x0401, SubCommand: 0x0000}
+
+	mcResp, _ := hex.DecodeString("d00000ffff03000b0056c0" + "00ffff0300" + "0104" + "0000")
+	r, err := NewParser().Do(mcResp)
+	var endCodeErr *EndCodeError
+	if !errors.As(err, &endCodeErr) || !errors.Is(err, ErrDeviceOutOfRange) {
+		t.Fatalf("expected device out of range, got %v", err)
+	}
+	if diff := cmp.Diff(endCodeErr, &EndCodeError{EndCode: 0xC056, Info: info}); diff != "" {
+		t.Errorf("end code error differs: (-got +want)\n%s", diff)
+	}
+	if r == nil || r.Payload != nil || len(r.ErrInfo) != 9 {
+		t.Errorf("expected error information instead of payload, got %+v", r)
+	}
+
+	r4E, _ := hex.DecodeString("d40034120000" + "00ffff0300" + "0b00" + "59c0" + "00ffff0300" + "0104" + "0000")
+	if _, err := NewParser().Do(r4E); !errors.Is(err, ErrUnsupportedRequest) {
+		t.Errorf("expected unsupported request, got %v", err)
+	}
+
+	_, err = NewParser().Do([]byte("D00000FF03FF000016" + "C056" + "00FF03FF00" + "04010000"))
+	if !errors.As(err, &endCodeErr) {
+		t.Fatalf("expected end code error, got %v", err)
+	}
+	if diff := cmp.Diff(endCodeErr, &EndCodeError{EndCode: 0xC056, Info: info}); diff != "" {
+		t.Errorf("ascii end code error differs: (-got +want)\n%s", diff)
+	}
+
+	if _, err := NewParser().Do(mcResp[:11]); !errors.Is(err, ErrDeviceOutOfRange) || err.(*EndCodeError).Info != nil {
+		t.Errorf("expected end code without error information, got %v", err)
+	}
+
+	if _, err := NewParser().Do([]byte("D00000FF03FF000004" + "4031")); !errors.Is(err, ErrCPU) {
+		t.Errorf("expected CPU error, got %v", err)
+	}
+}
+
+func TestParser_DoFxEndCode(t *testing.T) {
+	_, err := NewParser().DoFx([]byte{0x81, 0x5B, 0x10})
+	var endCodeErr *EndCodeError
+	if !errors.As(err, &endCodeErr) || !errors.Is(err, ErrCPU) {
+		t.Fatalf("expected CPU error, got %v", err)
+	}
+	if diff := cmp.Diff(endCodeErr, &EndCodeError{EndCode: 0x5B, Fx: true, AbnormalCode: 0x10}); diff != "" {
+		t.Errorf("end code error differs: (-got +want)\n%s", diff)
+	}
+
+	if _, err := NewParser().DoFx([]byte("8152")); !errors.Is(err, ErrDeviceOutOfRange) {
+		t.Errorf("expected device out of range, got %v", err)
+	}
+}
+
+func TestParser_DoPayload(t *testing.T) {
+	binary3E, _ := hex.DecodeString("d00000ffff0300" + "0600" + "0000" + "34127856")
+	binary4E, _ := hex.DecodeString("d40034120000" + "00ffff0300" + "0600" + "0000" + "34127856")
+	for _, resp := range [][]byte{
+		binary3E,
+		binary4E,
+		[]byte("D000" + "00FF03FF00" + "000C" + "0000" + "12345678"),
+		[]byte("D400" + "1234" + "0000" + "00FF03FF00" + "000C" + "0000" + "12345678"),
+	} {
+		payload, err := NewParser().DoPayload(resp)
+		if err != nil {
+			t.Fatalf("unexpected err for %q: %v", resp, err)
+		}
+		if !cmp.Equal(payload, []byte{0x34, 0x12, 0x78, 0x56}) {
+			t.Fatalf("expected 34127856 but actual is %X (%q)", payload, resp)
+		}
+	}
+
+	// abnormal ends and broken frames are reported like Do
+	endCode, _ := hex.DecodeString("d00000ffff03000c00" + "5bc0" + "00ffff030001040000")
+	var endCodeErr *EndCodeError
+	if _, err := NewParser().DoPayload(endCode); !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0xC05B {
+		t.Fatalf("expected end code C05B but actual is %v", err)
+	}
+	if _, err := NewParser().DoPayload([]byte{0xD0, 0x00, 0x00}); err == nil {
+		t.Fatalf("expected error for truncated response")
+	}
+}
+
+// benchResponse is a 3E binary response to a batch read of 64 words.
+func benchResponse() []byte {
+	resp, _ := hex.DecodeString("d00000ffff03008200" + "0000")
+	return append(resp, make([]byte, 128)...)
+}
+
+func BenchmarkParser_Do(b *testing.B) {
+	resp := benchResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParser().Do(resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_DoPayload(b *testing.B) {
+	resp := benchResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParser().DoPayload(resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
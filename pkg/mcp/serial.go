@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"fmt"
+	"io"
+)
+
+// 制御コード (シリアルコミュニケーションユニット 形式1/形式4)
+const (
+	STX = 0x02
+	ETX = 0x03
+	ENQ = 0x05
+	ACK = 0x06
+	NAK = 0x15
+	CR  = 0x0D
+	LF  = 0x0A
+
+	FRAME_ID_3C = "F9" // QnA互換3Cフレーム
+	FRAME_ID_4C = "F8" // QnA互換4Cフレーム
+)
+
+// SerialFormat is the frame format set on the serial communication module (C24).
+// Both formats carry a sum check; format 4 ends every frame with CR LF.
+type SerialFormat int
+
+const (
+	// Format1 is ENQ ... sum check.
+	Format1 SerialFormat = 1
+	// Format4 is ENQ ... sum check CR LF.
+	Format4 SerialFormat = 4
+)
+
+// serialFrame is the ASCII frame of a serial communication module reached through a
+// serial device server: the same commands as 3E ascii frames with a different header.
+type serialFrame struct {
+	// frame ID: FRAME_ID_3C or FRAME_ID_4C
+	id     string
+	format SerialFormat
+	// station number of the serial communication module (00-1F), 2 hex characters
+	stationNum string
+}
+
+// New3CClient returns a client that sends QnA compatible 3C ASCII frames over a raw TCP stream,
+// to a serial communication module (C24) behind a serial-to-Ethernet device server.
+// stationNum is the station number set on the module. The station is switched to ascii code.
+func New3CClient(host string, port int, stn *station, stationNum byte, format SerialFormat) (Client, error) {
+	return newSerialClient(host, port, stn, serialFrame{id: FRAME_ID_3C, format: format, stationNum: fmt.Sprintf("%02X", stationNum)})
+}
+
+// New4CClient returns a client that sends QnA compatible 4C ASCII frames, which also carry the
+// request destination module I/O and station number of the route. See New3CClient.
+func New4CClient(host string, port int, stn *station, stationNum byte, format SerialFormat) (Client, error) {
+	return newSerialClient(host, port, stn, serialFrame{id: FRAME_ID_4C, format: format, stationNum: fmt.Sprintf("%02X", stationNum)})
+}
+
+func newSerialClient(host string, port int, stn *station, serial serialFrame) (Client, error) {
+	if serial.format != Format1 && serial.format != Format4 {
+		return nil, fmt.Errorf("mcp: unsupported serial frame format %d", serial.format)
+	}
+	c, err := New3EClient(host, port, stn)
+	if err != nil {
+		return nil, err
+	}
+	stn.SetCode(Ascii)
+	stn.serial = &serial
+	return c, nil
+}
+
+// serialHeader renders the header of 3C/4C request and response frames after the control code.
+// 3C: frame ID | 局番 | ネットワーク番号 | PC番号 | 自局番号
+// 4C: frame ID | 局番 | ネットワーク番号 | PC番号 | 要求先ユニットI/O番号 | 要求先ユニット局番号
+func (h *station) serialHeader() string {
+	return string(h.appendSerialHeader(nil))
+}
+
+// appendSerialHeader appends the serialHeader of request frames to dst.
+func (h *station) appendSerialHeader(dst []byte) []byte {
+	dst = append(dst, h.serial.id...)
+	dst = append(dst, h.serial.stationNum...)
+	dst = append(dst, h.networkNum...)
+	dst = append(dst, h.pcNum...)
+	if h.serial.id == FRAME_ID_4C {
+		return append(h.appendField(dst, h.unitIONum), h.unitStationNum...)
+	}
+	return append(dst, "00"...) // 自局番号
+}
+
+// sumCheck is the lower byte of the sum of data as 2 hex characters.
+func sumCheck(data []byte) string {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return fmt.Sprintf("%02X", sum)
+}
+
+// readFrameSerial reads one 3C/4C response frame from r and returns it as the equivalent
+// 3E ascii response, so the parser and the split helpers handle every frame the same way.
+//
+//	STX header data ETX sum check  -> normal end with response data
+//	ACK header                     -> normal end without response data
+//	NAK header error code          -> abnormal end
+//
+// followed by CR LF in format 4.
+func (h *station) readFrameSerial(r io.Reader) ([]byte, error) {
+	control := make([]byte, 1)
+	if _, err := io.ReadFull(r, control); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(h.serialHeader()))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[0:2]) != h.serial.id {
+		return nil, fmt.Errorf("%w: unexpected frame ID %q", ErrFrame, header[0:2])
+	}
+
+	endCode, data := "0000", []byte(nil)
+	switch control[0] {
+	case ACK:
+	case NAK:
+		code := make([]byte, 4)
+		if _, err := io.ReadFull(r, code); err != nil {
+			return nil, err
+		}
+		endCode = string(code)
+	case STX:
+		var err error
+		if data, err = readUntilETX(r); err != nil {
+			return nil, err
+		}
+		sum := make([]byte, 2)
+		if _, err := io.ReadFull(r, sum); err != nil {
+			return nil, err
+		}
+		if expected := sumCheck(append(append(header, data...), ETX)); string(sum) != expected {
+			return nil, fmt.Errorf("%w: sum check %q, want %s", ErrFrame, sum, expected)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unexpected control code %02X", ErrFrame, control[0])
+	}
+
+	if h.serial.format == Format4 {
+		crlf := make([]byte, 2)
+		if _, err := io.ReadFull(r, crlf); err != nil {
+			return nil, err
+		}
+		if crlf[0] != CR || crlf[1] != LF {
+			return nil, fmt.Errorf("%w: frame does not end with CR LF", ErrFrame)
+		}
+	}
+
+	// D000 | ネットワーク番号 | PC番号 | 要求先ユニットI/O番号 | 要求先ユニット局番号 | 応答データ長 | 終了コード | 応答データ
+	route := string(header[4:8]) + "03FF" + "00"
+	if h.serial.id == FRAME_ID_4C {
+		route = string(header[4:14])
+	}
+	resp := "D000" + route + fmt.Sprintf("%04X", 4+len(data)) + endCode
+	return append([]byte(resp), data...), nil
+}
+
+// readUntilETX reads response data up to and excluding ETX. ascii data never contains ETX.
+func readUntilETX(r io.Reader) ([]byte, error) {
+	var data []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if b[0] == ETX {
+			return data, nil
+		}
+		if len(data) >= maxResponseDataLen {
+			return nil, fmt.Errorf("%w: no ETX within %d characters", ErrFrame, maxResponseDataLen)
+		}
+		data = append(data, b[0])
+	}
+}
@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestStation_SerialFrame(t *testing.T) {
+	tests := []struct {
+		name string
+		stn  *station
+		want string
+	}{
+		{
+			name: "3C format 1",
+			stn:  &station{networkNum: "00", pcNum: "FF", unitIONum: "FF03", unitStationNum: "00", code: Ascii, serial: &serialFrame{id: FRAME_ID_3C, format: Format1, stationNum: "00"}},
+			want: "\x05F90000FF0004010000D*000100000100",
+		},
+		{
+			name: "4C format 4",
+			stn:  &station{networkNum: "00", pcNum: "FF", unitIONum: "FF03", unitStationNum: "00", code: Ascii, serial: &serialFrame{id: FRAME_ID_4C, format: Format4, stationNum: "1F"}},
+			want: "\x05F81F00FF03FF0004010000D*000100000105\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stn.BuildReadRequest("D", 100, 1); got != tt.want {
+				t.Errorf("BuildReadRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStation_ReadFrameSerial(t *testing.T) {
+	stn := &station{networkNum: "00", pcNum: "FF", unitIONum: "FF03", unitStationNum: "00", code: Ascii, serial: &serialFrame{id: FRAME_ID_3C, format: Format4, stationNum: "00"}}
+
+	resp, err := stn.readFrameSerial(strings.NewReader("\x02F90000FF001234\x03F8\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if want := "D00000FF03FF00000800001234"; string(resp) != want {
+		t.Errorf("readFrameSerial() = %q, want %q", resp, want)
+	}
+
+	if _, err := stn.readFrameSerial(strings.NewReader("\x02F90000FF001234\x0300\r\n")); !errors.Is(err, ErrFrame) {
+		t.Errorf("sum check mismatch: want ErrFrame, got %v", err)
+	}
+	if _, err := stn.readFrameSerial(strings.NewReader("\x02F90000FF001234\x03F8")); err == nil {
+		t.Errorf("missing CR LF: want error")
+	}
+}
+
+// serveSerial accepts one connection and answers 3C format 4 frames like a serial communication
+// module: the batch read of D100 returns 1234, writes are acknowledged and every other request
+// is refused with error code C051.
+func serveSerial(t *testing.T, ln net.Listener) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		frame, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		body := frame[1 : len(frame)-4] // ENQ と サム チェック + CR LF を除く
+		if frame[0] != ENQ || sumCheck([]byte(body)) != frame[len(frame)-4:len(frame)-2] {
+			t.Errorf("malformed request %q", frame)
+			return
+		}
+		header, request := body[0:10], body[10:]
+		var resp string
+		switch {
+		case strings.HasPrefix(request, "04010000D*000100"):
+			data := header + "1234"
+			resp = "\x02" + data + "\x03" + sumCheck([]byte(data+"\x03"))
+		case strings.HasPrefix(request, "1401"):
+			resp = "\x06" + header
+		default:
+			resp = "\x15" + header + "C051"
+		}
+		if _, err := conn.Write([]byte(resp + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient3C_ReadWrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go serveSerial(t, ln)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	client, err := New3CClient(addr.IP.String(), addr.Port, NewLocalStation(), 0, Format4)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	resp, err := client.Read(ctx, "D", 100, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	r, err := NewParser().Do(resp)
+	if err != nil {
+		t.Fatalf("unexpected parse err: %v", err)
+	}
+	if len(r.Payload) != 2 || r.Payload[0] != 0x34 || r.Payload[1] != 0x12 {
+		t.Errorf("payload = %X, want 3412", r.Payload)
+	}
+
+	resp, err = client.Write(ctx, "D", 100, 1, []byte{0x34, 0x12})
+	if err != nil {
+		t.Fatalf("unexpected write err: %v", err)
+	}
+	if _, err := NewParser().Do(resp); err != nil {
+		t.Errorf("unexpected write response err: %v", err)
+	}
+
+	resp, err = client.Read(ctx, "D", 200, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	var endCodeErr *EndCodeError
+	if _, err := NewParser().Do(resp); !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0xC051 {
+		t.Errorf("NAK: want end code C051, got %v", err)
+	}
+}
+
+func TestNew3CClient_Format(t *testing.T) {
+	if _, err := New3CClient("127.0.0.1", 5000, NewLocalStation(), 0, SerialFormat(2)); err == nil {
+		t.Errorf("format 2: want error")
+	}
+}
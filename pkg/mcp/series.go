@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PLC CPU series.
+// This item is selecting device specification layout and sub commands of request frames.
+type Series int
+
+const (
+	// QSeries is MELSEC-Q/L (and QnA compatible) series.
+	// Device number is 3 byte and device code is 1 byte (sub command 0000/0001).
+	QSeries Series = iota
+
+	// IQRSeries is MELSEC iQ-R series extended device specification.
+	// Device number is 4 byte and device code is 2 byte (sub command 0002/0003).
+	IQRSeries
+)
+
+// ParseSeries converts a configuration value ("Q", "L" or "iQ-R") into a Series.
+// An empty value selects QSeries.
+func ParseSeries(s string) (Series, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "q", "l", "ql", "q/l":
+		return QSeries, nil
+	case "r", "iqr", "iq-r":
+		return IQRSeries, nil
+	default:
+		return QSeries, fmt.Errorf("mcp: unknown PLC series %q", s)
+	}
+}
+
+func (s Series) String() string {
+	if s == IQRSeries {
+		return "iQ-R"
+	}
+	return "Q/L"
+}
+
+// HasDevice reports whether deviceName is a device of the series, like "ZR" or "LZ" (iQ-R only).
+func (s Series) HasDevice(deviceName string) bool {
+	device, ok := devices[deviceName]
+	return ok && (!device.iqr || s == IQRSeries)
+}
+
+// MaxRandomReadPoints is the limit of word points + double word points of a random read.
+func (s Series) MaxRandomReadPoints() int {
+	if s == IQRSeries {
+		return MAX_RANDOM_READ_POINTS_IQR
+	}
+	return MAX_RANDOM_READ_POINTS
+}
+
+// MaxRandomWriteSize is the limit of word points * 12 + double word points * 14 of a random write.
+func (s Series) MaxRandomWriteSize() int {
+	if s == IQRSeries {
+		return MAX_RANDOM_WRITE_SIZE_IQR
+	}
+	return MAX_RANDOM_WRITE_SIZE
+}
+
+// MaxRandomWriteBitPoints is the limit of points of a random write in bit units.
+func (s Series) MaxRandomWriteBitPoints() int {
+	if s == IQRSeries {
+		return MAX_RANDOM_WRITE_BIT_POINTS_IQR
+	}
+	return MAX_RANDOM_WRITE_BIT_POINTS
+}
+
+// MaxBlocks is the limit of word blocks + bit blocks of a multiple block batch request.
+func (s Series) MaxBlocks() int {
+	if s == IQRSeries {
+		return MAX_BLOCKS_IQR
+	}
+	return MAX_BLOCKS
+}
@@ -0,0 +1,173 @@
+package mcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxWordPoints is the limit of points of one batch read/write in word units:
+// MAX_WORD_POINTS for 3E/4E frames of both series, MAX_WORD_POINTS_FX for 1E frames.
+func (h *station) maxWordPoints(fx bool) int64 {
+	if fx {
+		return MAX_WORD_POINTS_FX
+	}
+	return MAX_WORD_POINTS
+}
+
+// maxJoinedPoints is the limit of points of a split read in word units joined into one 3E/4E response:
+// its 16 bit data length counts the end code and 2 byte (binary) or 4 characters (ascii) per point.
+// 1E responses carry no length.
+func maxJoinedPoints(code Code) int64 {
+	if code == Ascii {
+		return (0xFFFF - 4) / 4
+	}
+	return (0xFFFF - 2) / 2
+}
+
+// wordStep is how many device numbers one point of a batch read/write in word units spans:
+// 16 for a bit device, which is accessed 16 bits per word, 1 for a word device.
+func wordStep(deviceName string, fx bool) int64 {
+	bit := IsBitDevice(deviceName)
+	if fx {
+		bit = devicesFx[deviceName].bit
+	}
+	if bit {
+		return 16
+	}
+	return 1
+}
+
+// readSplit reads numPoints points starting at offset with requests of at most maxPoints points
+// and joins the responses into one response frame, as if the PLC had answered a single request.
+// Each point spans step device numbers (see wordStep), so a request starts step*points further on.
+// A response with a non-zero end code is returned as it is, so the caller's parser reports it.
+// More points than one response of code can carry (maxJoinedPoints) are refused before any request.
+func readSplit(ctx context.Context, offset, numPoints, maxPoints, step int64, fx bool, code Code, read func(ctx context.Context, offset, numPoints int64) ([]byte, error)) ([]byte, error) {
+	if numPoints <= maxPoints {
+		return read(ctx, offset, numPoints)
+	}
+	if limit := maxJoinedPoints(code); !fx && numPoints > limit {
+		return nil, fmt.Errorf("mcp: read of %d points exceeds the limit of %d of one response", numPoints, limit)
+	}
+
+	var joined []byte
+	for done := int64(0); done < numPoints; done += maxPoints {
+		resp, err := read(ctx, offset+step*done, min(maxPoints, numPoints-done))
+		if err != nil {
+			return nil, err
+		}
+		if failed, err := endedAbnormally(resp, fx); err != nil || failed {
+			return resp, err
+		}
+		if joined == nil {
+			joined = resp
+			continue
+		}
+		joined = append(joined, resp[responseDataOffset(resp, fx):]...)
+	}
+	return setResponseDataLen(joined, fx), nil
+}
+
+// writeSplit writes numPoints points starting at offset with requests of at most maxPoints points.
+// writeData holds 2 byte per point, each spanning step device numbers. It returns the last response, or the first one with a non-zero end code.
+func writeSplit(ctx context.Context, offset, numPoints, maxPoints, step int64, fx bool, writeData []byte, write func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error)) ([]byte, error) {
+	if numPoints <= maxPoints {
+		return write(ctx, offset, numPoints, writeData)
+	}
+
+	var resp []byte
+	for done := int64(0); done < numPoints; done += maxPoints {
+		points := min(maxPoints, numPoints-done)
+		var err error
+		resp, err = write(ctx, offset+step*done, points, writeData[2*done:2*(done+points)])
+		if err != nil {
+			return nil, err
+		}
+		if failed, err := endedAbnormally(resp, fx); err != nil || failed {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// endedAbnormally reports whether resp carries a non-zero end code.
+func endedAbnormally(resp []byte, fx bool) (bool, error) {
+	parse := NewParser().Do
+	if fx {
+		parse = NewParser().DoFx
+	}
+	_, err := parse(resp)
+	var endCodeErr *EndCodeError
+	if errors.As(err, &endCodeErr) {
+		return true, nil
+	}
+	return false, err
+}
+
+// responseDataOffset returns where the response data of resp starts.
+func responseDataOffset(resp []byte, fx bool) int {
+	ascii := resp[0] != 0xD0 && resp[0] != 0xD4 && !(fx && resp[0]&0x80 != 0)
+	switch {
+	case fx && ascii:
+		return 4 // サブヘッダ(2) + 終了コード(2)
+	case fx:
+		return 2
+	case ascii && string(resp[0:4]) == SUB_HEADER_4E_RESP:
+		return responseHeaderLen4EAscii + 4
+	case ascii:
+		return responseHeaderLen3EAscii + 4
+	case resp[0] == 0xD4:
+		return responseHeaderLen4E + 2
+	default:
+		return responseHeaderLen3E + 2
+	}
+}
+
+// setResponseDataLen rewrites the response data length field of a joined 3E/4E response.
+// 1E responses carry no length.
+func setResponseDataLen(resp []byte, fx bool) []byte {
+	if fx {
+		return resp
+	}
+	dataStart := responseDataOffset(resp, fx)
+	switch dataStart {
+	case responseHeaderLen3E + 2, responseHeaderLen4E + 2:
+		// data length counts the end code and the data
+		binary.LittleEndian.PutUint16(resp[dataStart-4:dataStart-2], uint16(len(resp)-dataStart+2))
+	default:
+		copy(resp[dataStart-8:dataStart-4], fmt.Sprintf("%04X", len(resp)-dataStart+4))
+	}
+	return resp
+}
+
+// readBitsSplit reads numPoints bit devices starting at offset with requests of at most maxPoints points.
+func readBitsSplit(ctx context.Context, offset, numPoints, maxPoints int64, read func(ctx context.Context, offset, numPoints int64) ([]bool, error)) ([]bool, error) {
+	bits := make([]bool, 0, numPoints)
+	for done := int64(0); done < numPoints; done += maxPoints {
+		chunk, err := read(ctx, offset+done, min(maxPoints, numPoints-done))
+		if err != nil {
+			return nil, err
+		}
+		bits = append(bits, chunk...)
+	}
+	return bits, nil
+}
+
+// writeBitsSplit writes values to bit devices starting at offset with requests of at most maxPoints points.
+// It returns the last response, or the first one with a non-zero end code.
+func writeBitsSplit(ctx context.Context, offset int64, values []bool, maxPoints int, fx bool, write func(ctx context.Context, offset int64, values []bool) ([]byte, error)) ([]byte, error) {
+	var resp []byte
+	for done := 0; done < len(values); done += maxPoints {
+		var err error
+		resp, err = write(ctx, offset+int64(done), values[done:min(done+maxPoints, len(values))])
+		if err != nil {
+			return nil, err
+		}
+		if failed, err := endedAbnormally(resp, fx); err != nil || failed {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
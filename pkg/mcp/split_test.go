@@ -0,0 +1,231 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeWords answers a word read with the device number as the value of every point.
+func fakeWords(t *testing.T, calls *[]int64, ascii, fx bool) func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+	return func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+		*calls = append(*calls, numPoints)
+		switch {
+		case fx && ascii:
+			var b strings.Builder
+			b.WriteString("8100")
+			for i := int64(0); i < numPoints; i++ {
+				fmt.Fprintf(&b, "%04X", uint16(offset+i))
+			}
+			return []byte(b.String()), nil
+		case fx:
+			resp := []byte{0x81, 0x00}
+			for i := int64(0); i < numPoints; i++ {
+				resp = binary.LittleEndian.AppendUint16(resp, uint16(offset+i))
+			}
+			return resp, nil
+		case ascii:
+			var b strings.Builder
+			fmt.Fprintf(&b, "D00000FF03FF00%04X0000", 4+4*numPoints)
+			for i := int64(0); i < numPoints; i++ {
+				fmt.Fprintf(&b, "%04X", uint16(offset+i))
+			}
+			return []byte(b.String()), nil
+		default:
+			resp, _ := hex.DecodeString("d00000ffff0300")
+			resp = binary.LittleEndian.AppendUint16(resp, uint16(2+2*numPoints))
+			resp = append(resp, 0x00, 0x00)
+			for i := int64(0); i < numPoints; i++ {
+				resp = binary.LittleEndian.AppendUint16(resp, uint16(offset+i))
+			}
+			return resp, nil
+		}
+	}
+}
+
+func TestReadSplit(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		ascii, fx bool
+		max       int64
+	}{
+		{"binary", false, false, MAX_WORD_POINTS},
+		{"ascii", true, false, MAX_WORD_POINTS},
+		{"fx", false, true, MAX_WORD_POINTS_FX},
+		{"fx ascii", true, true, MAX_WORD_POINTS_FX},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []int64
+			numPoints := 2*tc.max + 100
+			code := Binary
+			if tc.ascii {
+				code = Ascii
+			}
+			resp, err := readSplit(context.Background(), 1000, numPoints, tc.max, 1, tc.fx, code, fakeWords(t, &calls, tc.ascii, tc.fx))
+			if err != nil {
+				t.Fatalf("unexpected read err: %v", err)
+			}
+			if len(calls) != 3 || calls[0] != tc.max || calls[2] != 100 {
+				t.Fatalf("unexpected requests %v", calls)
+			}
+
+			parse := NewParser().Do
+			if tc.fx {
+				parse = NewParser().DoFx
+			}
+			r, err := parse(resp)
+			if err != nil {
+				t.Fatalf("unexpected parser err: %v", err)
+			}
+			if int64(len(r.Payload)) != 2*numPoints {
+				t.Fatalf("expected %d bytes of data but actual is %d", 2*numPoints, len(r.Payload))
+			}
+			for i := int64(0); i < numPoints; i++ {
+				if v := binary.LittleEndian.Uint16(r.Payload[2*i:]); v != uint16(1000+i) {
+					t.Fatalf("point %d is %d, want %d", i, v, 1000+i)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSplit_BitDevice(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		fx         bool
+		max        int64
+		wantSecond int64
+	}{
+		// M0 in word units: the second request starts 16 bits per word further on
+		{"3E", false, MAX_WORD_POINTS, 16 * MAX_WORD_POINTS},
+		{"1E", true, MAX_WORD_POINTS_FX, 16 * MAX_WORD_POINTS_FX},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var heads []int64
+			var calls []int64
+			words := fakeWords(t, &calls, false, tc.fx)
+			read := func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+				heads = append(heads, offset)
+				return words(ctx, offset, numPoints)
+			}
+			if _, err := readSplit(context.Background(), 0, tc.max+1, tc.max, wordStep("M", tc.fx), tc.fx, Binary, read); err != nil {
+				t.Fatalf("unexpected read err: %v", err)
+			}
+			if len(heads) != 2 || heads[1] != tc.wantSecond {
+				t.Fatalf("expected the second request at M%d but the requests start at %v", tc.wantSecond, heads)
+			}
+
+			ok := []byte{0x83, 0x00}
+			if !tc.fx {
+				ok, _ = hex.DecodeString("d00000ffff030002000000")
+			}
+			heads = nil
+			data := make([]byte, 2*(tc.max+1))
+			if _, err := writeSplit(context.Background(), 0, tc.max+1, tc.max, wordStep("M", tc.fx), tc.fx, data, func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error) {
+				heads = append(heads, offset)
+				return ok, nil
+			}); err != nil {
+				t.Fatalf("unexpected write err: %v", err)
+			}
+			if len(heads) != 2 || heads[1] != tc.wantSecond {
+				t.Fatalf("expected the second write at M%d but the requests start at %v", tc.wantSecond, heads)
+			}
+		})
+	}
+}
+
+func TestReadSplit_EndCode(t *testing.T) {
+	failed, _ := hex.DecodeString("d00000ffff03000b0056c000ffff030001040000")
+	n := 0
+	resp, err := readSplit(context.Background(), 0, 2000, MAX_WORD_POINTS, 1, false, Binary, func(ctx context.Context, offset, numPoints int64) ([]byte, error) {
+		if n++; n == 2 {
+			return failed, nil
+		}
+		var calls []int64
+		return fakeWords(t, &calls, false, false)(ctx, offset, numPoints)
+	})
+	if err != nil || !bytes.Equal(resp, failed) {
+		t.Fatalf("expected the failed response, got %x (%v)", resp, err)
+	}
+	if _, err := NewParser().Do(resp); !errors.Is(err, ErrDeviceOutOfRange) {
+		t.Fatalf("expected device out of range, got %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected reading to stop at the failed request, got %d requests", n)
+	}
+}
+
+func TestReadSplit_TooManyPoints(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		ascii bool
+		code  Code
+	}{
+		{"binary", false, Binary},
+		{"ascii", true, Ascii},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limit := maxJoinedPoints(tc.code)
+
+			// one more point than the data length can count is refused before any request
+			var calls []int64
+			if _, err := readSplit(context.Background(), 0, limit+1, MAX_WORD_POINTS, 1, false, tc.code, fakeWords(t, &calls, tc.ascii, false)); err == nil {
+				t.Fatalf("expected an error for %d points", limit+1)
+			}
+			if len(calls) != 0 {
+				t.Fatalf("expected no request, got %v", calls)
+			}
+
+			resp, err := readSplit(context.Background(), 0, limit, MAX_WORD_POINTS, 1, false, tc.code, fakeWords(t, &calls, tc.ascii, false))
+			if err != nil {
+				t.Fatalf("unexpected read err: %v", err)
+			}
+			r, err := NewParser().Do(resp)
+			if err != nil {
+				t.Fatalf("unexpected parser err: %v", err)
+			}
+			if int64(len(r.Payload)) != 2*limit {
+				t.Fatalf("expected %d bytes of data but actual is %d", 2*limit, len(r.Payload))
+			}
+		})
+	}
+}
+
+func TestWriteSplit(t *testing.T) {
+	ok, _ := hex.DecodeString("d00000ffff030002000000")
+	data := make([]byte, 2*1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var written []byte
+	var offsets []int64
+	resp, err := writeSplit(context.Background(), 100, 1000, MAX_WORD_POINTS, 1, false, data, func(ctx context.Context, offset, numPoints int64, writeData []byte) ([]byte, error) {
+		offsets = append(offsets, offset)
+		written = append(written, writeData...)
+		return ok, nil
+	})
+	if err != nil || !bytes.Equal(resp, ok) {
+		t.Fatalf("unexpected write result %x (%v)", resp, err)
+	}
+	if len(offsets) != 2 || offsets[1] != 100+MAX_WORD_POINTS {
+		t.Fatalf("unexpected write offsets %v", offsets)
+	}
+	if !bytes.Equal(written, data) {
+		t.Fatalf("written data differs from the data to write")
+	}
+
+	values := make([]bool, MAX_BIT_POINTS+1)
+	var bitOffsets []int64
+	if _, err := writeBitsSplit(context.Background(), 0, values, MAX_BIT_POINTS, false, func(ctx context.Context, offset int64, values []bool) ([]byte, error) {
+		bitOffsets = append(bitOffsets, offset)
+		return ok, nil
+	}); err != nil || len(bitOffsets) != 2 || bitOffsets[1] != MAX_BIT_POINTS {
+		t.Fatalf("unexpected bit write offsets %v (%v)", bitOffsets, err)
+	}
+}
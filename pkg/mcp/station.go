@@ -1,15 +1,9 @@
 package mcp
 
-import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
-)
+import "fmt"
 
 const (
-	SUB_HEADER      = "5000" // 3Eフレームでは固定
-	SUB_HEADER_FX16 = "01"   // 1Eフレーム 1点単位（ビット）
-	SUB_HEADER_FX01 = "00"   // 1Eフレーム 16点単位（ワード）
+	SUB_HEADER = "5000" // 3Eフレームでは固定
 
 	HEALTH_CHECK_COMMAND    = "1906" // binary mode expression. if ascii mode then 0619
 	HEALTH_CHECK_SUBCOMMAND = "0000"
@@ -18,39 +12,114 @@ const (
 	READ_SUB_COMMAND     = "0000"
 	BIT_READ_SUB_COMMAND = "0100"
 
-	WRITE_COMMAND     = "0114" // binary mode expression. if ascii mode then 1401
-	WRITE_SUB_COMMAND = "0000"
+	WRITE_COMMAND         = "0114" // binary mode expression. if ascii mode then 1401
+	WRITE_SUB_COMMAND     = "0000"
+	BIT_WRITE_SUB_COMMAND = "0100"
+
+	MAX_BIT_POINTS  = 3584 // batch read/write in bit units
+	MAX_WORD_POINTS = 960  // batch read/write in word units. iQ-R extended specification has the same limit
+
+	RANDOM_READ_COMMAND          = "0304" // binary mode expression. if ascii mode then 0403
+	RANDOM_READ_SUB_COMMAND      = "0000"
+	RANDOM_WRITE_COMMAND         = "0214" // binary mode expression. if ascii mode then 1402
+	RANDOM_WRITE_SUB_COMMAND     = "0000"
+	RANDOM_WRITE_BIT_SUB_COMMAND = "0100"
+	MAX_RANDOM_READ_POINTS       = 192  // word points + double word points
+	MAX_RANDOM_WRITE_SIZE        = 1920 // word points * 12 + double word points * 14
+	MAX_RANDOM_WRITE_BIT_POINTS  = 188
+
+	MULTI_BLOCK_READ_COMMAND  = "0604" // binary mode expression. if ascii mode then 0406
+	MULTI_BLOCK_WRITE_COMMAND = "0614" // binary mode expression. if ascii mode then 1406
+	MULTI_BLOCK_SUB_COMMAND   = "0000"
+	MAX_BLOCKS                = 120 // word blocks + bit blocks
+	MAX_BLOCK_POINTS          = 960 // total points of all blocks. write: blocks * 4 + points
+
+	BUFFER_READ_COMMAND  = "0106" // binary mode expression. if ascii mode then 0601
+	BUFFER_WRITE_COMMAND = "0116" // binary mode expression. if ascii mode then 1601
+	BUFFER_SUB_COMMAND   = "0000"
+	MAX_BUFFER_POINTS    = 960  // 1920 byte per request
+	MAX_BUFFER_MODULE    = 0xFF // U0-UFF: start I/O number 000-FF0
+
+	CPU_MODEL_COMMAND     = "0101" // binary mode expression. if ascii mode then 0101
+	CPU_MODEL_SUB_COMMAND = "0000"
+
+	REMOTE_RUN_COMMAND         = "0110" // binary mode expression. if ascii mode then 1001
+	REMOTE_STOP_COMMAND        = "0210" // binary mode expression. if ascii mode then 1002
+	REMOTE_PAUSE_COMMAND       = "0310" // binary mode expression. if ascii mode then 1003
+	REMOTE_LATCH_CLEAR_COMMAND = "0510" // binary mode expression. if ascii mode then 1005
+	REMOTE_RESET_COMMAND       = "0610" // binary mode expression. if ascii mode then 1006
+	REMOTE_SUB_COMMAND         = "0000"
+	REMOTE_MODE                = "0100" // 0001: 強制実行しない (0003: 強制実行する)
+	REMOTE_CLEAR_MODE          = "00"   // RUN時 クリアしない
 
 	MONITORING_TIMER = "1000" // 3[sec]
 )
 
-// deviceCodes is device name and hex value map
-var deviceCodes = map[string]string{
-	"X": "9C",
-	"Y": "9D",
-	"M": "90",
-	"L": "92",
-	"F": "93",
-	"V": "94",
-	"B": "A0",
-	"W": "B4",
-	"D": "A8",
-}
-
-// FX Seris device name and hex value map
-var deviceCodesFx = map[string]string{
-	"X": "2058",
-	"Y": "2059",
-	"M": "204D",
-	"L": "204D",
-	"S": "204D",
-	"F": "2046",
-	"B": "2042",
-	"T": "4E54",
-	"C": "4E43",
-	"W": "2057",
-	"D": "2044",
-	"R": "2052",
+// MELSEC iQ-R extended device specification (sub command 0002/0003) halves the random and multiple block limits.
+const (
+	MAX_RANDOM_READ_POINTS_IQR      = 96
+	MAX_RANDOM_WRITE_SIZE_IQR       = 960
+	MAX_RANDOM_WRITE_BIT_POINTS_IQR = 94
+	MAX_BLOCKS_IQR                  = 60
+)
+
+// deviceInfo is the MC protocol specification of a device.
+type deviceInfo struct {
+	// binary device code. iQ-R extended device specification sends it as 2 byte (upper byte 00)
+	code byte
+	// ascii device code of MELSEC-Q/L (2char). MELSEC iQ-R pads the device name with '*' to 4char
+	ascii string
+	// bit device. every other device is a word device
+	bit bool
+	// device number is hexadecimal. every other device is decimal
+	hex bool
+	// only available on MELSEC iQ-R (extended device specification)
+	iqr bool
+}
+
+// devices is device name and MC protocol specification map
+// MELSECコミュニケーションプロトコル リファレンス(p67) デバイスコード一覧
+var devices = map[string]deviceInfo{
+	"SM":  {code: 0x91, ascii: "SM", bit: true}, // 特殊リレー
+	"SD":  {code: 0xA9, ascii: "SD"},            // 特殊レジスタ
+	"X":   {code: 0x9C, ascii: "X*", bit: true, hex: true},
+	"Y":   {code: 0x9D, ascii: "Y*", bit: true, hex: true},
+	"M":   {code: 0x90, ascii: "M*", bit: true},
+	"L":   {code: 0x92, ascii: "L*", bit: true},            // ラッチリレー
+	"F":   {code: 0x93, ascii: "F*", bit: true},            // アナンシエータ
+	"V":   {code: 0x94, ascii: "V*", bit: true},            // エッジリレー
+	"B":   {code: 0xA0, ascii: "B*", bit: true, hex: true}, // リンクリレー
+	"D":   {code: 0xA8, ascii: "D*"},
+	"W":   {code: 0xB4, ascii: "W*", hex: true},            // リンクレジスタ
+	"TS":  {code: 0xC1, ascii: "TS", bit: true},            // タイマ 接点
+	"TC":  {code: 0xC0, ascii: "TC", bit: true},            // タイマ コイル
+	"TN":  {code: 0xC2, ascii: "TN"},                       // タイマ 現在値
+	"STS": {code: 0xC7, ascii: "SS", bit: true},            // 積算タイマ 接点
+	"STC": {code: 0xC6, ascii: "SC", bit: true},            // 積算タイマ コイル
+	"STN": {code: 0xC8, ascii: "SN"},                       // 積算タイマ 現在値
+	"CS":  {code: 0xC4, ascii: "CS", bit: true},            // カウンタ 接点
+	"CC":  {code: 0xC3, ascii: "CC", bit: true},            // カウンタ コイル
+	"CN":  {code: 0xC5, ascii: "CN"},                       // カウンタ 現在値
+	"SB":  {code: 0xA1, ascii: "SB", bit: true, hex: true}, // リンク特殊リレー
+	"SW":  {code: 0xB5, ascii: "SW", hex: true},            // リンク特殊レジスタ
+	"S":   {code: 0x98, ascii: "S*", bit: true},            // ステップリレー
+	"DX":  {code: 0xA2, ascii: "DX", bit: true, hex: true}, // ダイレクトアクセス入力
+	"DY":  {code: 0xA3, ascii: "DY", bit: true, hex: true}, // ダイレクトアクセス出力
+	"Z":   {code: 0xCC, ascii: "Z*"},                       // インデックスレジスタ
+	"LZ":  {code: 0x62, iqr: true},                         // ロングインデックスレジスタ (2 word per point)
+	"R":   {code: 0xAF, ascii: "R*"},                       // ファイルレジスタ ブロック切換え方式
+	"ZR":  {code: 0xB0, ascii: "ZR", hex: true},            // ファイルレジスタ 連番アクセス方式
+}
+
+// IsBitDevice reports whether deviceName is a bit device such as 'M' or 'X'.
+func IsBitDevice(deviceName string) bool {
+	return devices[deviceName].bit
+}
+
+// IsHexDevice reports whether the device number of deviceName is hexadecimal, like X, Y, B and W.
+// Every other device number is decimal.
+func IsHexDevice(deviceName string) bool {
+	return devices[deviceName].hex
 }
 
 // Each single PLC that is connected on MELSECNET and CC-Link IE is called a station.
@@ -63,6 +132,14 @@ type station struct {
 	unitIONum string
 	// PLC stn Unit Station Number
 	unitStationNum string
+	// data communication code of request and response frames
+	code Code
+	// CPU series. selects device specification layout and sub commands
+	series Series
+	// 3C/4C frame of a serial communication module; nil for 3E frames. see New3CClient
+	serial *serialFrame
+	// remote password sent with the unlock command on every new connection; never logged
+	password string
 }
 
 func NewStation(networkNum, pcNum, unitIONum, unitStationNum string) *station {
@@ -71,6 +148,7 @@ func NewStation(networkNum, pcNum, unitIONum, unitStationNum string) *station {
 		pcNum:          pcNum,
 		unitIONum:      unitIONum,
 		unitStationNum: unitStationNum,
+		code:           Binary,
 	}
 }
 
@@ -81,30 +159,213 @@ func NewLocalStation() *station {
 		pcNum:          "FF",   // 自局の場合はFF固定
 		unitIONum:      "FF03", // マルチドロップ接続などでない場合はFF03固定値
 		unitStationNum: "00",   // マルチドロップ接続などでない場合は00固定値
+		code:           Binary,
+	}
+}
+
+// SetCode selects the data communication code (Ascii or Binary) of the station's frames.
+// It must match the communication data code set on the PLC Ethernet module.
+func (h *station) SetCode(code Code) *station {
+	h.code = code
+	return h
+}
+
+// Code returns the data communication code of the station's frames.
+func (h *station) Code() Code {
+	return h.code
+}
+
+// SetSeries selects the CPU series (QSeries or IQRSeries) of the station's frames.
+// IQRSeries sends 4 byte device numbers and 2 byte device codes with sub command 0002/0003.
+func (h *station) SetSeries(series Series) *station {
+	h.series = series
+	return h
+}
+
+// Series returns the CPU series of the station's frames.
+func (h *station) Series() Series {
+	return h.series
+}
+
+// appendSubCommand appends sub command sub (binary layout, e.g. BIT_READ_SUB_COMMAND) in the station's code.
+// MELSEC iQ-R extended device specification sets bit 1: 0000 -> 0002, 0001 -> 0003.
+func (h *station) appendSubCommand(dst []byte, sub string) []byte {
+	v := fieldValue(sub)
+	if h.series == IQRSeries {
+		v |= 0x02
+	}
+	return h.appendUint(dst, v, 2)
+}
+
+// appendDeviceSpec appends head device number and device code.
+// MELSECコミュニケーションプロトコル リファレンス(p67) MELSEC-Q/L: 3[byte], MELSEC iQ-R: 4[byte]
+func (h *station) appendDeviceSpec(dst []byte, deviceName string, offset int64) []byte {
+	device := devices[deviceName]
+	if h.code == Ascii {
+		// ascii: device code(Q/L 2char, iQ-R 4char) + device number(Q/L 6char, iQ-R 10char) in the device's own radix
+		base := uint64(10)
+		if device.hex {
+			base = 16
+		}
+		if h.series == IQRSeries {
+			dst = append(dst, deviceName...)
+			for i := len(deviceName); i < 4; i++ {
+				dst = append(dst, '*')
+			}
+			return appendDigits(dst, uint64(offset), base, 10)
+		}
+		return appendDigits(append(dst, device.ascii...), uint64(offset), base, 6)
+	}
+
+	if h.series == IQRSeries {
+		// iQ-R: device number 4byte + device code 2byte (lower byte first)
+		return h.appendUint(h.appendUint(dst, uint64(offset), 4), uint64(device.code), 2)
 	}
+	return append(h.appendUint(dst, uint64(offset), 3), device.code) // Q/Lシリーズは3byte
+}
+
+// appendWordData appends little endian word data (2 byte per device point) in the station's code.
+func (h *station) appendWordData(dst []byte, data []byte) []byte {
+	if h.code != Ascii {
+		return append(dst, data...)
+	}
+	for i := 0; i+1 < len(data); i += 2 {
+		dst = h.appendUint(dst, uint64(data[i])|uint64(data[i+1])<<8, 2)
+	}
+	return dst
+}
+
+// appendBitData appends bit states in the station's code.
+// binary packs 2 points per byte, upper nibble first (odd counts are padded with 0),
+// ascii sends 1 character ("0" or "1") per point.
+func (h *station) appendBitData(dst []byte, values []bool) []byte {
+	if h.code == Ascii {
+		for _, v := range values {
+			if v {
+				dst = append(dst, '1')
+			} else {
+				dst = append(dst, '0')
+			}
+		}
+		return dst
+	}
+	for i := 0; i < len(values); i += 2 {
+		var b byte
+		if values[i] {
+			b = 0x10
+		}
+		if i+1 < len(values) && values[i+1] {
+			b |= 0x01
+		}
+		dst = append(dst, b)
+	}
+	return dst
 }
 
 func (h *station) BuildHealthCheckRequest() string {
+	return h.frameString(h.AppendHealthCheckRequest(nil))
+}
+
+// AppendHealthCheckRequest appends the BuildHealthCheckRequest frame to dst in wire format.
+func (h *station) AppendHealthCheckRequest(dst []byte) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, HEALTH_CHECK_COMMAND)
+	dst = h.appendField(dst, HEALTH_CHECK_SUBCOMMAND)
+	dst = h.appendUint(dst, 5, 2) // 5 device
+	dst = append(dst, "ABCDE"...)
+	return h.endFrame(dst, mark)
+}
+
+// BuildCPUModelRequest represents MCP read CPU model name command.
+// Response data is model name(16 character, space padded) + model code(2byte).
+func (h *station) BuildCPUModelRequest() string {
+	return h.frameString(h.AppendCPUModelRequest(nil))
+}
 
-	returnDataNum := "0500"    // 5 device. if ascii mode then 0005
-	returnData := "4142434445" // value is "ABCDE".
+// AppendCPUModelRequest appends the BuildCPUModelRequest frame to dst in wire format.
+func (h *station) AppendCPUModelRequest(dst []byte) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, CPU_MODEL_COMMAND)
+	dst = h.appendField(dst, CPU_MODEL_SUB_COMMAND)
+	return h.endFrame(dst, mark)
+}
+
+// appendBufferSpec appends the buffer memory range of an intelligent function module.
+// 先頭アドレス(4byte) + バイト数(2byte) + ユニット番号(2byte).
+// The address and size count bytes, so the word address and points of U\G are doubled.
+// module is the start I/O number of the module without its last digit: U3 is X/Y30.
+func (h *station) appendBufferSpec(dst []byte, module uint16, address, numPoints int64) []byte {
+	dst = h.appendUint(dst, uint64(2*address), 4)
+	dst = h.appendUint(dst, uint64(2*numPoints), 2)
+	return h.appendUint(dst, uint64(module), 2)
+}
 
-	requestStr := HEALTH_CHECK_COMMAND + HEALTH_CHECK_SUBCOMMAND + returnDataNum + returnData
+// BuildBufferReadRequest represents MCP intelligent function module buffer memory batch read command.
+// address is the buffer memory address (G) in words, numPoints is number of read words.
+func (h *station) BuildBufferReadRequest(module uint16, address, numPoints int64) string {
+	return h.frameString(h.AppendBufferReadRequest(nil, module, address, numPoints))
+}
 
-	// data length
-	requestCharLen := len(MONITORING_TIMER+requestStr) / 2 // 1byte=2char
-	dataLenBuff := new(bytes.Buffer)
-	_ = binary.Write(dataLenBuff, binary.LittleEndian, int64(requestCharLen))
-	dataLen := fmt.Sprintf("%X", dataLenBuff.Bytes()[0:2]) // 2byte固定
+// AppendBufferReadRequest appends the BuildBufferReadRequest frame to dst in wire format.
+func (h *station) AppendBufferReadRequest(dst []byte, module uint16, address, numPoints int64) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, BUFFER_READ_COMMAND)
+	dst = h.appendField(dst, BUFFER_SUB_COMMAND)
+	dst = h.appendBufferSpec(dst, module, address, numPoints)
+	return h.endFrame(dst, mark)
+}
+
+// BuildBufferWriteRequest represents MCP intelligent function module buffer memory batch write command.
+// writeData holds 2 byte per point (lower byte first); data beyond 2*numPoints bytes is ignored.
+func (h *station) BuildBufferWriteRequest(module uint16, address, numPoints int64, writeData []byte) string {
+	return h.frameString(h.AppendBufferWriteRequest(nil, module, address, numPoints, writeData))
+}
 
-	return SUB_HEADER +
-		h.networkNum +
-		h.pcNum +
-		h.unitIONum +
-		h.unitStationNum +
-		dataLen +
-		MONITORING_TIMER +
-		requestStr
+// AppendBufferWriteRequest appends the BuildBufferWriteRequest frame to dst in wire format.
+func (h *station) AppendBufferWriteRequest(dst []byte, module uint16, address, numPoints int64, writeData []byte) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, BUFFER_WRITE_COMMAND)
+	dst = h.appendField(dst, BUFFER_SUB_COMMAND)
+	dst = h.appendBufferSpec(dst, module, address, numPoints)
+	dst = h.appendWordData(dst, writeData[0:2*numPoints])
+	return h.endFrame(dst, mark)
+}
+
+// BuildRemoteRequest represents MCP remote operation command (RUN/STOP/PAUSE/latch clear/reset).
+// RUN and PAUSE are not forced, so they fail while another device holds the CPU in STOP/PAUSE.
+func (h *station) BuildRemoteRequest(op RemoteOp) (string, error) {
+	request, err := h.AppendRemoteRequest(nil, op)
+	if err != nil {
+		return "", err
+	}
+	return h.frameString(request), nil
+}
+
+// AppendRemoteRequest appends the BuildRemoteRequest frame to dst in wire format.
+func (h *station) AppendRemoteRequest(dst []byte, op RemoteOp) ([]byte, error) {
+	var command, data string
+	switch op {
+	case RemoteRun:
+		// mode(2byte) + clear mode(1byte) + 固定値00
+		command, data = REMOTE_RUN_COMMAND, REMOTE_MODE+REMOTE_CLEAR_MODE+"00"
+	case RemoteStop:
+		command, data = REMOTE_STOP_COMMAND, "0100" // 固定値0001
+	case RemotePause:
+		command, data = REMOTE_PAUSE_COMMAND, REMOTE_MODE
+	case RemoteLatchClear:
+		command, data = REMOTE_LATCH_CLEAR_COMMAND, "0100" // 固定値0001
+	case RemoteReset:
+		command, data = REMOTE_RESET_COMMAND, "0100" // 固定値0001
+	default:
+		return dst, fmt.Errorf("mcp: unknown remote operation %v", op)
+	}
+
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, command)
+	dst = h.appendField(dst, REMOTE_SUB_COMMAND)
+	dst = h.appendField(dst, data[0:4])
+	dst = h.appendWire(dst, data[4:])
+	return h.endFrame(dst, mark), nil
 }
 
 // BuildReadRequest represents MCP read as word command.
@@ -112,115 +373,36 @@ func (h *station) BuildHealthCheckRequest() string {
 // offset is device offset addr.
 // numPoints is number of read device points.
 func (h *station) BuildReadRequest(deviceName string, offset, numPoints int64) string {
+	return h.frameString(h.AppendReadRequest(nil, deviceName, offset, numPoints))
+}
 
-	// get device symbol hex layout
-	deviceCode := deviceCodes[deviceName]
-
-	// offset convert to little endian layout
-	// MELSECコミュニケーションプロトコル リファレンス(p67) MELSEC-Q/L: 3[byte], MELSEC iQ-R: 4[byte]
-	offsetBuff := new(bytes.Buffer)
-	_ = binary.Write(offsetBuff, binary.LittleEndian, offset)
-	offsetHex := fmt.Sprintf("%X", offsetBuff.Bytes()[0:3]) // 仮にQシリーズとするので3byte trim
-
-	// read points
-	pointsBuff := new(bytes.Buffer)
-	_ = binary.Write(pointsBuff, binary.LittleEndian, numPoints)
-	points := fmt.Sprintf("%X", pointsBuff.Bytes()[0:2]) // 2byte固定
-
-	// data length
-	requestCharLen := len(MONITORING_TIMER+READ_COMMAND+READ_SUB_COMMAND+deviceCode+offsetHex+points) / 2 // 1byte=2char
-	dataLenBuff := new(bytes.Buffer)
-	_ = binary.Write(dataLenBuff, binary.LittleEndian, int64(requestCharLen))
-	dataLen := fmt.Sprintf("%X", dataLenBuff.Bytes()[0:2]) // 2byte固定
-
-	return SUB_HEADER +
-		h.networkNum +
-		h.pcNum +
-		h.unitIONum +
-		h.unitStationNum +
-		dataLen +
-		MONITORING_TIMER +
-		READ_COMMAND +
-		READ_SUB_COMMAND +
-		offsetHex +
-		deviceCode +
-		points
-}
-
-// BuildReadRequest represents MCP read as bit command.
-// deviceName is device code name like 'D' register.
-// offset is device offset addr.
-// numPoints is number of read device points.
-func (h *station) BuildBitReadRequest(deviceName string, offset, numPoints int64) string {
+// AppendReadRequest appends the BuildReadRequest frame to dst in wire format.
+func (h *station) AppendReadRequest(dst []byte, deviceName string, offset, numPoints int64) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, READ_COMMAND)
+	dst = h.appendSubCommand(dst, READ_SUB_COMMAND)
+	dst = h.appendDeviceSpec(dst, deviceName, offset)
+	dst = h.appendUint(dst, uint64(numPoints), 2) // 2byte固定
+	return h.endFrame(dst, mark)
+}
 
-	// get device symbol hex layout
-	deviceCode := deviceCodes[deviceName]
-
-	// offset convert to little endian layout
-	// MELSECコミュニケーションプロトコル リファレンス(p67) MELSEC-Q/L: 3[byte], MELSEC iQ-R: 4[byte]
-	offsetBuff := new(bytes.Buffer)
-	_ = binary.Write(offsetBuff, binary.LittleEndian, offset)
-	offsetHex := fmt.Sprintf("%X", offsetBuff.Bytes()[0:3]) // 仮にQシリーズとするので3byte trim
-
-	// read points
-	pointsBuff := new(bytes.Buffer)
-	_ = binary.Write(pointsBuff, binary.LittleEndian, numPoints)
-	points := fmt.Sprintf("%X", pointsBuff.Bytes()[0:2]) // 2byte固定
-
-	// data length
-	requestCharLen := len(MONITORING_TIMER+READ_COMMAND+BIT_READ_SUB_COMMAND+deviceCode+offsetHex+points) / 2 // 1byte=2char
-	dataLenBuff := new(bytes.Buffer)
-	_ = binary.Write(dataLenBuff, binary.LittleEndian, int64(requestCharLen))
-	dataLen := fmt.Sprintf("%X", dataLenBuff.Bytes()[0:2]) // 2byte固定
-
-	return SUB_HEADER +
-		h.networkNum +
-		h.pcNum +
-		h.unitIONum +
-		h.unitStationNum +
-		dataLen +
-		MONITORING_TIMER +
-		READ_COMMAND +
-		BIT_READ_SUB_COMMAND +
-		offsetHex +
-		deviceCode +
-		points
-}
-
-// BuildReadRequest represents MCP read as word command for FX CPU series.
-// deviceName is device code name like 'D' register.
+// BuildBitReadRequest represents MCP read as bit command.
+// deviceName is device code name like 'M' relay.
 // offset is device offset addr.
 // numPoints is number of read device points.
-func (h *station) BuildReadRequestFx(deviceName string, offset, numPoints int64) string {
-	//NumberPoints 3 is bit device, else word device
-	SUB_HEADER_FX := SUB_HEADER_FX16
-	if numPoints == 6 {
-		SUB_HEADER_FX = SUB_HEADER_FX01
-		numPoints = 1
-	}
-
-	// get device symbol hex layout
-	deviceCode := deviceCodesFx[deviceName]
-
-	// offset convert to little endian layout
-	// MELSECコミュニケーションプロトコル リファレンス(p384) MELSEC-F: 4[byte]
-	offsetBuff := new(bytes.Buffer)
-	_ = binary.Write(offsetBuff, binary.LittleEndian, offset)
-	offsetHex := fmt.Sprintf("%X", offsetBuff.Bytes()[0:4]) // 仮にQシリーズとするので3byte trim
-
-	// read points
-	pointsBuff := new(bytes.Buffer)
-	_ = binary.Write(pointsBuff, binary.LittleEndian, numPoints)
-	points := fmt.Sprintf("%X", pointsBuff.Bytes()[0:2]) // 2byte固定
+// Response data holds 2 points per byte (upper nibble first) in binary, 1 character per point in ascii.
+func (h *station) BuildBitReadRequest(deviceName string, offset, numPoints int64) string {
+	return h.frameString(h.AppendBitReadRequest(nil, deviceName, offset, numPoints))
+}
 
-	// 1Eフレームの交信伝文フォーマット
-	// ヘッダ|  サブヘッダ|  PC番号|  ACPU監視タイマ|  要求データ
-	return SUB_HEADER_FX +
-		h.pcNum +
-		MONITORING_TIMER +
-		offsetHex +
-		deviceCode +
-		points
+// AppendBitReadRequest appends the BuildBitReadRequest frame to dst in wire format.
+func (h *station) AppendBitReadRequest(dst []byte, deviceName string, offset, numPoints int64) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, READ_COMMAND)
+	dst = h.appendSubCommand(dst, BIT_READ_SUB_COMMAND)
+	dst = h.appendDeviceSpec(dst, deviceName, offset)
+	dst = h.appendUint(dst, uint64(numPoints), 2) // 2byte固定
+	return h.endFrame(dst, mark)
 }
 
 // BuildWriteRequest represents MCP write command.
@@ -231,46 +413,160 @@ func (h *station) BuildReadRequestFx(deviceName string, offset, numPoints int64)
 // writeData is the data to be written. If writeData is larger than 2*numPoints bytes,
 // data larger than 2*numPoints bytes is ignored.
 func (h *station) BuildWriteRequest(deviceName string, offset, numPoints int64, writeData []byte) string {
+	return h.frameString(h.AppendWriteRequest(nil, deviceName, offset, numPoints, writeData))
+}
+
+// AppendWriteRequest appends the BuildWriteRequest frame to dst in wire format.
+func (h *station) AppendWriteRequest(dst []byte, deviceName string, offset, numPoints int64, writeData []byte) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, WRITE_COMMAND)
+	dst = h.appendSubCommand(dst, WRITE_SUB_COMMAND)
+	dst = h.appendDeviceSpec(dst, deviceName, offset)
+	dst = h.appendUint(dst, uint64(numPoints), 2)         // 2byte固定
+	dst = h.appendWordData(dst, writeData[0:2*numPoints]) // 2 byte per 1 device point
+	return h.endFrame(dst, mark)
+}
+
+// BuildBitWriteRequest represents MCP write command in bit units.
+// deviceName is device code name like 'M' relay.
+// offset is device offset addr.
+// values are the states of consecutive devices from offset; true sets (ON), false resets (OFF).
+func (h *station) BuildBitWriteRequest(deviceName string, offset int64, values []bool) string {
+	return h.frameString(h.AppendBitWriteRequest(nil, deviceName, offset, values))
+}
 
-	// get device symbol hex layout
-	deviceCode := deviceCodes[deviceName]
-
-	// offset convert to little endian layout
-	// MELSECコミュニケーションプロトコル リファレンス(p67) MELSEC-Q/L: 3[byte], MELSEC iQ-R: 4[byte]
-	offsetBuff := new(bytes.Buffer)
-	_ = binary.Write(offsetBuff, binary.LittleEndian, offset)
-	offsetHex := fmt.Sprintf("%X", offsetBuff.Bytes()[0:3]) // 仮にQシリーズとするので3byte trim
-
-	// convert write data to little endian word
-	writeBuff := new(bytes.Buffer)
-	_ = binary.Write(writeBuff, binary.LittleEndian, writeData)
-	writeHex := fmt.Sprintf("%X", writeBuff.Bytes()[0:2*numPoints]) // 2 byte per 1 device point
-
-	// write points
-	pointsBuff := new(bytes.Buffer)
-	_ = binary.Write(pointsBuff, binary.LittleEndian, numPoints)
-	points := fmt.Sprintf("%X", pointsBuff.Bytes()[0:2]) // 2byte固定
-
-	// data length
-	requestCharLen := len(MONITORING_TIMER+WRITE_COMMAND+WRITE_SUB_COMMAND+deviceCode+offsetHex+points+writeHex) / 2 // 1byte=2char
-	dataLenBuff := new(bytes.Buffer)
-	_ = binary.Write(dataLenBuff, binary.LittleEndian, int64(requestCharLen))
-	dataLen := fmt.Sprintf("%X", dataLenBuff.Bytes()[0:2]) // 2byte固定
-	return SUB_HEADER +
-		h.networkNum +
-		h.pcNum +
-		h.unitIONum +
-		h.unitStationNum +
-		dataLen +
-		MONITORING_TIMER +
-		WRITE_COMMAND +
-		WRITE_SUB_COMMAND +
-		offsetHex +
-		deviceCode +
-		points +
-		writeHex
-}
-
-func (h *station) BuildAccessPath() {
+// AppendBitWriteRequest appends the BuildBitWriteRequest frame to dst in wire format.
+func (h *station) AppendBitWriteRequest(dst []byte, deviceName string, offset int64, values []bool) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, WRITE_COMMAND)
+	dst = h.appendSubCommand(dst, BIT_WRITE_SUB_COMMAND)
+	dst = h.appendDeviceSpec(dst, deviceName, offset)
+	dst = h.appendUint(dst, uint64(len(values)), 2) // 2byte固定
+	dst = h.appendBitData(dst, values)
+	return h.endFrame(dst, mark)
+}
+
+// BuildRandomReadRequest represents MCP random read command.
+// words are read as one word (16 bit) each, dwords as one double word (32 bit) each.
+// Response data holds the word values first, then the double word values, in request order.
+func (h *station) BuildRandomReadRequest(words, dwords []Device) string {
+	return h.frameString(h.AppendRandomReadRequest(nil, words, dwords))
+}
+
+// AppendRandomReadRequest appends the BuildRandomReadRequest frame to dst in wire format.
+func (h *station) AppendRandomReadRequest(dst []byte, words, dwords []Device) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, RANDOM_READ_COMMAND)
+	dst = h.appendSubCommand(dst, RANDOM_READ_SUB_COMMAND)
+	dst = h.appendUint(dst, uint64(len(words)), 1)
+	dst = h.appendUint(dst, uint64(len(dwords)), 1)
+	for _, d := range words {
+		dst = h.appendDeviceSpec(dst, d.Name, d.Offset)
+	}
+	for _, d := range dwords {
+		dst = h.appendDeviceSpec(dst, d.Name, d.Offset)
+	}
+	return h.endFrame(dst, mark)
+}
+
+// BuildRandomWriteRequest represents MCP random write command in word units.
+// words are written as one word (16 bit) each, dwords as one double word (32 bit) each.
+func (h *station) BuildRandomWriteRequest(words, dwords []DeviceValue) string {
+	return h.frameString(h.AppendRandomWriteRequest(nil, words, dwords))
+}
+
+// AppendRandomWriteRequest appends the BuildRandomWriteRequest frame to dst in wire format.
+func (h *station) AppendRandomWriteRequest(dst []byte, words, dwords []DeviceValue) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, RANDOM_WRITE_COMMAND)
+	dst = h.appendSubCommand(dst, RANDOM_WRITE_SUB_COMMAND)
+	dst = h.appendUint(dst, uint64(len(words)), 1)
+	dst = h.appendUint(dst, uint64(len(dwords)), 1)
+	for _, d := range words {
+		dst = h.appendUint(h.appendDeviceSpec(dst, d.Name, d.Offset), uint64(d.Value), 2)
+	}
+	for _, d := range dwords {
+		dst = h.appendUint(h.appendDeviceSpec(dst, d.Name, d.Offset), uint64(d.Value), 4)
+	}
+	return h.endFrame(dst, mark)
+}
+
+// BuildRandomBitWriteRequest represents MCP random write command in bit units.
+// Each device is set ON when its Value is non-zero and OFF otherwise.
+// ON/OFF is 1 byte on MELSEC-Q/L and 2 byte on MELSEC iQ-R.
+func (h *station) BuildRandomBitWriteRequest(bits []DeviceValue) string {
+	return h.frameString(h.AppendRandomBitWriteRequest(nil, bits))
+}
+
+// AppendRandomBitWriteRequest appends the BuildRandomBitWriteRequest frame to dst in wire format.
+func (h *station) AppendRandomBitWriteRequest(dst []byte, bits []DeviceValue) []byte {
+	onOffSize := 1
+	if h.series == IQRSeries {
+		onOffSize = 2
+	}
+
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, RANDOM_WRITE_COMMAND)
+	dst = h.appendSubCommand(dst, RANDOM_WRITE_BIT_SUB_COMMAND)
+	dst = h.appendUint(dst, uint64(len(bits)), 1)
+	for _, d := range bits {
+		onOff := uint64(0)
+		if d.Value != 0 {
+			onOff = 1
+		}
+		dst = h.appendUint(h.appendDeviceSpec(dst, d.Name, d.Offset), onOff, onOffSize)
+	}
+	return h.endFrame(dst, mark)
+}
+
+// BuildMultiBlockReadRequest represents MCP multiple block batch read command.
+// wordBlocks are ranges of word devices, bitBlocks ranges of bit devices read in word units (16 point per word).
+// Response data holds every word block, then every bit block, in request order.
+func (h *station) BuildMultiBlockReadRequest(wordBlocks, bitBlocks []Block) string {
+	return h.frameString(h.AppendMultiBlockReadRequest(nil, wordBlocks, bitBlocks))
+}
+
+// AppendMultiBlockReadRequest appends the BuildMultiBlockReadRequest frame to dst in wire format.
+func (h *station) AppendMultiBlockReadRequest(dst []byte, wordBlocks, bitBlocks []Block) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, MULTI_BLOCK_READ_COMMAND)
+	dst = h.appendSubCommand(dst, MULTI_BLOCK_SUB_COMMAND)
+	dst = h.appendUint(dst, uint64(len(wordBlocks)), 1)
+	dst = h.appendUint(dst, uint64(len(bitBlocks)), 1)
+	for _, b := range wordBlocks {
+		dst = h.appendUint(h.appendDeviceSpec(dst, b.Name, b.Offset), uint64(b.Points), 2)
+	}
+	for _, b := range bitBlocks {
+		dst = h.appendUint(h.appendDeviceSpec(dst, b.Name, b.Offset), uint64(b.Points), 2)
+	}
+	return h.endFrame(dst, mark)
+}
+
+// BuildMultiBlockWriteRequest represents MCP multiple block batch write command.
+// Each block writes its Data (2 byte per point, lower byte first); bit blocks are written in word units.
+// Data larger than 2*Points bytes is ignored.
+func (h *station) BuildMultiBlockWriteRequest(wordBlocks, bitBlocks []Block) string {
+	return h.frameString(h.AppendMultiBlockWriteRequest(nil, wordBlocks, bitBlocks))
+}
+
+// AppendMultiBlockWriteRequest appends the BuildMultiBlockWriteRequest frame to dst in wire format.
+func (h *station) AppendMultiBlockWriteRequest(dst []byte, wordBlocks, bitBlocks []Block) []byte {
+	dst, mark := h.beginFrame(dst)
+	dst = h.appendField(dst, MULTI_BLOCK_WRITE_COMMAND)
+	dst = h.appendSubCommand(dst, MULTI_BLOCK_SUB_COMMAND)
+	dst = h.appendUint(dst, uint64(len(wordBlocks)), 1)
+	dst = h.appendUint(dst, uint64(len(bitBlocks)), 1)
+	for _, blocks := range [][]Block{wordBlocks, bitBlocks} {
+		for _, b := range blocks {
+			dst = h.appendDeviceSpec(dst, b.Name, b.Offset)
+			dst = h.appendUint(dst, uint64(b.Points), 2)
+			dst = h.appendWordData(dst, b.Data[0:2*b.Points])
+		}
+	}
+	return h.endFrame(dst, mark)
+}
 
+// BuildAccessPath renders the access route (network, pc, module i/o, module station) in the station's code.
+func (h *station) BuildAccessPath() string {
+	return h.frameString(h.appendAccessPath(nil))
 }
@@ -0,0 +1,213 @@
+package mcp
+
+import "fmt"
+
+// 1Eフレームのサブヘッダ（コマンド）。応答のサブヘッダは要求のサブヘッダ + 80H
+// MELSEC-F/A互換1Eフレーム: FX3U-ENET, FX3U-ENET-ADP
+const (
+	FX_BIT_READ_COMMAND    = "00" // 一括読出し ビット単位
+	FX_READ_COMMAND        = "01" // 一括読出し ワード単位
+	FX_BIT_WRITE_COMMAND   = "02" // 一括書込み ビット単位
+	FX_WRITE_COMMAND       = "03" // 一括書込み ワード単位
+	FX_BIT_TEST_COMMAND    = "04" // テスト(ランダム書込み) ビット単位
+	FX_TEST_COMMAND        = "05" // テスト(ランダム書込み) ワード単位
+	FX_REMOTE_RUN_COMMAND  = "13" // リモートRUN
+	FX_REMOTE_STOP_COMMAND = "14" // リモートSTOP
+	FX_LOOPBACK_COMMAND    = "16" // ループバックテスト
+
+	MAX_BIT_READ_POINTS_FX  = 256 // batch read in bit units
+	MAX_BIT_WRITE_POINTS_FX = 160 // batch write in bit units
+	MAX_WORD_POINTS_FX      = 256 // batch read/write in word units
+	MAX_TEST_BIT_POINTS_FX  = 80  // test (random write) in bit units
+	MAX_TEST_WORD_POINTS_FX = 40  // test (random write) in word units
+)
+
+// deviceInfoFx is the 1E frame specification of a device.
+type deviceInfoFx struct {
+	// device code: 2 ascii characters of the device name, space padded ("D " = 4420H).
+	// binary sends it lower byte first, ascii as 4 hex characters
+	code uint16
+	// bit device. every other device is a word device
+	bit bool
+}
+
+// devicesFx is device name and 1E frame specification map.
+// FX3U-ENET ユーザーズマニュアル デバイスコード一覧. X and Y numbers are octal on FX CPUs:
+// the device number in the frame is the octal number converted, X20 is device number 16.
+// F, B and W are devices of A series CPUs reached through the A compatible 1E frame.
+var devicesFx = map[string]deviceInfoFx{
+	"X":  {code: 0x5820, bit: true},
+	"Y":  {code: 0x5920, bit: true},
+	"M":  {code: 0x4D20, bit: true},
+	"L":  {code: 0x4D20, bit: true}, // Aシリーズ ラッチリレーはMと同じデバイスコード
+	"S":  {code: 0x5320, bit: true}, // ステートリレー
+	"F":  {code: 0x4620, bit: true},
+	"B":  {code: 0x4220, bit: true},
+	"TS": {code: 0x5453, bit: true}, // タイマ 接点
+	"CS": {code: 0x4353, bit: true}, // カウンタ 接点
+	"TN": {code: 0x544E},            // タイマ 現在値
+	"CN": {code: 0x434E},            // カウンタ 現在値
+	"T":  {code: 0x544E},            // TN
+	"C":  {code: 0x434E},            // CN
+	"D":  {code: 0x4420},
+	"R":  {code: 0x5220}, // 拡張レジスタ
+	"W":  {code: 0x5720},
+}
+
+// IsOctalDeviceFx reports whether the device number of deviceName is octal on FX CPUs, like X and Y.
+func IsOctalDeviceFx(deviceName string) bool {
+	return deviceName == "X" || deviceName == "Y"
+}
+
+// appendFrameFx appends the 1E header (sub header, PC number, monitoring timer) in front of the request data.
+// 1Eフレームの交信伝文フォーマット
+// サブヘッダ|  PC番号|  ACPU監視タイマ|  要求データ
+func (h *station) appendFrameFx(dst []byte, command string) []byte {
+	dst = h.appendField(dst, command)
+	dst = h.appendField(dst, h.pcNum)
+	return h.appendField(dst, MONITORING_TIMER)
+}
+
+// appendDeviceSpecFx appends head device number and device code.
+// MELSECコミュニケーションプロトコル リファレンス(p384) MELSEC-F: 4[byte]
+// binary: device number(4byte) + device code(2byte), ascii: device code(4char) + device number(8char)
+func (h *station) appendDeviceSpecFx(dst []byte, deviceName string, offset int64) []byte {
+	code := uint64(devicesFx[deviceName].code)
+	if h.code == Ascii {
+		return h.appendUint(h.appendUint(dst, code, 2), uint64(offset), 4)
+	}
+	return h.appendUint(h.appendUint(dst, uint64(offset), 4), code, 2)
+}
+
+// appendPointsFx appends the number of points (1byte, 256 points is 00H) and the fixed 00H following it.
+func (h *station) appendPointsFx(dst []byte, numPoints int64) []byte {
+	dst = h.appendUint(dst, uint64(numPoints&0xFF), 1)
+	return h.appendUint(dst, 0, 1) // 固定値00H
+}
+
+// BuildReadRequestFx represents MCP read as word command for FX CPU series.
+// deviceName is device code name like 'D' register.
+// offset is device offset addr.
+// numPoints is number of read device points.
+func (h *station) BuildReadRequestFx(deviceName string, offset, numPoints int64) string {
+	return h.frameString(h.AppendReadRequestFx(nil, deviceName, offset, numPoints))
+}
+
+// AppendReadRequestFx appends the BuildReadRequestFx frame to dst in wire format.
+func (h *station) AppendReadRequestFx(dst []byte, deviceName string, offset, numPoints int64) []byte {
+	dst = h.appendFrameFx(dst, FX_READ_COMMAND)
+	dst = h.appendDeviceSpecFx(dst, deviceName, offset)
+	return h.appendPointsFx(dst, numPoints)
+}
+
+// BuildBitReadRequestFx represents MCP read as bit command for FX CPU series.
+// Response data holds 2 points per byte (upper nibble first) in binary, 1 character per point in ascii.
+func (h *station) BuildBitReadRequestFx(deviceName string, offset, numPoints int64) string {
+	return h.frameString(h.AppendBitReadRequestFx(nil, deviceName, offset, numPoints))
+}
+
+// AppendBitReadRequestFx appends the BuildBitReadRequestFx frame to dst in wire format.
+func (h *station) AppendBitReadRequestFx(dst []byte, deviceName string, offset, numPoints int64) []byte {
+	dst = h.appendFrameFx(dst, FX_BIT_READ_COMMAND)
+	dst = h.appendDeviceSpecFx(dst, deviceName, offset)
+	return h.appendPointsFx(dst, numPoints)
+}
+
+// BuildWriteRequestFx represents MCP write command for FX CPU series.
+// writeData holds 2 byte per point (lower byte first); data beyond 2*numPoints bytes is ignored.
+func (h *station) BuildWriteRequestFx(deviceName string, offset, numPoints int64, writeData []byte) string {
+	return h.frameString(h.AppendWriteRequestFx(nil, deviceName, offset, numPoints, writeData))
+}
+
+// AppendWriteRequestFx appends the BuildWriteRequestFx frame to dst in wire format.
+func (h *station) AppendWriteRequestFx(dst []byte, deviceName string, offset, numPoints int64, writeData []byte) []byte {
+	dst = h.appendFrameFx(dst, FX_WRITE_COMMAND)
+	dst = h.appendDeviceSpecFx(dst, deviceName, offset)
+	dst = h.appendPointsFx(dst, numPoints)
+	return h.appendWordData(dst, writeData[0:2*numPoints])
+}
+
+// BuildBitWriteRequestFx represents MCP write command in bit units for FX CPU series.
+// values are the states of consecutive devices from offset; true sets (ON), false resets (OFF).
+func (h *station) BuildBitWriteRequestFx(deviceName string, offset int64, values []bool) string {
+	return h.frameString(h.AppendBitWriteRequestFx(nil, deviceName, offset, values))
+}
+
+// AppendBitWriteRequestFx appends the BuildBitWriteRequestFx frame to dst in wire format.
+func (h *station) AppendBitWriteRequestFx(dst []byte, deviceName string, offset int64, values []bool) []byte {
+	dst = h.appendFrameFx(dst, FX_BIT_WRITE_COMMAND)
+	dst = h.appendDeviceSpecFx(dst, deviceName, offset)
+	dst = h.appendPointsFx(dst, int64(len(values)))
+	return h.appendBitData(dst, values)
+}
+
+// BuildTestRequestFx represents MCP test (random write) command in word units for FX CPU series.
+// The lower 16 bits of each Value are written.
+func (h *station) BuildTestRequestFx(words []DeviceValue) string {
+	return h.frameString(h.AppendTestRequestFx(nil, words))
+}
+
+// AppendTestRequestFx appends the BuildTestRequestFx frame to dst in wire format.
+func (h *station) AppendTestRequestFx(dst []byte, words []DeviceValue) []byte {
+	dst = h.appendFrameFx(dst, FX_TEST_COMMAND)
+	dst = h.appendPointsFx(dst, int64(len(words)))
+	for _, d := range words {
+		dst = h.appendUint(h.appendDeviceSpecFx(dst, d.Name, d.Offset), uint64(d.Value&0xFFFF), 2)
+	}
+	return dst
+}
+
+// BuildBitTestRequestFx represents MCP test (random write) command in bit units for FX CPU series.
+// Each device is set ON when its Value is non-zero and OFF otherwise.
+func (h *station) BuildBitTestRequestFx(bits []DeviceValue) string {
+	return h.frameString(h.AppendBitTestRequestFx(nil, bits))
+}
+
+// AppendBitTestRequestFx appends the BuildBitTestRequestFx frame to dst in wire format.
+func (h *station) AppendBitTestRequestFx(dst []byte, bits []DeviceValue) []byte {
+	dst = h.appendFrameFx(dst, FX_BIT_TEST_COMMAND)
+	dst = h.appendPointsFx(dst, int64(len(bits)))
+	for _, d := range bits {
+		onOff := uint64(0)
+		if d.Value != 0 {
+			onOff = 1
+		}
+		dst = h.appendUint(h.appendDeviceSpecFx(dst, d.Name, d.Offset), onOff, 1)
+	}
+	return dst
+}
+
+// BuildRemoteRequestFx represents MCP remote RUN/STOP command for FX CPU series.
+// 1E frames have no remote PAUSE, latch clear or reset.
+func (h *station) BuildRemoteRequestFx(op RemoteOp) (string, error) {
+	request, err := h.AppendRemoteRequestFx(nil, op)
+	if err != nil {
+		return "", err
+	}
+	return h.frameString(request), nil
+}
+
+// AppendRemoteRequestFx appends the BuildRemoteRequestFx frame to dst in wire format.
+func (h *station) AppendRemoteRequestFx(dst []byte, op RemoteOp) ([]byte, error) {
+	switch op {
+	case RemoteRun:
+		return h.appendFrameFx(dst, FX_REMOTE_RUN_COMMAND), nil
+	case RemoteStop:
+		return h.appendFrameFx(dst, FX_REMOTE_STOP_COMMAND), nil
+	default:
+		return dst, fmt.Errorf("%w: remote %v", ErrUnsupported1E, op)
+	}
+}
+
+// BuildHealthCheckRequestFx represents MCP loopback test command for FX CPU series.
+// The PLC echoes the number of bytes (1byte) and "ABCDE".
+func (h *station) BuildHealthCheckRequestFx() string {
+	return h.frameString(h.AppendHealthCheckRequestFx(nil))
+}
+
+// AppendHealthCheckRequestFx appends the BuildHealthCheckRequestFx frame to dst in wire format.
+func (h *station) AppendHealthCheckRequestFx(dst []byte) []byte {
+	dst = h.appendFrameFx(dst, FX_LOOPBACK_COMMAND)
+	dst = h.appendUint(dst, 5, 1) // 5 byte
+	return append(dst, "ABCDE"...)
+}
@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStation_BuildRequestFx(t *testing.T) {
+	stn := NewLocalStation()
+	cases := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{"word read", stn.BuildReadRequestFx("D", 100, 3), "01FF1000" + "64000000" + "2044" + "0300"},
+		{"256 points", stn.BuildReadRequestFx("D", 0, 256), "01FF1000" + "00000000" + "2044" + "0000"},
+		{"bit read", stn.BuildBitReadRequestFx("X", 16, 5), "00FF1000" + "10000000" + "2058" + "0500"},
+		{"word write", stn.BuildWriteRequestFx("D", 100, 2, []byte{0x34, 0x12, 0x02, 0x00}), "03FF1000" + "64000000" + "2044" + "0200" + "34120200"},
+		{"bit write", stn.BuildBitWriteRequestFx("Y", 16, []bool{true, false, true}), "02FF1000" + "10000000" + "2059" + "0300" + "1010"},
+		{"word test", stn.BuildTestRequestFx([]DeviceValue{{Device{"D", 100}, 0x1234}, {Device{"TN", 2}, 7}}), "05FF1000" + "0200" + "64000000" + "2044" + "3412" + "02000000" + "4E54" + "0700"},
+		{"bit test", stn.BuildBitTestRequestFx([]DeviceValue{{Device{"M", 5}, 1}, {Device{"S", 3}, 0}}), "04FF1000" + "0200" + "05000000" + "204D" + "01" + "03000000" + "2053" + "00"},
+		{"loopback", stn.BuildHealthCheckRequestFx(), "16FF1000" + "05" + "4142434445"},
+	}
+	for _, c := range cases {
+		if c.request != c.expected {
+			t.Errorf("%s: expected %v but actual is %v", c.name, c.expected, c.request)
+		}
+	}
+}
+
+func TestStation_BuildRequestFxAscii(t *testing.T) {
+	stn := NewLocalStation().SetCode(Ascii)
+	cases := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{"word read", stn.BuildReadRequestFx("D", 100, 3), "01FF0010" + "4420" + "00000064" + "0300"},
+		{"word write", stn.BuildWriteRequestFx("D", 100, 2, []byte{0x34, 0x12, 0x02, 0x00}), "03FF0010" + "4420" + "00000064" + "0200" + "12340002"},
+		{"bit write", stn.BuildBitWriteRequestFx("M", 8000, []bool{true, false, true}), "02FF0010" + "4D20" + "00001F40" + "0300" + "101"},
+		{"loopback", stn.BuildHealthCheckRequestFx(), "16FF0010" + "05" + "ABCDE"},
+	}
+	for _, c := range cases {
+		if c.request != c.expected {
+			t.Errorf("%s: expected %v but actual is %v", c.name, c.expected, c.request)
+		}
+	}
+}
+
+func TestStation_BuildRemoteRequestFx(t *testing.T) {
+	stn := NewLocalStation()
+	run, err := stn.BuildRemoteRequestFx(RemoteRun)
+	if err != nil || run != "13FF1000" {
+		t.Fatalf("expected %v but actual is %v (%v)", "13FF1000", run, err)
+	}
+	stop, err := stn.BuildRemoteRequestFx(RemoteStop)
+	if err != nil || stop != "14FF1000" {
+		t.Fatalf("expected %v but actual is %v (%v)", "14FF1000", stop, err)
+	}
+	if _, err := stn.BuildRemoteRequestFx(RemotePause); !errors.Is(err, ErrUnsupported1E) {
+		t.Fatalf("expected ErrUnsupported1E but actual is %v", err)
+	}
+}
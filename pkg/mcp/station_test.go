@@ -1,6 +1,9 @@
 package mcp
 
-import "testing"
+import (
+	"encoding/hex"
+	"testing"
+)
 
 func TestStation_BuildRRequest(t *testing.T) {
 	station := NewLocalStation()
@@ -15,3 +18,227 @@ func TestStation_BuildRRequest(t *testing.T) {
 		t.Fatalf("expected %v but actual is %v", "500000FFFF03000C00100001040000F40100A83200", request2)
 	}
 }
+
+func TestStation_BuildRRequestAscii(t *testing.T) {
+	station := NewLocalStation().SetCode(Ascii)
+
+	request := station.BuildReadRequest("D", 100, 3)
+	if request != "500000FF03FF000018001004010000D*0001000003" {
+		t.Fatalf("expected %v but actual is %v", "500000FF03FF000018001004010000D*0001000003", request)
+	}
+
+	// X is hex-addressed, so its device number is sent as hex digits
+	request2 := station.BuildReadRequest("X", 0x1A0, 1)
+	if request2 != "500000FF03FF000018001004010000X*0001A00001" {
+		t.Fatalf("expected %v but actual is %v", "500000FF03FF000018001004010000X*0001A00001", request2)
+	}
+}
+
+func TestStation_BuildWRequestAscii(t *testing.T) {
+	station := NewLocalStation().SetCode(Ascii)
+
+	request := station.BuildWriteRequest("D", 100, 2, []byte{0x34, 0x12, 0x02, 0x00})
+	if request != "500000FF03FF000020001014010000D*000100000212340002" {
+		t.Fatalf("expected %v but actual is %v", "500000FF03FF000020001014010000D*000100000212340002", request)
+	}
+}
+
+func TestStation_BuildRandomReadRequest(t *testing.T) {
+	words := []Device{{Name: "D", Offset: 100}, {Name: "M", Offset: 64}}
+	dwords := []Device{{Name: "D", Offset: 200}}
+
+	request := NewLocalStation().BuildRandomReadRequest(words, dwords)
+	expected := "500000FFFF030014001000030400000201640000A840000090C80000A8"
+	if request != expected {
+		t.Fatalf("expected %v but actual is %v", expected, request)
+	}
+
+	requestAscii := NewLocalStation().SetCode(Ascii).BuildRandomReadRequest(words, dwords)
+	expectedAscii := "500000FF03FF000028001004030000" + "0201" + "D*000100M*000064D*000200"
+	if requestAscii != expectedAscii {
+		t.Fatalf("expected %v but actual is %v", expectedAscii, requestAscii)
+	}
+}
+
+func TestStation_BuildRandomWriteRequest(t *testing.T) {
+	words := []DeviceValue{{Device: Device{Name: "D", Offset: 100}, Value: 0x1234}}
+	dwords := []DeviceValue{{Device: Device{Name: "D", Offset: 200}, Value: 0x12345678}}
+
+	request := NewLocalStation().BuildRandomWriteRequest(words, dwords)
+	expected := "500000FFFF030016001000021400000101640000A83412C80000A878563412"
+	if request != expected {
+		t.Fatalf("expected %v but actual is %v", expected, request)
+	}
+}
+
+func TestStation_BuildRandomBitWriteRequest(t *testing.T) {
+	bits := []DeviceValue{
+		{Device: Device{Name: "M", Offset: 10}, Value: 1},
+		{Device: Device{Name: "Y", Offset: 0x20}, Value: 0},
+	}
+
+	request := NewLocalStation().BuildRandomBitWriteRequest(bits)
+	expected := "500000FFFF03001100100002140100020A000090012000009D00"
+	if request != expected {
+		t.Fatalf("expected %v but actual is %v", expected, request)
+	}
+}
+
+func TestStation_BuildMultiBlockRequest(t *testing.T) {
+	wordBlocks := []Block{{Device: Device{Name: "D", Offset: 100}, Points: 4}}
+	bitBlocks := []Block{{Device: Device{Name: "M", Offset: 24}, Points: 2}}
+
+	request := NewLocalStation().BuildMultiBlockReadRequest(wordBlocks, bitBlocks)
+	expected := "500000FFFF03001400100006040000" + "0101" + "640000A80400" + "180000900200"
+	if request != expected {
+		t.Fatalf("expected %v but actual is %v", expected, request)
+	}
+
+	wordBlocks[0].Points = 1
+	wordBlocks[0].Data = []byte{0x34, 0x12}
+	bitBlocks[0].Points = 1
+	bitBlocks[0].Data = []byte{0x05, 0x00}
+	request2 := NewLocalStation().BuildMultiBlockWriteRequest(wordBlocks, bitBlocks)
+	expected2 := "500000FFFF03001800100006140000" + "0101" + "640000A801003412" + "1800009001000500"
+	if request2 != expected2 {
+		t.Fatalf("expected %v but actual is %v", expected2, request2)
+	}
+}
+
+func TestStation_BuildBitRequest(t *testing.T) {
+	read := NewLocalStation().BuildBitReadRequest("M", 10, 3)
+	if read != "500000FFFF03000C001000010401000A0000900300" {
+		t.Fatalf("expected %v but actual is %v", "500000FFFF03000C001000010401000A0000900300", read)
+	}
+
+	write := NewLocalStation().BuildBitWriteRequest("M", 10, []bool{true, false, true})
+	if write != "500000FFFF03000E001000011401000A00009003001010" {
+		t.Fatalf("expected %v but actual is %v", "500000FFFF03000E001000011401000A00009003001010", write)
+	}
+
+	writeAscii := NewLocalStation().SetCode(Ascii).BuildBitWriteRequest("M", 10, []bool{true, false, true})
+	if writeAscii != "500000FF03FF00001B001014010001M*0000100003101" {
+		t.Fatalf("expected %v but actual is %v", "500000FF03FF00001B001014010001M*0000100003101", writeAscii)
+	}
+}
+
+func TestStation_BuildRequestIQR(t *testing.T) {
+	read := NewLocalStation().SetSeries(IQRSeries).BuildReadRequest("D", 1000000, 2)
+	expected := "500000FFFF03000E00" + "1000" + "0104" + "0200" + "40420F00A800" + "0200"
+	if read != expected {
+		t.Fatalf("expected %v but actual is %v", expected, read)
+	}
+
+	readAscii := NewLocalStation().SetCode(Ascii).SetSeries(IQRSeries).BuildReadRequest("D", 1000000, 2)
+	expectedAscii := "500000FF03FF00001E" + "0010" + "0401" + "0002" + "D***0001000000" + "0002"
+	if readAscii != expectedAscii {
+		t.Fatalf("expected %v but actual is %v", expectedAscii, readAscii)
+	}
+
+	bits := NewLocalStation().SetSeries(IQRSeries).BuildRandomBitWriteRequest([]DeviceValue{{Device: Device{Name: "M", Offset: 10}, Value: 1}})
+	expectedBits := "500000FFFF03000F00" + "1000" + "0214" + "0300" + "01" + "0A0000009000" + "0100"
+	if bits != expectedBits {
+		t.Fatalf("expected %v but actual is %v", expectedBits, bits)
+	}
+}
+
+func TestStation_DeviceCodes(t *testing.T) {
+	zr := NewLocalStation().BuildReadRequest("ZR", 0x1234, 1)
+	if zr != "500000FFFF03000C00100001040000341200B00100" {
+		t.Fatalf("expected %v but actual is %v", "500000FFFF03000C00100001040000341200B00100", zr)
+	}
+
+	tn := NewLocalStation().SetCode(Ascii).BuildReadRequest("TN", 10, 1)
+	if tn != "500000FF03FF000018001004010000TN0000100001" {
+		t.Fatalf("expected %v but actual is %v", "500000FF03FF000018001004010000TN0000100001", tn)
+	}
+
+	sts := NewLocalStation().SetCode(Ascii).SetSeries(IQRSeries).BuildBitReadRequest("STS", 10, 1)
+	if sts != "500000FF03FF00001E00100401"+"0003"+"STS*0000000010"+"0001" {
+		t.Fatalf("unexpected iQ-R ascii request %v", sts)
+	}
+}
+
+func TestStation_BuildRemoteRequest(t *testing.T) {
+	run, err := NewLocalStation().BuildRemoteRequest(RemoteRun)
+	if err != nil || run != "500000FFFF03000A00100001100000010000"+"00" {
+		t.Fatalf("unexpected remote run request %v (%v)", run, err)
+	}
+
+	stop, err := NewLocalStation().SetCode(Ascii).BuildRemoteRequest(RemoteStop)
+	if err != nil || stop != "500000FF03FF000010001010020000"+"0001" {
+		t.Fatalf("unexpected remote stop request %v (%v)", stop, err)
+	}
+
+	if _, err := NewLocalStation().BuildRemoteRequest(RemoteOp(42)); err == nil {
+		t.Fatalf("expected error for unknown remote operation")
+	}
+}
+
+func TestStation_BuildBufferRequest(t *testing.T) {
+	// U3\G100 2 words: byte address 200 (C8H), 4 byte
+	read := NewLocalStation().BuildBufferReadRequest(3, 100, 2)
+	if read != "500000FFFF03000E0010000106"+"0000"+"C8000000"+"0400"+"0300" {
+		t.Fatalf("unexpected buffer read request %v", read)
+	}
+
+	ascii := NewLocalStation().SetCode(Ascii).BuildBufferReadRequest(3, 100, 2)
+	if ascii != "500000FF03FF00001C0010"+"0601"+"0000"+"000000C8"+"0004"+"0003" {
+		t.Fatalf("unexpected ascii buffer read request %v", ascii)
+	}
+
+	write := NewLocalStation().BuildBufferWriteRequest(0x1F, 10, 1, []byte{0x34, 0x12})
+	if write != "500000FFFF0300100010000116"+"0000"+"14000000"+"0200"+"1F00"+"3412" {
+		t.Fatalf("unexpected buffer write request %v", write)
+	}
+}
+
+func TestStation_AppendUint(t *testing.T) {
+	cases := []struct {
+		code     Code
+		input    uint64
+		expected string
+	}{
+		{code: Binary, input: 0x0401, expected: "0104"},
+		{code: Ascii, input: 0x0401, expected: hex.EncodeToString([]byte("0401"))},
+	}
+
+	for _, v := range cases {
+		actual := NewLocalStation().SetCode(v.code).appendUint(nil, v.input, 2)
+		if hex.EncodeToString(actual) != v.expected {
+			t.Errorf("wrong result: expected is %v but actual is %v", v.expected, hex.EncodeToString(actual))
+		}
+	}
+}
+
+func TestStation_AppendRequest(t *testing.T) {
+	for _, stn := range []*station{NewLocalStation(), NewLocalStation().SetCode(Ascii), NewLocalStation().SetSeries(IQRSeries)} {
+		want := stn.BuildWriteRequest("W", 0x1A0, 2, []byte{0x34, 0x12, 0x78, 0x56})
+		// frames are appended behind what dst already holds
+		frame := stn.AppendWriteRequest([]byte("xx"), "W", 0x1A0, 2, []byte{0x34, 0x12, 0x78, 0x56})
+		if string(frame[:2]) != "xx" || stn.frameString(frame[2:]) != want {
+			t.Fatalf("%v: expected %v but actual is %v", stn.code, want, stn.frameString(frame[2:]))
+		}
+	}
+}
+
+// BenchmarkStation_BuildReadRequest is a batch read request rendered as a hex string by
+// BuildReadRequest and decoded for the wire: what the Build* string API costs over AppendReadRequest.
+func BenchmarkStation_BuildReadRequest(b *testing.B) {
+	stn := NewLocalStation()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := hex.DecodeString(stn.BuildReadRequest("D", 100, 64)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStation_AppendReadRequest(b *testing.B) {
+	stn := NewLocalStation()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = stn.AppendReadRequest(buf[:0], "D", 100, 64)
+	}
+}
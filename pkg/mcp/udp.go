@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// udpResponseTimeout is how long a request sent over UDP waits for its response before
+	// it is sent again. A lost datagram is never delivered, so waiting responseTimeout is pointless.
+	udpResponseTimeout = 1 * time.Second
+	// udpRetransmits is how many times a request is sent again after udpResponseTimeout.
+	udpRetransmits = 2
+
+	// maxDatagramLen is the largest response datagram: the largest header and response data.
+	maxDatagramLen = responseHeaderLen4EAscii + maxResponseDataLen
+)
+
+// New3EUDPClient returns a client that sends 3E frames over UDP, for Ethernet modules with a
+// UDP open setting. UDP takes no connection slot of the module. A request without response is
+// sent again udpRetransmits times, each time from a new socket, so a late response to an
+// earlier attempt is never taken for the response to a later request.
+func New3EUDPClient(host string, port int, stn *station) (Client, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%v", host, port))
+	if err != nil {
+		return nil, err
+	}
+	return &client3E{dialer: newConnector(udpAddr), stn: stn, lock: make(chan struct{}, 1)}, nil
+}
+
+// New1EUDPClient returns a client that sends 1E frames over UDP. See New1EClient and New3EUDPClient.
+func New1EUDPClient(host string, port int, stn *station) (Client, error) {
+	if stn.password != "" {
+		return nil, fmt.Errorf("%w: remote password", ErrUnsupported1E)
+	}
+	c, err := New3EUDPClient(host, port, stn)
+	if err != nil {
+		return nil, err
+	}
+	return &client1E{c.(*client3E)}, nil
+}
+
+// datagramConn reads a UDP connection one datagram at a time and serves Read from the buffered
+// datagram, so readFrame3E and readFrameFx read a response in pieces as they do from TCP.
+// Reading part of a datagram directly from the socket would discard the rest of it.
+type datagramConn struct {
+	net.Conn
+	buf  []byte
+	rest []byte
+}
+
+func newDatagramConn(conn net.Conn) *datagramConn {
+	return &datagramConn{Conn: conn, buf: make([]byte, maxDatagramLen)}
+}
+
+func (d *datagramConn) Read(p []byte) (int, error) {
+	if len(d.rest) == 0 {
+		n, err := d.Conn.Read(d.buf)
+		if err != nil {
+			return 0, err
+		}
+		d.rest = d.buf[:n]
+	}
+	n := copy(p, d.rest)
+	d.rest = d.rest[n:]
+	return n, nil
+}
+
+// Write sends a request datagram. Whatever is left of the previous datagram answers no request and is dropped.
+func (d *datagramConn) Write(p []byte) (int, error) {
+	d.rest = nil
+	return d.Conn.Write(p)
+}
+
+// isTimeout reports whether err is a socket timeout, the only failure a retransmission may fix.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClient3E_UDPRetransmit(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+
+	// the first request is lost; the retransmission is answered with D100 = 0x1234
+	from := make(chan net.Addr, 2)
+	go func() {
+		buf := make([]byte, 1024)
+		for i := 0; i < 2; i++ {
+			_, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			from <- addr
+			if i == 1 {
+				pc.WriteTo([]byte{0xD0, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00, 0x04, 0x00, 0x00, 0x00, 0x34, 0x12}, addr)
+			}
+		}
+	}()
+
+	addr := pc.LocalAddr().(*net.UDPAddr)
+	client, err := New3EUDPClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := client.Read(ctx, "D", 100, 1, false)
+	if err != nil {
+		t.Fatalf("unexpected mcp read err: %v", err)
+	}
+	r, err := NewParser().Do(resp)
+	if err != nil || string(r.Payload) != "\x34\x12" {
+		t.Fatalf("unexpected response %x: %v", resp, err)
+	}
+
+	// the retransmission comes from a new socket, so a late answer to the first request is never read
+	if first, second := <-from, <-from; first.String() == second.String() {
+		t.Fatalf("expected the retransmission from a new port, both came from %v", first)
+	}
+}
+
+func TestClient1E_UDPTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+
+	// never answers
+	addr := pc.LocalAddr().(*net.UDPAddr)
+	client, err := New1EUDPClient(addr.IP.String(), addr.Port, NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	if _, err := client.Ping(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded but actual is %v", err)
+	}
+}
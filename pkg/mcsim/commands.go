@@ -0,0 +1,444 @@
+package mcsim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// 3E/4Eフレームのコマンド (ascii mode expression)
+const (
+	cmdRead        = 0x0401 // 一括読出し
+	cmdWrite       = 0x1401 // 一括書込み
+	cmdRandomRead  = 0x0403 // ランダム読出し
+	cmdRandomWrite = 0x1402 // ランダム書込み
+	cmdBlockRead   = 0x0406 // 複数ブロック一括読出し
+	cmdBlockWrite  = 0x1406 // 複数ブロック一括書込み
+	cmdBufferRead  = 0x0601 // インテリジェント機能ユニット バッファメモリ読出し
+	cmdBufferWrite = 0x1601 // インテリジェント機能ユニット バッファメモリ書込み
+	cmdCPUModel    = 0x0101 // 形名読出し
+	cmdLoopback    = 0x0619 // ループバックテスト
+	cmdUnlock      = 0x1630 // リモートパスワード ロック解除
+	cmdLock        = 0x1631 // リモートパスワード ロック
+
+	// リモートRUN/STOP/PAUSE/ラッチクリア/RESET
+	cmdRemoteRun        = 0x1001
+	cmdRemoteStop       = 0x1002
+	cmdRemotePause      = 0x1003
+	cmdRemoteLatchClear = 0x1005
+	cmdRemoteReset      = 0x1006
+)
+
+// 3E/4E end codes answered by the emulator. MELSECコミュニケーションプロトコル リファレンス エラーコード一覧
+const (
+	EndCodePoints   = 0xC051 // 一括読出し/書込みの点数が範囲外
+	EndCodeCommand  = 0xC059 // コマンド/サブコマンドの指定誤り
+	EndCodeDevice   = 0xC05B // 指定されたデバイスを扱えない
+	EndCodeData     = 0xC061 // 要求データ長が点数と合わない
+	EndCodePassword = 0xC200 // リモートパスワード不一致
+	EndCodeLocked   = 0xC201 // リモートパスワードでロック中
+)
+
+const (
+	maxWordPoints     = 960  // batch read/write in word units
+	maxBitPoints      = 7168 // batch read/write in bit units
+	maxRandomPoints   = 192  // random read/write, words and double words together
+	maxBlockCount     = 120  // multiple block read/write, word and bit blocks together
+	maxRequestDataLen = 8192
+)
+
+// request3E is a decoded 3E/4E request frame up to the sub command.
+type request3E struct {
+	ascii bool
+	fourE bool
+	// serial number of a 4E frame, echoed in the response
+	serial uint64
+	// network, pc, module i/o and module station as on the wire, echoed in the response
+	route   []byte
+	command uint16
+	sub     uint16
+	// data reads the request data following the sub command
+	data *fieldReader
+}
+
+// read3E reads one 3E/4E request frame from r. The data length field frames the request, so
+// request data the emulator cannot decode is still answered; only a broken header is an error.
+func read3E(r io.Reader, ascii bool) (*request3E, error) {
+	f := &fieldReader{r: r, ascii: ascii}
+	q := &request3E{ascii: ascii}
+
+	// サブヘッダ 5000 (3E) / 5400 (4E)
+	subHeader := f.raw(2 * f.unit())
+	switch {
+	case !ascii && subHeader[0] == 0x54 && subHeader[1] == 0x00, ascii && string(subHeader) == "5400":
+		q.fourE = true
+		q.serial = f.uint(2)
+		f.uint(2) // 固定値0000
+	case !ascii && subHeader[0] == 0x50 && subHeader[1] == 0x00, ascii && string(subHeader) == "5000":
+	default:
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, fmt.Errorf("mcsim: unexpected sub header %q", subHeader)
+	}
+	q.route = f.raw(5 * f.unit())
+	dataLen := f.uint(2)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if dataLen > maxRequestDataLen {
+		return nil, fmt.Errorf("mcsim: data length %d too large", dataLen)
+	}
+	body := f.raw(int(dataLen))
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	q.data = &fieldReader{r: bytes.NewReader(body), ascii: ascii}
+	q.data.uint(2) // 監視タイマ
+	q.command = uint16(q.data.uint(2))
+	q.sub = uint16(q.data.uint(2))
+	return q, nil
+}
+
+// response renders the response frame to q. An abnormal end carries the error information
+// (route, command and sub command of the request) instead of data.
+func (q *request3E) response(endCode uint16, data []byte) []byte {
+	w := &fieldWriter{ascii: q.ascii}
+	switch {
+	case q.ascii && q.fourE:
+		w.raw([]byte("D400"))
+	case q.ascii:
+		w.raw([]byte("D000"))
+	case q.fourE:
+		w.raw([]byte{0xD4, 0x00})
+	default:
+		w.raw([]byte{0xD0, 0x00})
+	}
+	if q.fourE {
+		w.uint(q.serial, 2)
+		w.uint(0, 2)
+	}
+	w.raw(q.route)
+
+	if endCode != 0 {
+		info := &fieldWriter{ascii: q.ascii}
+		info.raw(q.route)
+		info.uint(uint64(q.command), 2)
+		info.uint(uint64(q.sub), 2)
+		data = info.b
+	}
+	// 応答データ長は終了コードから数える
+	endCodeLen := 2
+	if q.ascii {
+		endCodeLen = 4
+	}
+	w.uint(uint64(endCodeLen+len(data)), 2)
+	w.uint(uint64(endCode), 2)
+	w.raw(data)
+	return w.b
+}
+
+// iqr reports whether the sub command uses the MELSEC iQ-R device specification (bit 1 set).
+func (q *request3E) iqr() bool {
+	return q.sub&0x02 != 0
+}
+
+// bitUnits reports whether the sub command accesses bit devices in bit units (bit 0 set).
+func (q *request3E) bitUnits() bool {
+	return q.sub&0x01 != 0
+}
+
+// deviceSpec reads a head device: device number and device code in binary, device code and
+// device number (in the radix of the device) in ascii. ok is false for an unknown device code.
+// MELSECコミュニケーションプロトコル リファレンス(p67) MELSEC-Q/L: 3[byte], MELSEC iQ-R: 4[byte]
+func (f *fieldReader) deviceSpec(iqr bool) (d device, offset int64, ok bool) {
+	if !f.ascii {
+		numberSize, codeSize := 3, 1
+		if iqr {
+			numberSize, codeSize = 4, 2
+		}
+		offset = int64(f.uint(numberSize))
+		d, ok = deviceByCode(f.uint(codeSize))
+		return d, offset, ok
+	}
+
+	codeLen, numberLen := 2, 6
+	if iqr {
+		codeLen, numberLen = 4, 10
+	}
+	d, ok = deviceByASCII(string(f.raw(codeLen)))
+	number := f.raw(numberLen)
+	if !ok || f.err != nil {
+		return d, 0, false
+	}
+	base := 10
+	if d.hex {
+		base = 16
+	}
+	offset, err := strconv.ParseInt(string(number), base, 64)
+	if err != nil {
+		f.err = errData
+	}
+	return d, offset, ok
+}
+
+// spec is a device point decoded from a request, with the value written to it, if any.
+type spec struct {
+	d      device
+	offset int64
+	ok     bool
+	value  uint64
+}
+
+// command3E executes q with e.mu held and returns the end code and the response data.
+// Every request is decoded to the end before the memory is touched, so a request answered
+// with an abnormal end writes nothing.
+func (e *Emulator) command3E(q *request3E, s *session) (uint16, []byte) {
+	f := q.data
+	if f.err != nil {
+		return EndCodeData, nil
+	}
+	if e.opts.Password != "" && !s.unlocked && q.command != cmdUnlock && q.command != cmdLock {
+		return EndCodeLocked, nil
+	}
+	if endCode := e.injected(q.command); endCode != 0 {
+		return endCode, nil
+	}
+
+	w := &fieldWriter{ascii: q.ascii}
+	switch q.command {
+	case cmdRead, cmdWrite:
+		if q.sub > 0x0003 {
+			return EndCodeCommand, nil
+		}
+		d, offset, ok := f.deviceSpec(q.iqr())
+		n := int(f.uint(2))
+		var words []uint16
+		var bits []bool
+		if q.command == cmdWrite {
+			if q.bitUnits() {
+				bits = f.bits(n)
+			} else {
+				words = f.words(n)
+			}
+		}
+		switch {
+		case f.err != nil:
+			return EndCodeData, nil
+		case !ok || q.bitUnits() && !d.bit:
+			return EndCodeDevice, nil
+		case n == 0 || q.bitUnits() && n > maxBitPoints || !q.bitUnits() && n > maxWordPoints:
+			return EndCodePoints, nil
+		}
+
+		if q.command == cmdRead {
+			if q.bitUnits() {
+				bits = make([]bool, n)
+				for i := range bits {
+					bits[i] = e.mem.bit(d, offset+int64(i))
+				}
+				w.bits(bits)
+			} else {
+				words = make([]uint16, n)
+				for i := range words {
+					words[i] = e.mem.word(d, offset+int64(i)*wordStep(d))
+				}
+				w.words(words)
+			}
+			return 0, w.b
+		}
+		for i, v := range bits {
+			e.mem.setBit(d, offset+int64(i), v)
+		}
+		for i, v := range words {
+			e.mem.setWord(d, offset+int64(i)*wordStep(d), v)
+		}
+		return 0, nil
+
+	case cmdRandomRead:
+		if q.sub != 0x0000 && q.sub != 0x0002 {
+			return EndCodeCommand, nil
+		}
+		n, m := int(f.uint(1)), int(f.uint(1))
+		specs := make([]spec, n+m)
+		for i := range specs {
+			specs[i].d, specs[i].offset, specs[i].ok = f.deviceSpec(q.iqr())
+		}
+		if endCode := checkSpecs(f, specs, n+m, maxRandomPoints); endCode != 0 {
+			return endCode, nil
+		}
+		for i, sp := range specs {
+			if i < n {
+				w.uint(uint64(e.mem.word(sp.d, sp.offset)), 2)
+			} else {
+				w.uint(uint64(e.mem.dword(sp.d, sp.offset)), 4)
+			}
+		}
+		return 0, w.b
+
+	case cmdRandomWrite:
+		if q.sub > 0x0003 {
+			return EndCodeCommand, nil
+		}
+		if q.bitUnits() {
+			// ON/OFF: MELSEC-Q/L 1byte, MELSEC iQ-R 2byte
+			onOffSize := 1
+			if q.iqr() {
+				onOffSize = 2
+			}
+			specs := make([]spec, f.uint(1))
+			for i := range specs {
+				specs[i].d, specs[i].offset, specs[i].ok = f.deviceSpec(q.iqr())
+				specs[i].value = f.uint(onOffSize)
+				if specs[i].ok && !specs[i].d.bit {
+					specs[i].ok = false
+				}
+			}
+			if endCode := checkSpecs(f, specs, len(specs), maxRandomPoints); endCode != 0 {
+				return endCode, nil
+			}
+			for _, sp := range specs {
+				e.mem.setBit(sp.d, sp.offset, sp.value != 0)
+			}
+			return 0, nil
+		}
+
+		n, m := int(f.uint(1)), int(f.uint(1))
+		specs := make([]spec, n+m)
+		for i := range specs {
+			specs[i].d, specs[i].offset, specs[i].ok = f.deviceSpec(q.iqr())
+			if i < n {
+				specs[i].value = f.uint(2)
+			} else {
+				specs[i].value = f.uint(4)
+			}
+		}
+		if endCode := checkSpecs(f, specs, n+m, maxRandomPoints); endCode != 0 {
+			return endCode, nil
+		}
+		for i, sp := range specs {
+			if i < n {
+				e.mem.setWord(sp.d, sp.offset, uint16(sp.value))
+			} else {
+				e.mem.setDword(sp.d, sp.offset, uint32(sp.value))
+			}
+		}
+		return 0, nil
+
+	case cmdBlockRead, cmdBlockWrite:
+		if q.sub != 0x0000 && q.sub != 0x0002 {
+			return EndCodeCommand, nil
+		}
+		// ワードデバイスのブロック、続いてビットデバイスのブロック。ビットデバイスも16点1ワードで数える
+		n, m := int(f.uint(1)), int(f.uint(1))
+		blocks := make([]spec, n+m)
+		data := make([][]uint16, n+m)
+		for i := range blocks {
+			blocks[i].d, blocks[i].offset, blocks[i].ok = f.deviceSpec(q.iqr())
+			blocks[i].value = f.uint(2)
+			if blocks[i].ok && blocks[i].d.bit != (i >= n) {
+				blocks[i].ok = false
+			}
+			if q.command == cmdBlockWrite {
+				data[i] = f.words(int(blocks[i].value))
+			}
+		}
+		if endCode := checkSpecs(f, blocks, n+m, maxBlockCount); endCode != 0 {
+			return endCode, nil
+		}
+		for i, b := range blocks {
+			for j := int64(0); j < int64(b.value); j++ {
+				offset := b.offset + j*wordStep(b.d)
+				if q.command == cmdBlockRead {
+					w.uint(uint64(e.mem.word(b.d, offset)), 2)
+				} else {
+					e.mem.setWord(b.d, offset, data[i][j])
+				}
+			}
+		}
+		return 0, w.b
+
+	case cmdBufferRead, cmdBufferWrite:
+		// 先頭アドレス(4byte) + バイト数(2byte) + ユニット番号(2byte)。アドレスとバイト数はバイト単位
+		address, size, module := int64(f.uint(4)), int(f.uint(2)), uint16(f.uint(2))
+		var words []uint16
+		if q.command == cmdBufferWrite {
+			words = f.words(size / 2)
+		}
+		switch {
+		case f.err != nil:
+			return EndCodeData, nil
+		case address%2 != 0 || size%2 != 0 || size == 0 || size > 2*maxWordPoints:
+			return EndCodePoints, nil
+		}
+		for i := 0; i < size/2; i++ {
+			at := bufferAddress{module, address/2 + int64(i)}
+			if q.command == cmdBufferRead {
+				w.uint(uint64(e.mem.buffer[at]), 2)
+			} else {
+				e.mem.buffer[at] = words[i]
+			}
+		}
+		return 0, w.b
+
+	case cmdCPUModel:
+		// 形名(16文字、スペース埋め) + 形名コード(2byte)
+		name := []byte(fmt.Sprintf("%-16.16s", e.opts.Model))
+		w.raw(name)
+		w.uint(uint64(e.opts.ModelCode), 2)
+		return 0, w.b
+
+	case cmdLoopback:
+		// 折り返しデータ数(2byte) + 折り返しデータ(ASCII), echoed as is
+		n := f.uint(2)
+		data := f.raw(int(n))
+		if f.err != nil {
+			return EndCodeData, nil
+		}
+		w.uint(n, 2)
+		w.raw(data)
+		return 0, w.b
+
+	case cmdRemoteRun, cmdRemoteStop, cmdRemotePause, cmdRemoteLatchClear, cmdRemoteReset:
+		return 0, nil
+
+	case cmdUnlock, cmdLock:
+		// パスワード文字数(2byte) + パスワード(ASCII)
+		password := string(f.raw(int(f.uint(2))))
+		if f.err != nil {
+			return EndCodeData, nil
+		}
+		if password != e.opts.Password {
+			return EndCodePassword, nil
+		}
+		s.unlocked = q.command == cmdUnlock
+		return 0, nil
+	}
+	return EndCodeCommand, nil
+}
+
+// checkSpecs returns the end code of decoded device points: count points are allowed up to limit.
+func checkSpecs(f *fieldReader, specs []spec, count, limit int) uint16 {
+	if f.err != nil {
+		return EndCodeData
+	}
+	for _, sp := range specs {
+		if !sp.ok {
+			return EndCodeDevice
+		}
+	}
+	if count == 0 || count > limit {
+		return EndCodePoints
+	}
+	return 0
+}
+
+// wordStep is the number of device points in one word of d: 16 for a bit device, 1 otherwise.
+func wordStep(d device) int64 {
+	if d.bit {
+		return 16
+	}
+	return 1
+}
@@ -0,0 +1,303 @@
+// Package mcsim is an MC protocol PLC emulator for development and integration tests.
+// It answers 3E, 4E and 1E frames (binary and ascii) over TCP and UDP from its own device memory,
+// so the gateway runs end to end without a PLC. Faults are injected with SetEndCode and SetDelay.
+//
+//	sim := mcsim.New(mcsim.Options{})
+//	addr, err := sim.Listen("tcp", "127.0.0.1:0")
+//	...
+//	sim.SetWords("D", 100, 1, 2, 3)
+package mcsim
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnyCommand injects an end code into every command, see SetEndCode.
+const AnyCommand = 0xFFFF
+
+// ErrClosed is returned by Serve and ServePacket after Close.
+var ErrClosed = errors.New("mcsim: emulator closed")
+
+// Options configures an Emulator. The zero value emulates a Q03UDVCPU without remote password.
+type Options struct {
+	// Model and ModelCode answer the CPU model read (0101). Empty Model is Q03UDVCPU (0366).
+	Model     string
+	ModelCode uint16
+	// Password locks every connection until the remote password unlock (1630) sends it.
+	// Empty accepts every request.
+	Password string
+	// Logger logs every request and its end code. nil logs nothing.
+	Logger *log.Logger
+}
+
+// Emulator is an emulated PLC. Its methods are safe for concurrent use, also while serving.
+type Emulator struct {
+	opts Options
+
+	// mu guards the device memory and the injected faults
+	mu       sync.Mutex
+	mem      *memory
+	endCodes map[uint16]uint16
+	delay    time.Duration
+
+	// serving guards the listeners and connections closed by Close
+	serving sync.Mutex
+	closers map[io.Closer]struct{}
+	closed  bool
+	conns   sync.WaitGroup
+}
+
+// session is the state of one client: a TCP connection or the UDP peer of a port.
+type session struct {
+	// remote password unlocked
+	unlocked bool
+}
+
+// New returns an emulated PLC with empty device memory. Serve it with Listen, Serve or ServePacket.
+func New(opts Options) *Emulator {
+	if opts.Model == "" {
+		opts.Model, opts.ModelCode = "Q03UDVCPU", 0x0366
+	}
+	return &Emulator{
+		opts:     opts,
+		mem:      newMemory(),
+		endCodes: make(map[uint16]uint16),
+		closers:  make(map[io.Closer]struct{}),
+	}
+}
+
+// SetEndCode makes command answer with endCode instead of executing it; 0 executes it again.
+// command is a 3E/4E command like 0x0401, a 1E command (sub header) like 0x01, or AnyCommand.
+// 1E frames answer the lower byte of endCode; for 5BH the upper byte is the abnormal code.
+func (e *Emulator) SetEndCode(command uint16, endCode uint16) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if endCode == 0 {
+		delete(e.endCodes, command)
+		return
+	}
+	e.endCodes[command] = endCode
+}
+
+// SetDelay delays every response by d, like a PLC busy with its scan.
+func (e *Emulator) SetDelay(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.delay = d
+}
+
+// injected returns the end code injected into command, if any. e.mu must be held.
+func (e *Emulator) injected(command uint16) uint16 {
+	if endCode, ok := e.endCodes[command]; ok {
+		return endCode
+	}
+	return e.endCodes[AnyCommand]
+}
+
+// Listen listens on address ("tcp" or "udp" networks) and serves it in the background until Close.
+// It returns the address listened on, useful with port 0.
+func (e *Emulator) Listen(network, address string) (net.Addr, error) {
+	if strings.HasPrefix(network, "udp") {
+		pc, err := net.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		go e.ServePacket(pc)
+		return pc.LocalAddr(), nil
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	go e.Serve(ln)
+	return ln.Addr(), nil
+}
+
+// Serve answers the requests of every connection accepted on ln until ln fails or Close.
+// Each connection has its own remote password state, like the Ethernet port of a PLC.
+func (e *Emulator) Serve(ln net.Listener) error {
+	if !e.track(ln) {
+		return ErrClosed
+	}
+	defer e.untrack(ln)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if e.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+		if !e.track(conn) {
+			return ErrClosed
+		}
+		e.conns.Add(1)
+		go func() {
+			defer e.conns.Done()
+			defer e.untrack(conn)
+			e.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn answers requests on conn until it is closed or sends a request that cannot be framed.
+func (e *Emulator) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	s := &session{}
+	for {
+		resp, err := e.handle(r, s)
+		if resp != nil {
+			if _, werr := conn.Write(resp); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				e.logf("close %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// ServePacket answers every request datagram arriving on pc until pc fails or Close.
+// The remote password state is kept per peer address.
+func (e *Emulator) ServePacket(pc net.PacketConn) error {
+	if !e.track(pc) {
+		return ErrClosed
+	}
+	defer e.untrack(pc)
+	sessions := make(map[string]*session)
+	buf := make([]byte, 2*maxRequestDataLen)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if e.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+		s, ok := sessions[addr.String()]
+		if !ok {
+			s = &session{}
+			sessions[addr.String()] = s
+		}
+		// 1 datagram is 1 request; a datagram that cannot be framed is dropped
+		resp, err := e.handle(bufio.NewReader(bytes.NewReader(buf[:n])), s)
+		if resp != nil {
+			pc.WriteTo(resp, addr)
+		}
+		if err != nil {
+			e.logf("drop datagram from %v: %v", addr, err)
+		}
+	}
+}
+
+// Close stops serving: every listener, packet connection and accepted connection is closed.
+func (e *Emulator) Close() error {
+	e.serving.Lock()
+	e.closed = true
+	for c := range e.closers {
+		c.Close()
+	}
+	e.serving.Unlock()
+	e.conns.Wait()
+	return nil
+}
+
+func (e *Emulator) track(c io.Closer) bool {
+	e.serving.Lock()
+	defer e.serving.Unlock()
+	if e.closed {
+		c.Close()
+		return false
+	}
+	e.closers[c] = struct{}{}
+	return true
+}
+
+func (e *Emulator) untrack(c io.Closer) {
+	e.serving.Lock()
+	defer e.serving.Unlock()
+	delete(e.closers, c)
+}
+
+func (e *Emulator) isClosed() bool {
+	e.serving.Lock()
+	defer e.serving.Unlock()
+	return e.closed
+}
+
+// handle reads one request frame from r, executes it and returns the response.
+// The frame is told by its first byte: 3E/4E sub header 50H/54H (binary) or "5" (ascii),
+// 1E sub header 00H-16H (binary) or "0"/"1" (ascii). An error means r cannot be read any
+// further; the response, if not nil, is still sent before closing.
+func (e *Emulator) handle(r *bufio.Reader, s *session) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := first[0]; {
+	case c == 0x50 || c == 0x54 || c == '5':
+		q, err := read3E(r, c == '5')
+		if err != nil {
+			return nil, err
+		}
+		e.mu.Lock()
+		endCode, data := e.command3E(q, s)
+		delay := e.delay
+		e.mu.Unlock()
+		frame := "3E"
+		if q.fourE {
+			frame = "4E"
+		}
+		e.logf("%v %v %04X/%04X end code %04X", frame, codeName(q.ascii), q.command, q.sub, endCode)
+		time.Sleep(delay)
+		return q.response(endCode, data), nil
+
+	case c <= fxLoopback || c == '0' || c == '1':
+		ascii := c == '0' || c == '1'
+		command, run, err := read1E(r, ascii)
+		if errors.Is(err, errUnknownFx) {
+			return responseFx(ascii, command, EndCodeCommandFx, nil), err
+		}
+		if err != nil {
+			return nil, err
+		}
+		w := &fieldWriter{ascii: ascii}
+		e.mu.Lock()
+		endCode := e.injected(uint16(command))
+		if endCode == 0 {
+			endCode = uint16(run(e, w))
+		}
+		delay := e.delay
+		e.mu.Unlock()
+		e.logf("1E %v %02X end code %02X", codeName(ascii), command, endCode)
+		time.Sleep(delay)
+		return responseFx(ascii, command, endCode, w.b), nil
+	}
+	return nil, errors.New("mcsim: unknown frame")
+}
+
+func codeName(ascii bool) string {
+	if ascii {
+		return "ascii"
+	}
+	return "binary"
+}
+
+func (e *Emulator) logf(format string, args ...any) {
+	if e.opts.Logger != nil {
+		e.opts.Logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,323 @@
+package mcsim
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+)
+
+// listen starts sim on a local port of network ("tcp" or "udp") and returns host and port.
+func listen(t *testing.T, sim *Emulator, network string) (string, int) {
+	t.Helper()
+	addr, err := sim.Listen(network, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		return udp.IP.String(), udp.Port
+	}
+	tcp := addr.(*net.TCPAddr)
+	return tcp.IP.String(), tcp.Port
+}
+
+func TestEmulator_Clients(t *testing.T) {
+	tests := []struct {
+		name      string
+		network   string
+		newClient func(host string, port int) (mcp.Client, error)
+	}{
+		{"3E binary", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New3EClient(host, port, mcp.NewLocalStation())
+		}},
+		{"3E ascii", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New3EClient(host, port, mcp.NewLocalStation().SetCode(mcp.Ascii))
+		}},
+		{"3E iQ-R binary", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New3EClient(host, port, mcp.NewLocalStation().SetSeries(mcp.IQRSeries))
+		}},
+		{"3E iQ-R ascii", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New3EClient(host, port, mcp.NewLocalStation().SetCode(mcp.Ascii).SetSeries(mcp.IQRSeries))
+		}},
+		{"4E binary", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New4EClient(host, port, mcp.NewLocalStation())
+		}},
+		{"4E ascii", "tcp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New4EClient(host, port, mcp.NewLocalStation().SetCode(mcp.Ascii))
+		}},
+		{"3E udp", "udp", func(host string, port int) (mcp.Client, error) {
+			return mcp.New3EUDPClient(host, port, mcp.NewLocalStation())
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := New(Options{})
+			client, err := tt.newClient(listen(t, sim, tt.network))
+			if err != nil {
+				t.Fatalf("unexpected client err: %v", err)
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			sim.SetWords("D", 100, 0x1234, 0xABCD)
+			resp, err := client.Read(ctx, "D", 100, 2, false)
+			if err != nil {
+				t.Fatalf("unexpected read err: %v", err)
+			}
+			r, err := mcp.NewParser().Do(resp)
+			if err != nil {
+				t.Fatalf("unexpected parse err: %v", err)
+			}
+			if want := []byte{0x34, 0x12, 0xCD, 0xAB}; !reflect.DeepEqual(r.Payload, want) {
+				t.Errorf("read D100 = % X, want % X", r.Payload, want)
+			}
+
+			if _, err := client.Write(ctx, "W", 0x1A0, 2, []byte{0x01, 0x00, 0xFF, 0x7F}); err != nil {
+				t.Fatalf("unexpected write err: %v", err)
+			}
+			if words, _ := sim.Words("W", 0x1A0, 2); !reflect.DeepEqual(words, []uint16{1, 0x7FFF}) {
+				t.Errorf("W1A0 = %X after write", words)
+			}
+
+			if _, err := client.WriteBits(ctx, "M", 10, []bool{true, false, true}); err != nil {
+				t.Fatalf("unexpected bit write err: %v", err)
+			}
+			if bits, _ := sim.Bits("M", 10, 3); !reflect.DeepEqual(bits, []bool{true, false, true}) {
+				t.Errorf("M10 = %v after bit write", bits)
+			}
+			sim.SetBits("X", 0x1F, true)
+			bits, err := client.ReadBits(ctx, "X", 0x1F, 2)
+			if err != nil || !reflect.DeepEqual(bits, []bool{true, false}) {
+				t.Errorf("read X1F = %v (%v)", bits, err)
+			}
+
+			if _, err := client.Ping(ctx); err != nil {
+				t.Errorf("unexpected ping err: %v", err)
+			}
+			model, err := client.ReadCPUModel(ctx)
+			if err != nil || model.Name != "Q03UDVCPU" || model.Code != 0x0366 {
+				t.Errorf("cpu model = %v (%v)", model, err)
+			}
+		})
+	}
+}
+
+func TestEmulator_RandomAndBlocks(t *testing.T) {
+	sim := New(Options{})
+	host, port := listen(t, sim, "tcp")
+	client, err := mcp.New3EClient(host, port, mcp.NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	words := []mcp.DeviceValue{{Device: mcp.Device{Name: "D", Offset: 10}, Value: 5}}
+	dwords := []mcp.DeviceValue{{Device: mcp.Device{Name: "D", Offset: 20}, Value: 0x12345678}}
+	if _, err := client.RandomWrite(ctx, words, dwords); err != nil {
+		t.Fatalf("unexpected random write err: %v", err)
+	}
+	if got, _ := sim.Words("D", 20, 2); !reflect.DeepEqual(got, []uint16{0x5678, 0x1234}) {
+		t.Errorf("D20 = %X, want lower word first", got)
+	}
+	if _, err := client.RandomWriteBits(ctx, []mcp.DeviceValue{{Device: mcp.Device{Name: "M", Offset: 16}, Value: 1}}); err != nil {
+		t.Fatalf("unexpected random bit write err: %v", err)
+	}
+
+	resp, err := client.RandomRead(ctx, []mcp.Device{{Name: "D", Offset: 10}, {Name: "M", Offset: 16}}, []mcp.Device{{Name: "D", Offset: 20}})
+	if err != nil {
+		t.Fatalf("unexpected random read err: %v", err)
+	}
+	r, err := mcp.NewParser().DoRandom(resp, 2, 1)
+	if err != nil {
+		t.Fatalf("unexpected parse err: %v", err)
+	}
+	if want := []byte{0x05, 0x00, 0x01, 0x00, 0x78, 0x56, 0x34, 0x12}; !reflect.DeepEqual(r.Payload, want) {
+		t.Errorf("random read = % X, want % X", r.Payload, want)
+	}
+
+	wordBlocks := []mcp.Block{{Device: mcp.Device{Name: "D", Offset: 300}, Points: 2, Data: []byte{1, 0, 2, 0}}}
+	bitBlocks := []mcp.Block{{Device: mcp.Device{Name: "M", Offset: 32}, Points: 1, Data: []byte{0x03, 0x80}}}
+	if _, err := client.WriteBlocks(ctx, wordBlocks, bitBlocks); err != nil {
+		t.Fatalf("unexpected block write err: %v", err)
+	}
+	if bits, _ := sim.Bits("M", 32, 16); !bits[0] || !bits[1] || bits[2] || !bits[15] {
+		t.Errorf("M32-M47 = %v after block write", bits)
+	}
+	resp, err = client.ReadBlocks(ctx, wordBlocks, bitBlocks)
+	if err != nil {
+		t.Fatalf("unexpected block read err: %v", err)
+	}
+	_, blocks, err := mcp.NewParser().DoBlocks(resp, wordBlocks, bitBlocks)
+	if err != nil {
+		t.Fatalf("unexpected parse err: %v", err)
+	}
+	if !reflect.DeepEqual(blocks, [][]byte{{1, 0, 2, 0}, {0x03, 0x80}}) {
+		t.Errorf("blocks = % X", blocks)
+	}
+}
+
+func TestEmulator_Buffer(t *testing.T) {
+	sim := New(Options{})
+	host, port := listen(t, sim, "tcp")
+	client, err := mcp.New3EClient(host, port, mcp.NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.WriteBuffer(context.Background(), 3, 100, 2, []byte{0x10, 0x00, 0x20, 0x00}); err != nil {
+		t.Fatalf("unexpected buffer write err: %v", err)
+	}
+	if got := sim.Buffer(3, 100, 2); !reflect.DeepEqual(got, []uint16{0x10, 0x20}) {
+		t.Errorf("U3\\G100 = %X after write", got)
+	}
+	resp, err := client.ReadBuffer(context.Background(), 3, 101, 1)
+	if err != nil {
+		t.Fatalf("unexpected buffer read err: %v", err)
+	}
+	if r, err := mcp.NewParser().Do(resp); err != nil || !reflect.DeepEqual(r.Payload, []byte{0x20, 0x00}) {
+		t.Errorf("read U3\\G101 = %v (%v)", r, err)
+	}
+}
+
+func TestEmulator_1E(t *testing.T) {
+	for _, code := range []mcp.Code{mcp.Binary, mcp.Ascii} {
+		t.Run(code.String(), func(t *testing.T) {
+			sim := New(Options{})
+			host, port := listen(t, sim, "tcp")
+			client, err := mcp.New1EClient(host, port, mcp.NewLocalStation().SetCode(code))
+			if err != nil {
+				t.Fatalf("unexpected client err: %v", err)
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			if _, err := client.Write(ctx, "D", 0, 2, []byte{0x0A, 0x00, 0x0B, 0x00}); err != nil {
+				t.Fatalf("unexpected write err: %v", err)
+			}
+			resp, err := client.Read(ctx, "D", 0, 2, true)
+			if err != nil {
+				t.Fatalf("unexpected read err: %v", err)
+			}
+			if r, err := mcp.NewParser().DoFx(resp); err != nil || !reflect.DeepEqual(r.Payload, []byte{0x0A, 0x00, 0x0B, 0x00}) {
+				t.Errorf("read D0 = %v (%v)", r, err)
+			}
+
+			if _, err := client.WriteBits(ctx, "Y", 2, []bool{true, true, false}); err != nil {
+				t.Fatalf("unexpected bit write err: %v", err)
+			}
+			bits, err := client.ReadBits(ctx, "Y", 1, 4)
+			if err != nil || !reflect.DeepEqual(bits, []bool{false, true, true, false}) {
+				t.Errorf("read Y1 = %v (%v)", bits, err)
+			}
+
+			words := []mcp.DeviceValue{{Device: mcp.Device{Name: "D", Offset: 7}, Value: 0x55}}
+			if _, err := client.RandomWrite(ctx, words, nil); err != nil {
+				t.Fatalf("unexpected test command err: %v", err)
+			}
+			if got, _ := sim.Words("D", 7, 1); got[0] != 0x55 {
+				t.Errorf("D7 = %X after test command", got)
+			}
+			if _, err := client.Ping(ctx); err != nil {
+				t.Errorf("unexpected ping err: %v", err)
+			}
+
+			// 1E end code 5B carries the abnormal code in the upper byte
+			sim.SetEndCode(0x01, 0x105B)
+			resp, err = client.Read(ctx, "D", 0, 1, true)
+			if err == nil {
+				_, err = mcp.NewParser().DoFx(resp)
+			}
+			var endCodeErr *mcp.EndCodeError
+			if !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0x5B || endCodeErr.AbnormalCode != 0x10 {
+				t.Fatalf("expected end code 5B (10) but actual is %v", err)
+			}
+		})
+	}
+}
+
+func TestEmulator_EndCode(t *testing.T) {
+	sim := New(Options{})
+	host, port := listen(t, sim, "tcp")
+	client, err := mcp.New3EClient(host, port, mcp.NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	sim.SetEndCode(0x0401, 0xC056)
+	resp, err := client.Read(ctx, "D", 0, 1, false)
+	if err == nil {
+		_, err = mcp.NewParser().Do(resp)
+	}
+	var endCodeErr *mcp.EndCodeError
+	if !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0xC056 {
+		t.Fatalf("expected end code C056 but actual is %v", err)
+	}
+	if endCodeErr.Info == nil || endCodeErr.Info.Command != 0x0401 {
+		t.Errorf("unexpected error information %+v", endCodeErr.Info)
+	}
+	// other commands are not affected
+	if _, err := client.Write(ctx, "D", 0, 1, []byte{1, 0}); err != nil {
+		t.Fatalf("unexpected write err: %v", err)
+	}
+
+	sim.SetEndCode(0x0401, 0)
+	sim.SetEndCode(AnyCommand, 0xC059)
+	if _, err := client.Ping(ctx); !errors.As(err, &endCodeErr) || endCodeErr.EndCode != 0xC059 {
+		t.Fatalf("expected end code C059 for any command but actual is %v", err)
+	}
+	sim.SetEndCode(AnyCommand, 0)
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatalf("unexpected ping err after clearing: %v", err)
+	}
+}
+
+func TestEmulator_Delay(t *testing.T) {
+	sim := New(Options{})
+	host, port := listen(t, sim, "tcp")
+	client, err := mcp.New3EClient(host, port, mcp.NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer client.Close()
+
+	sim.SetDelay(300 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded but actual is %v", err)
+	}
+}
+
+func TestEmulator_RemotePassword(t *testing.T) {
+	sim := New(Options{Password: "ab12"})
+	host, port := listen(t, sim, "tcp")
+
+	locked, err := mcp.New3EClient(host, port, mcp.NewLocalStation())
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer locked.Close()
+	var endCodeErr *mcp.EndCodeError
+	if _, err := locked.Ping(context.Background()); !errors.As(err, &endCodeErr) || endCodeErr.EndCode != EndCodeLocked {
+		t.Fatalf("expected end code C201 but actual is %v", err)
+	}
+
+	unlocked, err := mcp.New3EClient(host, port, mcp.NewLocalStation().SetRemotePassword("ab12"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer unlocked.Close()
+	if _, err := unlocked.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected ping err: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package mcsim
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// errData marks request data the emulator cannot decode: a bad hex character or a request
+// shorter than its fields. 3E/4E frames answer it with end code C061, 1E connections are closed.
+var errData = errors.New("mcsim: malformed request data")
+
+// fieldReader reads request fields in the code of the frame: binary fields lower byte first,
+// ascii fields as hex characters upper byte first. The first error is kept in err and every
+// field read after it is zero, so a request is decoded first and checked once.
+type fieldReader struct {
+	r     io.Reader
+	ascii bool
+	err   error
+}
+
+// raw reads n bytes as they are on the wire.
+func (f *fieldReader) raw(n int) []byte {
+	b := make([]byte, n)
+	if f.err != nil {
+		return b
+	}
+	if _, err := io.ReadFull(f.r, b); err != nil {
+		f.err = err
+	}
+	return b
+}
+
+// uint reads a size byte field: size bytes in binary, 2*size hex characters in ascii.
+func (f *fieldReader) uint(size int) uint64 {
+	if !f.ascii {
+		var v uint64
+		for i, b := range f.raw(size) {
+			v |= uint64(b) << (8 * i)
+		}
+		return v
+	}
+	s := f.raw(2 * size)
+	if f.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseUint(string(s), 16, 64)
+	if err != nil {
+		f.err = errData
+	}
+	return v
+}
+
+// unit is the size of one byte of a field on the wire: 1 byte in binary, 2 characters in ascii.
+func (f *fieldReader) unit() int {
+	if f.ascii {
+		return 2
+	}
+	return 1
+}
+
+// words reads n words.
+func (f *fieldReader) words(n int) []uint16 {
+	words := make([]uint16, n)
+	for i := range words {
+		words[i] = uint16(f.uint(2))
+	}
+	return words
+}
+
+// bits reads the state of n points: 2 points per byte (upper nibble first) in binary,
+// 1 character ("0" or "1") per point in ascii.
+func (f *fieldReader) bits(n int) []bool {
+	bits := make([]bool, n)
+	if f.ascii {
+		for i, c := range f.raw(n) {
+			switch c {
+			case '0':
+			case '1':
+				bits[i] = true
+			default:
+				f.err = errData
+			}
+		}
+		return bits
+	}
+	for i, b := range f.raw((n + 1) / 2) {
+		bits[2*i] = b&0x10 != 0
+		if 2*i+1 < n {
+			bits[2*i+1] = b&0x01 != 0
+		}
+	}
+	return bits
+}
+
+// fieldWriter appends response fields in the code of the frame, the other way round of fieldReader.
+type fieldWriter struct {
+	b     []byte
+	ascii bool
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// uint appends v as a size byte field.
+func (w *fieldWriter) uint(v uint64, size int) {
+	if w.ascii {
+		for i := 2*size - 1; i >= 0; i-- {
+			w.b = append(w.b, hexDigits[v>>(4*i)&0x0F])
+		}
+		return
+	}
+	for i := 0; i < size; i++ {
+		w.b = append(w.b, byte(v>>(8*i)))
+	}
+}
+
+func (w *fieldWriter) raw(b []byte) {
+	w.b = append(w.b, b...)
+}
+
+func (w *fieldWriter) words(words []uint16) {
+	for _, v := range words {
+		w.uint(uint64(v), 2)
+	}
+}
+
+// bits appends the state of points in the layout fieldReader.bits reads.
+func (w *fieldWriter) bits(bits []bool) {
+	if w.ascii {
+		for _, v := range bits {
+			if v {
+				w.b = append(w.b, '1')
+			} else {
+				w.b = append(w.b, '0')
+			}
+		}
+		return
+	}
+	for i := 0; i < len(bits); i += 2 {
+		var b byte
+		if bits[i] {
+			b = 0x10
+		}
+		if i+1 < len(bits) && bits[i+1] {
+			b |= 0x01
+		}
+		w.b = append(w.b, b)
+	}
+}
@@ -0,0 +1,175 @@
+package mcsim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 1Eフレームのサブヘッダ（コマンド）。応答のサブヘッダは要求のサブヘッダ + 80H
+const (
+	fxBitRead    = 0x00 // 一括読出し ビット単位
+	fxRead       = 0x01 // 一括読出し ワード単位
+	fxBitWrite   = 0x02 // 一括書込み ビット単位
+	fxWrite      = 0x03 // 一括書込み ワード単位
+	fxBitTest    = 0x04 // テスト(ランダム書込み) ビット単位
+	fxTest       = 0x05 // テスト(ランダム書込み) ワード単位
+	fxRemoteRun  = 0x13 // リモートRUN
+	fxRemoteStop = 0x14 // リモートSTOP
+	fxLoopback   = 0x16 // ループバックテスト
+)
+
+// 1E end codes answered by the emulator. FX3U-ENET ユーザーズマニュアル 終了コード一覧
+const (
+	EndCodeCommandFx = 0x50 // コマンド/サブヘッダの指定誤り
+	EndCodeDeviceFx  = 0x56 // デバイスの指定誤り
+	// 5BH is followed by the abnormal code, given in the upper byte of an injected end code
+	EndCodeAbnormalFx = 0x5B
+)
+
+// deviceSpecFx reads a 1E head device: device number(4byte) + device code(2byte) in binary,
+// device code(4char) + device number(8char) in ascii. The device code is 2 ascii characters
+// of the device name, space padded ("D " = 4420H).
+func (f *fieldReader) deviceSpecFx() (d device, offset int64, ok bool) {
+	var code uint64
+	if f.ascii {
+		code = f.uint(2)
+		offset = int64(f.uint(4))
+	} else {
+		offset = int64(f.uint(4))
+		code = f.uint(2)
+	}
+	d, ok = deviceByASCII(string([]byte{byte(code >> 8), byte(code)}))
+	return d, offset, ok
+}
+
+// pointsFx reads the number of points (1byte, 00H is 256 points) and the fixed 00H following it.
+func (f *fieldReader) pointsFx() int {
+	n := int(f.uint(1))
+	f.uint(1) // 固定値00H
+	if n == 0 {
+		return 256
+	}
+	return n
+}
+
+// read1E reads one 1E request frame from r and returns the command and a function that executes
+// it with e.mu held. 1E requests carry no length, so the command tells how much to read;
+// an unknown command returns errUnknownFx and the connection cannot be read any further.
+func read1E(r io.Reader, ascii bool) (command byte, run func(e *Emulator, w *fieldWriter) byte, err error) {
+	f := &fieldReader{r: r, ascii: ascii}
+	// サブヘッダ|  PC番号|  ACPU監視タイマ|  要求データ
+	command = byte(f.uint(1))
+	f.uint(1)
+	f.uint(2)
+	if f.err != nil {
+		return command, nil, f.err
+	}
+
+	switch command {
+	case fxBitRead, fxRead, fxBitWrite, fxWrite:
+		d, offset, ok := f.deviceSpecFx()
+		n := f.pointsFx()
+		var bits []bool
+		var words []uint16
+		switch command {
+		case fxBitWrite:
+			bits = f.bits(n)
+		case fxWrite:
+			words = f.words(n)
+		}
+		run = func(e *Emulator, w *fieldWriter) byte {
+			bitUnits := command == fxBitRead || command == fxBitWrite
+			if !ok || bitUnits && !d.bit {
+				return EndCodeDeviceFx
+			}
+			switch command {
+			case fxBitRead:
+				bits := make([]bool, n)
+				for i := range bits {
+					bits[i] = e.mem.bit(d, offset+int64(i))
+				}
+				w.bits(bits)
+			case fxRead:
+				for i := 0; i < n; i++ {
+					w.uint(uint64(e.mem.word(d, offset+int64(i)*wordStep(d))), 2)
+				}
+			case fxBitWrite:
+				for i, v := range bits {
+					e.mem.setBit(d, offset+int64(i), v)
+				}
+			case fxWrite:
+				for i, v := range words {
+					e.mem.setWord(d, offset+int64(i)*wordStep(d), v)
+				}
+			}
+			return 0
+		}
+
+	case fxBitTest, fxTest:
+		// 点数 + (デバイス + 値) x 点数。ビット単位のON/OFFは1byte
+		specs := make([]spec, f.pointsFx())
+		for i := range specs {
+			specs[i].d, specs[i].offset, specs[i].ok = f.deviceSpecFx()
+			if command == fxBitTest {
+				specs[i].value = f.uint(1)
+			} else {
+				specs[i].value = f.uint(2)
+			}
+		}
+		run = func(e *Emulator, w *fieldWriter) byte {
+			for _, sp := range specs {
+				if !sp.ok || command == fxBitTest && !sp.d.bit {
+					return EndCodeDeviceFx
+				}
+			}
+			for _, sp := range specs {
+				if command == fxBitTest {
+					e.mem.setBit(sp.d, sp.offset, sp.value != 0)
+				} else {
+					e.mem.setWord(sp.d, sp.offset, uint16(sp.value))
+				}
+			}
+			return 0
+		}
+
+	case fxRemoteRun, fxRemoteStop:
+		run = func(e *Emulator, w *fieldWriter) byte { return 0 }
+
+	case fxLoopback:
+		// 折り返しデータ数(1byte) + 折り返しデータ(ASCII), echoed as is
+		n := f.uint(1)
+		data := f.raw(int(n))
+		run = func(e *Emulator, w *fieldWriter) byte {
+			w.uint(n, 1)
+			w.raw(data)
+			return 0
+		}
+
+	default:
+		return command, nil, fmt.Errorf("%w %02X", errUnknownFx, command)
+	}
+	if f.err != nil {
+		return command, nil, f.err
+	}
+	return command, run, nil
+}
+
+// errUnknownFx is a 1E command the emulator does not know. It is answered with end code 50H
+// before the connection is closed.
+var errUnknownFx = errors.New("mcsim: unknown 1E command")
+
+// responseFx renders the 1E response to command: sub header + end code + data (normal end)
+// or abnormal code (end code 5BH).
+func responseFx(ascii bool, command byte, endCode uint16, data []byte) []byte {
+	w := &fieldWriter{ascii: ascii}
+	w.uint(uint64(command|0x80), 1)
+	w.uint(uint64(endCode&0xFF), 1)
+	switch {
+	case endCode&0xFF == EndCodeAbnormalFx:
+		w.uint(uint64(endCode>>8), 1)
+	case endCode == 0:
+		w.raw(data)
+	}
+	return w.b
+}
@@ -0,0 +1,222 @@
+package mcsim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// device is a device of the emulated PLC. The table is kept apart from pkg/mcp on purpose:
+// the emulator decodes frames on its own, so an encoding mistake of the client is not hidden.
+type device struct {
+	name string
+	// 3E/4E binary device code
+	code byte
+	// bit device. every other device is a word device
+	bit bool
+	// device number is hexadecimal in ascii frames
+	hex bool
+}
+
+// devices of the emulated PLC. MELSECコミュニケーションプロトコル リファレンス デバイスコード一覧
+var devices = []device{
+	{name: "SM", code: 0x91, bit: true},
+	{name: "SD", code: 0xA9},
+	{name: "X", code: 0x9C, bit: true, hex: true},
+	{name: "Y", code: 0x9D, bit: true, hex: true},
+	{name: "M", code: 0x90, bit: true},
+	{name: "L", code: 0x92, bit: true},
+	{name: "F", code: 0x93, bit: true},
+	{name: "V", code: 0x94, bit: true},
+	{name: "B", code: 0xA0, bit: true, hex: true},
+	{name: "D", code: 0xA8},
+	{name: "W", code: 0xB4, hex: true},
+	{name: "TS", code: 0xC1, bit: true},
+	{name: "TC", code: 0xC0, bit: true},
+	{name: "TN", code: 0xC2},
+	{name: "CS", code: 0xC4, bit: true},
+	{name: "CC", code: 0xC3, bit: true},
+	{name: "CN", code: 0xC5},
+	{name: "SB", code: 0xA1, bit: true, hex: true},
+	{name: "SW", code: 0xB5, hex: true},
+	{name: "S", code: 0x98, bit: true},
+	{name: "Z", code: 0xCC},
+	{name: "R", code: 0xAF},
+	{name: "ZR", code: 0xB0, hex: true},
+}
+
+// deviceByName returns the device named name, like "D" or "M".
+func deviceByName(name string) (device, bool) {
+	for _, d := range devices {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return device{}, false
+}
+
+// deviceByCode returns the device of a 3E/4E binary device code.
+func deviceByCode(code uint64) (device, bool) {
+	for _, d := range devices {
+		if uint64(d.code) == code {
+			return d, true
+		}
+	}
+	return device{}, false
+}
+
+// deviceByASCII returns the device of an ascii device code: "D*" (MELSEC-Q/L) or "D***" (iQ-R)
+// in 3E/4E frames, "D " in 1E frames.
+func deviceByASCII(code string) (device, bool) {
+	return deviceByName(strings.TrimRight(code, "* "))
+}
+
+// address is a device point of the memory.
+type address struct {
+	device string
+	offset int64
+}
+
+// memory is the device memory of the emulated PLC. Every point is zero until written.
+// Word access to a bit device reads or writes 16 points per word, the first point in bit 0.
+type memory struct {
+	words map[address]uint16
+	bits  map[address]bool
+	// buffer memory of intelligent function modules (U\G), by module and word address
+	buffer map[bufferAddress]uint16
+}
+
+type bufferAddress struct {
+	module  uint16
+	address int64
+}
+
+func newMemory() *memory {
+	return &memory{
+		words:  make(map[address]uint16),
+		bits:   make(map[address]bool),
+		buffer: make(map[bufferAddress]uint16),
+	}
+}
+
+func (m *memory) word(d device, offset int64) uint16 {
+	if !d.bit {
+		return m.words[address{d.name, offset}]
+	}
+	var w uint16
+	for i := int64(0); i < 16; i++ {
+		if m.bits[address{d.name, offset + i}] {
+			w |= 1 << i
+		}
+	}
+	return w
+}
+
+func (m *memory) setWord(d device, offset int64, v uint16) {
+	if !d.bit {
+		m.words[address{d.name, offset}] = v
+		return
+	}
+	for i := int64(0); i < 16; i++ {
+		m.bits[address{d.name, offset + i}] = v&(1<<i) != 0
+	}
+}
+
+// dword reads 2 words, the lower word first.
+func (m *memory) dword(d device, offset int64) uint32 {
+	return uint32(m.word(d, offset)) | uint32(m.word(d, offset+1))<<16
+}
+
+func (m *memory) setDword(d device, offset int64, v uint32) {
+	m.setWord(d, offset, uint16(v))
+	m.setWord(d, offset+1, uint16(v>>16))
+}
+
+func (m *memory) bit(d device, offset int64) bool {
+	return m.bits[address{d.name, offset}]
+}
+
+func (m *memory) setBit(d device, offset int64, v bool) {
+	m.bits[address{d.name, offset}] = v
+}
+
+// SetWords writes values to consecutive devices from offset, like D100 or W1A0.
+// A bit device is written 16 points per value, the first point in bit 0.
+func (e *Emulator) SetWords(deviceName string, offset int64, values ...uint16) error {
+	d, ok := deviceByName(deviceName)
+	if !ok {
+		return fmt.Errorf("mcsim: unknown device %q", deviceName)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, v := range values {
+		e.mem.setWord(d, offset+int64(i)*wordStep(d), v)
+	}
+	return nil
+}
+
+// Words reads numPoints words from offset. A bit device is read 16 points per word.
+func (e *Emulator) Words(deviceName string, offset int64, numPoints int) ([]uint16, error) {
+	d, ok := deviceByName(deviceName)
+	if !ok {
+		return nil, fmt.Errorf("mcsim: unknown device %q", deviceName)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	words := make([]uint16, numPoints)
+	for i := range words {
+		words[i] = e.mem.word(d, offset+int64(i)*wordStep(d))
+	}
+	return words, nil
+}
+
+// SetBits sets (true) or resets (false) consecutive bit devices from offset, like M100 or X1F.
+func (e *Emulator) SetBits(deviceName string, offset int64, values ...bool) error {
+	d, ok := deviceByName(deviceName)
+	if !ok || !d.bit {
+		return fmt.Errorf("mcsim: %q is not a bit device", deviceName)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, v := range values {
+		e.mem.setBit(d, offset+int64(i), v)
+	}
+	return nil
+}
+
+// Bits reads the state of numPoints bit devices from offset.
+func (e *Emulator) Bits(deviceName string, offset int64, numPoints int) ([]bool, error) {
+	d, ok := deviceByName(deviceName)
+	if !ok || !d.bit {
+		return nil, fmt.Errorf("mcsim: %q is not a bit device", deviceName)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	bits := make([]bool, numPoints)
+	for i := range bits {
+		bits[i] = e.mem.bit(d, offset+int64(i))
+	}
+	return bits, nil
+}
+
+// SetBuffer writes values to the buffer memory of an intelligent function module from
+// word address, like U3\G100 for module 3 and address 100.
+func (e *Emulator) SetBuffer(module uint16, address int64, values ...uint16) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, v := range values {
+		e.mem.buffer[bufferAddress{module, address + int64(i)}] = v
+	}
+}
+
+// Buffer reads numPoints words of the buffer memory of an intelligent function module.
+func (e *Emulator) Buffer(module uint16, address int64, numPoints int) []uint16 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	words := make([]uint16, numPoints)
+	for i := range words {
+		words[i] = e.mem.buffer[bufferAddress{module, address + int64(i)}]
+	}
+	return words
+}
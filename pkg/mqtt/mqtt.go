@@ -32,6 +32,16 @@ func PublishMessage(client MQTT.Client, topic string, message string, logger *lo
 	}
 }
 
+// PublishRetained publishes message as a retained message, so subscribers that
+// connect later still receive the last value (e.g. PLC metadata published once at startup).
+func PublishRetained(client MQTT.Client, topic string, message string, logger *log.Logger) {
+	token := client.Publish(topic, 1, true, message)
+	token.Wait()
+	if token.Error() != nil {
+		logger.Printf("Error publishing retained message to topic %s: %s", topic, token.Error())
+	}
+}
+
 func ECSNewMQTTClientWithTLS(cfg config.AppConfig, logger *log.Logger) MQTT.Client {
 
 	// Load client certificate and key
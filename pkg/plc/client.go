@@ -5,10 +5,14 @@ package plc
 import (
 	"context"
 	"log"
+	"time"
+
+	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
 )
 
 // PLCClient is the brand-agnostic interface every PLC driver must satisfy.
 // Keep this minimal — only operations that every brand can genuinely support.
+// Every operation is bounded by ctx: it returns once ctx is done without leaving work behind.
 type PLCClient interface {
 	// ReadData reads numberRegisters worth of data starting at deviceNumber.
 	// deviceType: memory area code  (e.g. "D", "M", "W", "R", "X", "Y")
@@ -24,14 +28,16 @@ type PLCClient interface {
 
 	// WriteData writes data to the PLC at the given address.
 	WriteData(
+		ctx context.Context,
 		deviceType string,
 		deviceNumber string,
 		writeData []byte,
 		numberRegisters uint16,
 	) error
 
-	// BatchWrite writes in chunks, handling wrap-around automatically.
+	// BatchWrite writes in chunks of maxRegistersPerWrite registers forward from startDevice.
 	BatchWrite(
+		ctx context.Context,
 		deviceType string,
 		startDevice string,
 		writeData []byte,
@@ -42,4 +48,58 @@ type PLCClient interface {
 	// EncodeData converts a string value to PLC-ready bytes.
 	// Each brand implements its own encoding format.
 	EncodeData(valueStr string, processNumber int) ([]byte, error)
+
+	// Ping checks that the PLC answers and returns the round trip time.
+	// Mitsubishi runs the MC protocol loopback test; other brands use their cheapest request.
+	Ping(ctx context.Context) (time.Duration, error)
+}
+
+// ReadResult is the outcome of reading one device through a MultiReader.
+type ReadResult struct {
+	Value any
+	Err   error
+}
+
+// MultiReader is implemented by clients that can read many devices in fewer
+// round trips than one per device. Results are returned in the order of devices.
+type MultiReader interface {
+	ReadDevices(ctx context.Context, devices []PLC_Utils.Device, fx bool) []ReadResult
+}
+
+// RemoteOperator is implemented by clients that can switch the PLC CPU remotely.
+// op is "run", "stop", "pause", "latch-clear" or "reset"; reason is recorded in the audit log.
+type RemoteOperator interface {
+	RemoteOperation(ctx context.Context, op string, reason string) error
+}
+
+// Identity describes the PLC hardware answering on a connection.
+type Identity struct {
+	// Model is the CPU model name, e.g. "R04CPU"
+	Model string
+	// Code is the model code in hex, e.g. "4800"
+	Code string
+}
+
+// Identifier is implemented by clients that can ask the PLC what it is.
+type Identifier interface {
+	Identify(ctx context.Context) (Identity, error)
+}
+
+// Connection is the state of a client's connection to the PLC.
+type Connection struct {
+	// State is "disconnected", "connecting" or "connected"
+	State string
+	// Since is when State was entered
+	Since time.Time
+	// Failures is the number of consecutive failed connection attempts
+	Failures int
+	// LastError is why the last connection was lost or the last attempt failed
+	LastError error
+	// RetryAt is when the client tries to connect again after a failed attempt
+	RetryAt time.Time
+}
+
+// ConnectionReporter is implemented by clients that keep a connection to the PLC.
+type ConnectionReporter interface {
+	Connection() Connection
 }
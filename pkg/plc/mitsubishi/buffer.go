@@ -0,0 +1,70 @@
+package mitsubishi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isBufferDevice reports whether deviceType is an intelligent function module buffer memory
+// address like U3\G, the module part of U3\G100.
+func isBufferDevice(deviceType string) bool {
+	upper := strings.ToUpper(deviceType)
+	return strings.HasPrefix(upper, "U") && strings.Contains(upper, `\G`)
+}
+
+// parseBufferDevice parses a buffer memory address U<module>\G<address>. The device type carries
+// the module (U3\G) and the device number the address (100); U3\G100 with an empty device
+// number is accepted too, but not an address in both. The module is hex, the start I/O number
+// without its last digit (U3 is X/Y30), and the address is decimal in words, as in GX Works.
+func parseBufferDevice(deviceType, deviceNumber string) (uint16, int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(deviceType))
+	i := strings.Index(upper, `\G`)
+	if !strings.HasPrefix(upper, "U") || i < 0 {
+		return 0, 0, fmt.Errorf("%s%s is not a buffer memory address like U3\\G100", deviceType, deviceNumber)
+	}
+	module, err := strconv.ParseUint(upper[1:i], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid module in %s%s: %w", deviceType, deviceNumber, err)
+	}
+	embedded, number := upper[i+2:], strings.TrimSpace(deviceNumber)
+	if embedded != "" && number != "" {
+		return 0, 0, fmt.Errorf("%s has its address in the device type, the device number %s must be empty", deviceType, deviceNumber)
+	}
+	address, err := strconv.ParseInt(embedded+number, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid buffer memory address in %s%s: %w", deviceType, deviceNumber, err)
+	}
+	return uint16(module), address, nil
+}
+
+// readBuffer reads a buffer memory value of the given data type, like ReadData does for devices.
+func (m *MSPClient) readBuffer(ctx context.Context, deviceType, deviceNumber string, numberRegisters uint16) (any, error) {
+	module, address, err := parseBufferDevice(deviceType, deviceNumber)
+	if err != nil {
+		return nil, err
+	}
+	data, err := m.client.ReadBuffer(ctx, module, address, int64(numberRegisters))
+	if err != nil {
+		return nil, err
+	}
+	return parseData(data, int(numberRegisters), false)
+}
+
+// writeBuffer writes writeData to the buffer memory, like WriteData does for devices.
+// Odd or short data is padded with zeros to numberRegisters words.
+func (m *MSPClient) writeBuffer(ctx context.Context, deviceType, deviceNumber string, writeData []byte, numberRegisters uint16) error {
+	module, address, err := parseBufferDevice(deviceType, deviceNumber)
+	if err != nil {
+		return err
+	}
+
+	points := max(int(numberRegisters), (len(writeData)+1)/2)
+	if len(writeData) < 2*points {
+		padded := make([]byte, 2*points)
+		copy(padded, writeData)
+		writeData = padded
+	}
+	return m.checkEndCode(m.client.WriteBuffer(ctx, module, address, int64(points), writeData))
+}
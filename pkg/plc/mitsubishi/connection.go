@@ -0,0 +1,22 @@
+package mitsubishi
+
+import (
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+	"github.com/mochigome-git/msp-go/pkg/plc"
+)
+
+// Connection reports the state of the connection to the PLC and satisfies plc.ConnectionReporter.
+// The client dials with a timeout and, after a failed dial, waits with exponential backoff before the next one.
+func (m *MSPClient) Connection() plc.Connection {
+	if m == nil || m.client == nil {
+		return plc.Connection{State: mcp.Disconnected.String()}
+	}
+	s := m.client.Status()
+	return plc.Connection{
+		State:     s.State.String(),
+		Since:     s.Since,
+		Failures:  s.Failures,
+		LastError: s.LastError,
+		RetryAt:   s.RetryAt,
+	}
+}
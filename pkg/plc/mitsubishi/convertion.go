@@ -11,14 +11,30 @@ import (
 )
 
 // parseData parses raw PLC bytes based on the register count / data type convention.
-// This is your original parseData function, unchanged.
 func parseData(data []byte, numberRegisters int, fx bool) (any, error) {
-	registerBinary, _ := mcp.NewParser().Do(data)
-	if fx {
-		registerBinary, _ = mcp.NewParser().DoFx(data)
+	payload, err := responsePayload(data, fx)
+	if err != nil {
+		return nil, err
 	}
-	data = registerBinary.Payload
 
+	return decodeData(payload, numberRegisters)
+}
+
+// responsePayload returns the data of a read response: 1E responses are parsed with DoFx,
+// 3E/4E responses with DoPayload, which renders no header strings.
+func responsePayload(resp []byte, fx bool) ([]byte, error) {
+	if !fx {
+		return mcp.NewParser().DoPayload(resp)
+	}
+	r, err := mcp.NewParser().DoFx(resp)
+	if err != nil {
+		return nil, err
+	}
+	return r.Payload, nil
+}
+
+// decodeData decodes response payload bytes (lower byte first) based on the register count / data type convention.
+func decodeData(data []byte, numberRegisters int) (any, error) {
 	switch numberRegisters {
 	case 1: // 16-bit unsigned
 		var val uint16
@@ -0,0 +1,22 @@
+package mitsubishi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mochigome-git/msp-go/pkg/plc"
+)
+
+// Identify reads the CPU model name and model code (command 0101) and satisfies plc.Identifier.
+// FX CPUs behind 1E frames do not support the command.
+func (m *MSPClient) Identify(ctx context.Context) (plc.Identity, error) {
+	if m == nil || m.client == nil {
+		return plc.Identity{}, fmt.Errorf("MSP client not initialized")
+	}
+
+	model, err := m.client.ReadCPUModel(ctx)
+	if err != nil {
+		return plc.Identity{}, err
+	}
+	return plc.Identity{Model: model.Name, Code: fmt.Sprintf("%04X", model.Code)}, nil
+}
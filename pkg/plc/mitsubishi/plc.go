@@ -5,9 +5,12 @@ package mitsubishi
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 	"unicode"
 
 	"github.com/mochigome-git/msp-go/pkg/mcp"
@@ -21,8 +24,62 @@ import (
 // MSPClient wraps the MC Protocol client for Mitsubishi PLCs.
 type MSPClient struct {
 	client mcp.Client
+	// maxInFlight is how many requests may share the connection at once
+	maxInFlight int
+	// series and code are the CPU series and communication code the requests are built for
+	series mcp.Series
+	code   mcp.Code
+	// addr is the PLC address, used in audit log lines
+	addr string
+	// remoteEnabled allows remote RUN/STOP/PAUSE/latch clear/reset
+	remoteEnabled bool
+	// logger receives the audit log lines of remote operations; nil is the standard logger
+	logger *log.Logger
+	// fx is set for 1E frame clients: responses are 1E frames and X/Y numbers are octal
+	fx bool
 }
 
+// Options selects how MSPClient talks to the PLC.
+// The zero value is a 3E frame client, matching NewMSPClient.
+type Options struct {
+	// Frame is the MC protocol frame: "3E" (default), "4E", "1E", "3C" or "4C".
+	// "1E" talks to FX3U-ENET / FX3U-ENET-ADP; X and Y device numbers are octal like on the FX CPU.
+	// "3C" and "4C" are ascii frames of a serial communication module (C24) reached over tcp
+	// through a serial-to-Ethernet device server; Code is ignored.
+	Frame string
+	// SerialStation is the station number set on the serial communication module (0-31), "3C"/"4C" only.
+	SerialStation int
+	// SerialFormat is the frame format set on the serial communication module, "3C"/"4C" only:
+	// 1 (default) or 4, which ends every frame with CR LF.
+	SerialFormat int
+	// Transport is "tcp" (default) or "udp", matching the open setting of the Ethernet module.
+	// UDP is available for the 3E and 1E frames; requests without response are sent again.
+	Transport string
+	// Code is the communication data code set on the Ethernet module:
+	// "binary" (default) or "ascii".
+	Code string
+	// Series is the CPU series: "Q" (default, also L) or "iQ-R".
+	// iQ-R uses the extended device specification (4 byte device numbers, 2 byte device codes).
+	Series string
+	// Route is the access route to a PLC on another network or multidrop station:
+	// "network,station[,module i/o[,multidrop station]]". Empty is the connected PLC itself.
+	Route string
+	// TargetCPU selects the CPU of a multiple CPU or redundant system: "1"-"4", "control",
+	// "standby", "A", "B" or a hex module I/O number. Empty keeps the module I/O of Route (03FF).
+	TargetCPU string
+	// RemotePassword unlocks an Ethernet port protected by a remote password. It is sent after
+	// every (re)connect before any other request; empty for unprotected ports. Not for "1E".
+	RemotePassword string
+	// EnableRemoteOperation allows RemoteOperation (RUN/STOP/PAUSE/latch clear/reset).
+	// It is off by default so the normal data path can never stop a line.
+	EnableRemoteOperation bool
+	// Logger receives the audit log lines of RemoteOperation. nil writes them to the standard logger.
+	Logger *log.Logger
+}
+
+// maxInFlight4E is how many pipelined requests a 4E client keeps on its connection.
+const maxInFlight4E = 8
+
 var msp *MSPClient
 
 // InitMSPClient initializes a package-level singleton MSP client.
@@ -41,73 +98,244 @@ func InitMSPClient(plcHost string, plcPort int) error {
 
 // NewMSPClient creates a new Mitsubishi MC Protocol client.
 func NewMSPClient(plcHost string, plcPort int) (*MSPClient, error) {
-	client, err := mcp.New3EClient(plcHost, plcPort, mcp.NewLocalStation())
+	return NewMSPClientWithOptions(plcHost, plcPort, Options{})
+}
+
+// NewMSPClientWithOptions creates a new Mitsubishi MC Protocol client
+// using the frame and settings in opts.
+func NewMSPClientWithOptions(plcHost string, plcPort int, opts Options) (*MSPClient, error) {
+	code, err := mcp.ParseCode(opts.Code)
+	if err != nil {
+		return nil, err
+	}
+	series, err := mcp.ParseSeries(opts.Series)
 	if err != nil {
 		return nil, err
 	}
-	return &MSPClient{client: client}, nil
+	stn, err := mcp.ParseStation(opts.Route)
+	if err != nil {
+		return nil, err
+	}
+	stn.SetCode(code).SetSeries(series)
+	if opts.RemotePassword != "" {
+		if err := mcp.CheckRemotePassword(opts.RemotePassword, series); err != nil {
+			return nil, err
+		}
+		stn.SetRemotePassword(opts.RemotePassword)
+	}
+	if strings.TrimSpace(opts.TargetCPU) != "" {
+		ioNum, err := mcp.ParseTargetCPU(opts.TargetCPU)
+		if err != nil {
+			return nil, err
+		}
+		stn.SetModuleIO(ioNum)
+	}
+
+	m := &MSPClient{
+		maxInFlight:   1,
+		series:        series,
+		code:          code,
+		addr:          fmt.Sprintf("%s:%d", plcHost, plcPort),
+		remoteEnabled: opts.EnableRemoteOperation,
+		logger:        opts.Logger,
+	}
+	new3E, new1E := mcp.New3EClient, mcp.New1EClient
+	udp := false
+	switch strings.ToLower(strings.TrimSpace(opts.Transport)) {
+	case "", "tcp":
+	case "udp":
+		new3E, new1E, udp = mcp.New3EUDPClient, mcp.New1EUDPClient, true
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", opts.Transport)
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(opts.Frame)) {
+	case "", "3E":
+		m.client, err = new3E(plcHost, plcPort, stn)
+	case "4E":
+		if udp {
+			return nil, fmt.Errorf("MC protocol frame 4E is only supported over tcp")
+		}
+		m.client, err = mcp.New4EClient(plcHost, plcPort, stn)
+		m.maxInFlight = maxInFlight4E
+	case "1E":
+		m.client, err = new1E(plcHost, plcPort, stn)
+		m.fx = true
+	case "3C", "4C":
+		if udp {
+			return nil, fmt.Errorf("MC protocol frame %s is only supported over tcp", opts.Frame)
+		}
+		if opts.SerialStation < 0 || opts.SerialStation > 31 {
+			return nil, fmt.Errorf("serial station number %d out of range 0-31", opts.SerialStation)
+		}
+		format := mcp.Format1
+		if opts.SerialFormat != 0 {
+			format = mcp.SerialFormat(opts.SerialFormat)
+		}
+		newSerial := mcp.New3CClient
+		if strings.EqualFold(strings.TrimSpace(opts.Frame), "4C") {
+			newSerial = mcp.New4CClient
+		}
+		m.client, err = newSerial(plcHost, plcPort, stn, byte(opts.SerialStation), format)
+		// serial frames are always ascii, whatever Code says
+		m.code = mcp.Ascii
+	default:
+		return nil, fmt.Errorf("unsupported MC protocol frame %q", opts.Frame)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MaxInFlight reports how many requests may be issued concurrently on this client.
+// 3E clients serialize requests; 4E clients pipeline them by serial number.
+func (m *MSPClient) MaxInFlight() int {
+	if m == nil || m.maxInFlight < 1 {
+		return 1
+	}
+	return m.maxInFlight
+}
+
+// Ping runs the MC protocol loopback test and returns the round trip time.
+func (m *MSPClient) Ping(ctx context.Context) (time.Duration, error) {
+	if m == nil || m.client == nil {
+		return 0, fmt.Errorf("MSP client not initialized")
+	}
+	return m.client.Ping(ctx)
 }
 
 // ReadData reads data from the Mitsubishi PLC for the specified device.
+// Intelligent function module buffer memory is addressed as U3\G with device number 100 (U3\G100).
 func (m *MSPClient) ReadData(ctx context.Context, deviceType string, deviceNumber string, numberRegisters uint16, fx bool) (any, error) {
 	if m == nil || m.client == nil {
 		return nil, fmt.Errorf("MSP client not initialized")
 	}
-
-	// W and Y are hex-addressed on Mitsubishi PLCs. Parse as hex
-	// unconditionally — see BatchWrite's comment for why a
-	// decimal-first-then-fallback approach silently misreads addresses
-	// like "50" (valid in both bases, but means different registers).
-	var deviceNumberInt64 int64
-	var err error
-	if deviceType == "Y" || deviceType == "W" {
-		deviceNumberInt64, err = strconv.ParseInt(deviceNumber, 16, 64)
-	} else {
-		deviceNumberInt64, err = strconv.ParseInt(deviceNumber, 10, 64)
+	if isBufferDevice(deviceType) {
+		return m.readBuffer(ctx, deviceType, deviceNumber, numberRegisters)
 	}
+
+	// W and Y are hex-addressed on Mitsubishi PLCs — see parseDeviceNumber.
+	deviceNumberInt64, err := m.parseDeviceNumber(deviceType, deviceNumber)
 	if err != nil {
 		return nil, err
 	}
 
-	resultCh := make(chan any)
-	errCh := make(chan error)
+	// a 1E frame client answers every request with 1E frames
+	fx = fx || m.fx
 
-	go func() {
-		data, err := m.client.Read(deviceType, deviceNumberInt64, int64(numberRegisters), fx)
-		if err != nil {
-			errCh <- err
-			return
+	// bit devices are read in bit units so the value is exactly the addressed relay.
+	// FX data type 6 is a bit device as well. Bit reads are 1E frames only on a 1E frame client,
+	// so a 3E client given fx reads FX devices in word units.
+	bitRead := numberRegisters == 3 && fx == m.fx && mcp.IsBitDevice(deviceType)
+	if numberRegisters == 6 && fx {
+		if !m.fx {
+			return nil, fmt.Errorf("FX bit device %s%s needs the 1E frame client (frame 1E)", deviceType, deviceNumber)
 		}
-		value, err := parseData(data, int(numberRegisters), fx)
+		bitRead = true
+	}
+	if bitRead {
+		bits, err := m.ReadBits(ctx, deviceType, deviceNumber, 1)
 		if err != nil {
-			errCh <- err
-			return
+			return nil, err
+		}
+		var value uint8
+		if bits[0] {
+			value = 1
 		}
-		resultCh <- value
-	}()
+		if numberRegisters == 6 {
+			return uint16(value), nil
+		}
+		return value, nil
+	}
 
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case err := <-errCh:
+	data, err := m.client.Read(ctx, deviceType, deviceNumberInt64, int64(numberRegisters), fx)
+	if err != nil {
 		return nil, err
-	case value := <-resultCh:
-		return value, nil
 	}
+	return parseData(data, int(numberRegisters), fx)
+}
+
+// ReadBits reads numPoints consecutive bit devices (M, X, Y, B, L, ...) in bit units.
+func (m *MSPClient) ReadBits(ctx context.Context, deviceType, deviceNumber string, numPoints uint16) ([]bool, error) {
+	if m == nil || m.client == nil {
+		return nil, fmt.Errorf("MSP client not initialized")
+	}
+
+	offset, err := m.parseDeviceNumber(deviceType, deviceNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.client.ReadBits(ctx, deviceType, offset, int64(numPoints))
+}
+
+// ReadWords reads numPoints consecutive word devices starting at deviceNumber, e.g. a whole D register table.
+// Reads beyond the per-request limit (960 words, 256 for FX) are split into several requests by the MC protocol client.
+func (m *MSPClient) ReadWords(ctx context.Context, deviceType, deviceNumber string, numPoints int, fx bool) ([]uint16, error) {
+	if m == nil || m.client == nil {
+		return nil, fmt.Errorf("MSP client not initialized")
+	}
+	if numPoints <= 0 {
+		return nil, fmt.Errorf("ReadWords: %d points", numPoints)
+	}
+
+	offset, err := m.parseDeviceNumber(deviceType, deviceNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	fx = fx || m.fx
+	data, err := m.client.Read(ctx, deviceType, offset, int64(numPoints), fx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := responsePayload(data, fx)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 2*numPoints {
+		return nil, fmt.Errorf("ReadWords: got %d bytes for %d points", len(payload), numPoints)
+	}
+	words := make([]uint16, numPoints)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint16(payload[2*i:])
+	}
+	return words, nil
+}
+
+// WriteBits sets (true) or resets (false) consecutive bit devices starting at deviceNumber.
+func (m *MSPClient) WriteBits(ctx context.Context, deviceType, deviceNumber string, values []bool) error {
+	if m == nil || m.client == nil {
+		return fmt.Errorf("MSP client not initialized")
+	}
+
+	offset, err := m.parseDeviceNumber(deviceType, deviceNumber)
+	if err != nil {
+		return err
+	}
+
+	return m.checkEndCode(m.client.WriteBits(ctx, deviceType, offset, values))
 }
 
 // WriteData sends data to the Mitsubishi PLC for the specified device.
-func (m *MSPClient) WriteData(deviceType, deviceNumber string, writeData []byte, numberRegisters uint16) error {
+// A single byte written to a bit device (EncodeData bit value) sets or resets that relay in bit units.
+func (m *MSPClient) WriteData(ctx context.Context, deviceType, deviceNumber string, writeData []byte, numberRegisters uint16) error {
 	if m == nil || m.client == nil {
 		return fmt.Errorf("MSP client not initialized")
 	}
+	if isBufferDevice(deviceType) {
+		return m.writeBuffer(ctx, deviceType, deviceNumber, writeData, numberRegisters)
+	}
 
-	deviceNumberInt64, err := strconv.ParseInt(deviceNumber, 10, 64)
-	if err != nil || deviceType == "Y" {
-		deviceNumberInt64, err = strconv.ParseInt(deviceNumber, 16, 64)
-		if err != nil {
-			return err
-		}
+	// W, X, Y, B, ... are hex-addressed like in ReadData — see parseDeviceNumber.
+	deviceNumberInt64, err := m.parseDeviceNumber(deviceType, deviceNumber)
+	if err != nil {
+		return err
+	}
+
+	if len(writeData) == 1 && mcp.IsBitDevice(deviceType) {
+		return m.checkEndCode(m.client.WriteBits(ctx, deviceType, deviceNumberInt64, []bool{writeData[0] != 0}))
 	}
 
 	calculatedRegisters := (len(writeData) + 1) / 2
@@ -115,72 +343,109 @@ func (m *MSPClient) WriteData(deviceType, deviceNumber string, writeData []byte,
 		numberRegisters = uint16(calculatedRegisters)
 	}
 
-	_, err = m.client.Write(deviceType, deviceNumberInt64, int64(numberRegisters), writeData)
+	// word writes send 2 byte per point; pad odd or short data with zeros
+	if len(writeData) < 2*int(numberRegisters) {
+		padded := make([]byte, 2*int(numberRegisters))
+		copy(padded, writeData)
+		writeData = padded
+	}
+
+	return m.checkEndCode(m.client.Write(ctx, deviceType, deviceNumberInt64, int64(numberRegisters), writeData))
+}
+
+// checkEndCode returns err, or the *mcp.EndCodeError of resp when the PLC answered with a non-zero end code.
+// It takes the results of a write call directly: m.checkEndCode(m.client.Write(...)).
+func (m *MSPClient) checkEndCode(resp []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if m.fx {
+		_, err = mcp.NewParser().DoFx(resp)
+		return err
+	}
+	_, err = mcp.NewParser().Do(resp)
 	return err
 }
 
+// parseDeviceNumber parses the number of a device address in the radix of its device type:
+// hex for X, Y, B, W and the other hex-addressed devices, decimal otherwise. On a 1E frame
+// client X and Y are octal, as the FX CPU numbers them: X20 is the 17th input.
+// A number that does not fit the client's device number field is an error.
+func (m *MSPClient) parseDeviceNumber(deviceType, deviceNumber string) (int64, error) {
+	var offset int64
+	var err error
+	if m.fx && mcp.IsOctalDeviceFx(deviceType) {
+		offset, err = strconv.ParseInt(deviceNumber, 8, 64)
+	} else {
+		offset, err = parseDeviceNumber(deviceType, deviceNumber)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkDeviceRange(deviceType, offset, 1); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
 // WriteData sends data to the PLC for the specified device.
 // deviceType: device code (e.g. "D", "M", "Y").
 // deviceNumber: starting device address (string, can be decimal or hex depending on device).
 // numberRegisters: number of points to write.
 // writeData: the data to be written as a byte slice.
-// BatchWrite get wrap-around (overflow) and jump to lower device (reverse)
+// Chunks of maxRegistersPerWrite registers are written forward from the start; a range that does not
+// fit in the device number field is rejected before the first chunk.
 // BatchWrite writes using the package-level MSP client initialized via InitMSPClient.
+// It is not bounded by a context; prefer (*MSPClient).BatchWrite.
 func BatchWrite(deviceType, startDevice string, writeData []byte, maxRegistersPerWrite uint16, logger *log.Logger) error {
 	if msp == nil {
 		return fmt.Errorf("MSP client not initialized")
 	}
-	return msp.BatchWrite(deviceType, startDevice, writeData, maxRegistersPerWrite, logger)
+	return msp.BatchWrite(context.Background(), deviceType, startDevice, writeData, maxRegistersPerWrite, logger)
 }
 
-func (m *MSPClient) BatchWrite(deviceType, startDevice string, writeData []byte, maxRegistersPerWrite uint16, logger *log.Logger) error {
+func (m *MSPClient) BatchWrite(ctx context.Context, deviceType, startDevice string, writeData []byte, maxRegistersPerWrite uint16, logger *log.Logger) error {
 	if m == nil || m.client == nil {
 		return fmt.Errorf("MSP client not initialized")
 	}
+	if isBufferDevice(deviceType) {
+		// buffer memory writes are split into requests by the MC protocol client
+		return m.writeBuffer(ctx, deviceType, startDevice, writeData, 0)
+	}
 
-	// W and Y are hex-addressed on Mitsubishi PLCs. Parse as hex
-	// unconditionally — a hex address like "10" (=16 decimal) is ALSO
-	// valid decimal syntax, so a decimal-first-then-fallback-on-error
-	// approach silently parses it wrong instead of falling back.
-	var deviceNumberUint16 uint16
-	if deviceType == "Y" || deviceType == "W" {
-		val64, err := strconv.ParseInt(startDevice, 16, 64)
-		if err != nil {
-			return err
-		}
-		deviceNumberUint16 = uint16(val64)
-	} else {
-		val64, err := strconv.ParseInt(startDevice, 10, 64)
-		if err != nil {
-			return err
-		}
-		deviceNumberUint16 = uint16(val64)
+	if maxRegistersPerWrite == 0 {
+		return fmt.Errorf("BatchWrite: maxRegistersPerWrite must be at least 1")
 	}
 
-	totalRegisters := (len(writeData) + 1) / 2
-	written := 0
+	// Hex-addressed devices (W, Y, ...) are parsed as hex unconditionally —
+	// a hex address like "10" (=16 decimal) is ALSO valid decimal syntax,
+	// so a decimal-first-then-fallback-on-error approach silently parses
+	// it wrong instead of falling back. See parseDeviceNumber.
+	start, err := m.parseDeviceNumber(deviceType, startDevice)
+	if err != nil {
+		return err
+	}
 
-	for written < totalRegisters {
-		remaining := totalRegisters - written
-		chunkSize := remaining
-		if chunkSize > int(maxRegistersPerWrite) {
-			chunkSize = int(maxRegistersPerWrite)
-		}
+	totalRegisters := int64((len(writeData) + 1) / 2)
+	// the last device of the range must be addressable too, so no chunk is sent for a range that does not fit
+	if err := m.checkDeviceRange(deviceType, start, totalRegisters); err != nil {
+		return err
+	}
+
+	for written := int64(0); written < totalRegisters; {
+		chunkSize := min(totalRegisters-written, int64(maxRegistersPerWrite))
 
 		startIndex := written * 2
-		endIndex := startIndex + chunkSize*2
-		if endIndex > len(writeData) {
-			endIndex = len(writeData)
-		}
+		endIndex := min(startIndex+chunkSize*2, int64(len(writeData)))
 		chunk := writeData[startIndex:endIndex]
 
-		addr := deviceNumberUint16 + uint16(written) // forward from the real start, no top-of-range shift
+		addr := start + written // forward from the real start, no top-of-range shift
 
 		if logger != nil {
 			logger.Printf("Writing to %s device number %d, chunk size %d, data % X\n", deviceType, addr, chunkSize, chunk)
 		}
 
-		if _, err := m.client.Write(deviceType, int64(addr), int64(chunkSize), chunk); err != nil {
+		if err := m.checkEndCode(m.client.Write(ctx, deviceType, addr, chunkSize, chunk)); err != nil {
 			return err
 		}
 		written += chunkSize
@@ -189,6 +454,23 @@ func (m *MSPClient) BatchWrite(deviceType, startDevice string, writeData []byte,
 	return nil
 }
 
+// checkDeviceRange validates the device numbers start to start+numPoints-1 with the validator
+// the MC protocol client runs on every request (1E frame devices on a 1E frame client).
+func (m *MSPClient) checkDeviceRange(deviceType string, start, numPoints int64) error {
+	for _, offset := range []int64{start, start + max(numPoints, 1) - 1} {
+		var err error
+		if m.fx {
+			err = mcp.CheckDeviceNumberFx(deviceType, offset)
+		} else {
+			err = mcp.CheckDeviceNumber(deviceType, offset, m.series, m.code)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // IncrementDevice increments a device string like "W10" to "W11".
 func IncrementDevice(device string, offset int64) (string, error) {
 	var prefix string
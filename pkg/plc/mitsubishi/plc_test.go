@@ -1,8 +1,19 @@
 package mitsubishi
 
 import (
+	"context"
+	"encoding/hex"
 	"log"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+	"github.com/mochigome-git/msp-go/pkg/mcsim"
+	"github.com/mochigome-git/msp-go/pkg/plc"
+	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,16 +26,85 @@ type mockClient struct {
 
 var parseDataFunc = parseData
 
-func (m *mockClient) Write(deviceType string, deviceNumber int64, numPoints int64, data []byte) ([]byte, error) {
+// writeOK is a 3E binary response with a normal end and no data, the answer to every write command.
+var writeOK, _ = hex.DecodeString("d00000ffff030002000000")
+
+func (m *mockClient) Write(ctx context.Context, deviceType string, deviceNumber int64, numPoints int64, data []byte) ([]byte, error) {
 	args := m.Called(deviceType, deviceNumber, numPoints, data)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
-func (m *mockClient) Read(deviceType string, deviceNumber int64, numPoints int64, fx bool) ([]byte, error) {
+func (m *mockClient) Read(ctx context.Context, deviceType string, deviceNumber int64, numPoints int64, fx bool) ([]byte, error) {
 	args := m.Called(deviceType, deviceNumber, numPoints, fx)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *mockClient) ReadBits(ctx context.Context, deviceType string, deviceNumber int64, numPoints int64) ([]bool, error) {
+	args := m.Called(deviceType, deviceNumber, numPoints)
+	return args.Get(0).([]bool), args.Error(1)
+}
+
+func (m *mockClient) WriteBits(ctx context.Context, deviceType string, deviceNumber int64, values []bool) ([]byte, error) {
+	args := m.Called(deviceType, deviceNumber, values)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) RandomRead(ctx context.Context, words, dwords []mcp.Device) ([]byte, error) {
+	args := m.Called(words, dwords)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) RandomWrite(ctx context.Context, words, dwords []mcp.DeviceValue) ([]byte, error) {
+	args := m.Called(words, dwords)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) RandomWriteBits(ctx context.Context, bits []mcp.DeviceValue) ([]byte, error) {
+	args := m.Called(bits)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) ReadBlocks(ctx context.Context, wordBlocks, bitBlocks []mcp.Block) ([]byte, error) {
+	args := m.Called(wordBlocks, bitBlocks)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) WriteBlocks(ctx context.Context, wordBlocks, bitBlocks []mcp.Block) ([]byte, error) {
+	args := m.Called(wordBlocks, bitBlocks)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) ReadBuffer(ctx context.Context, module uint16, address, numPoints int64) ([]byte, error) {
+	args := m.Called(module, address, numPoints)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) WriteBuffer(ctx context.Context, module uint16, address, numPoints int64, data []byte) ([]byte, error) {
+	args := m.Called(module, address, numPoints, data)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) Ping(ctx context.Context) (time.Duration, error) {
+	args := m.Called()
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *mockClient) ReadCPUModel(ctx context.Context) (*mcp.CPUModel, error) {
+	args := m.Called()
+	model, _ := args.Get(0).(*mcp.CPUModel)
+	return model, args.Error(1)
+}
+
+func (m *mockClient) Remote(ctx context.Context, op mcp.RemoteOp) ([]byte, error) {
+	args := m.Called(op)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *mockClient) Status() mcp.ConnStatus {
+	args := m.Called()
+	return args.Get(0).(mcp.ConnStatus)
+}
+
 func (m *mockClient) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -40,19 +120,77 @@ func TestWriteData_ASCIIRegisters(t *testing.T) {
 	mockMCP := new(mockClient)
 	expectedRegisters := uint16((len(data) + 1) / 2)
 
-	// Expect Write call
-	mockMCP.On("Write", "W", int64(10), int64(expectedRegisters), data).Return([]byte{}, nil).Once()
+	// Expect Write call. W is hex-addressed: W10 is device number 0x10
+	mockMCP.On("Write", "W", int64(0x10), int64(expectedRegisters), data).Return(writeOK, nil).Once()
 
 	// Create MSPClient instance with mock
 	client := &MSPClient{client: mockMCP}
 
 	// Call WriteData using instance
-	err = client.WriteData("W", "10", data, 0)
+	err = client.WriteData(context.Background(), "W", "10", data, 0)
+	assert.NoError(t, err)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestWriteData_Bit(t *testing.T) {
+	data, err := EncodeData("1", 3)
+	assert.NoError(t, err)
+
+	mockMCP := new(mockClient)
+	// Y is hex-addressed: Y1F = 31
+	mockMCP.On("WriteBits", "Y", int64(31), []bool{true}).Return(writeOK, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	err = client.WriteData(context.Background(), "Y", "1F", data, 1)
+	assert.NoError(t, err)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestWriteData_PadsShortData(t *testing.T) {
+	mockMCP := new(mockClient)
+	mockMCP.On("Write", "D", int64(100), int64(2), []byte{0x01, 0x02, 0x03, 0x00}).Return(writeOK, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	err := client.WriteData(context.Background(), "D", "100", []byte{0x01, 0x02, 0x03}, 2)
 	assert.NoError(t, err)
 
 	mockMCP.AssertExpectations(t)
 }
 
+// ------------------- Test end code errors -------------------
+
+func TestReadData_EndCodeError(t *testing.T) {
+	mockMCP := new(mockClient)
+	// C056 with error information: network 00, pc FF, module i/o 03FF, station 00, command 0401/0000
+	resp, _ := hex.DecodeString("d00000ffff03000b0056c000ffff030001040000")
+	mockMCP.On("Read", "D", int64(99999), int64(1), false).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	_, err := client.ReadData(context.Background(), "D", "99999", 1, false)
+
+	var endCodeErr *mcp.EndCodeError
+	assert.ErrorAs(t, err, &endCodeErr)
+	assert.ErrorIs(t, err, mcp.ErrDeviceOutOfRange)
+	assert.Equal(t, uint16(0x0401), endCodeErr.Info.Command)
+	assert.Contains(t, err.Error(), "device out of range")
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestWriteData_EndCodeError(t *testing.T) {
+	mockMCP := new(mockClient)
+	resp, _ := hex.DecodeString("d00000ffff03000b0055c000ffff030001140000")
+	mockMCP.On("Write", "D", int64(100), int64(1), []byte{0x01, 0x00}).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	err := client.WriteData(context.Background(), "D", "100", []byte{0x01, 0x00}, 1)
+	assert.ErrorIs(t, err, mcp.ErrPointsOutOfRange)
+
+	mockMCP.AssertExpectations(t)
+}
+
 // ------------------- Test BatchWrite -------------------
 
 func TestBatchWrite(t *testing.T) {
@@ -65,10 +203,10 @@ func TestBatchWrite(t *testing.T) {
 
 	maxRegisters := uint16(4) // batch size 4 registers
 
-	// Expected Write calls (address calculated for backward batch)
-	mockMCP.On("Write", "W", int64(19), int64(4), writeData[0:8]).Return([]byte{}, nil).Once()
-	mockMCP.On("Write", "W", int64(15), int64(4), writeData[8:16]).Return([]byte{}, nil).Once()
-	mockMCP.On("Write", "W", int64(11), int64(2), writeData[16:20]).Return([]byte{}, nil).Once()
+	// Expected Write calls: W is hex-addressed, so "10" starts at 16 and chunks move forward
+	mockMCP.On("Write", "W", int64(16), int64(4), writeData[0:8]).Return(writeOK, nil).Once()
+	mockMCP.On("Write", "W", int64(20), int64(4), writeData[8:16]).Return(writeOK, nil).Once()
+	mockMCP.On("Write", "W", int64(24), int64(2), writeData[16:20]).Return(writeOK, nil).Once()
 
 	// Inject mock into MSPClient
 	client := &MSPClient{client: mockMCP}
@@ -77,10 +215,504 @@ func TestBatchWrite(t *testing.T) {
 	logger := log.Default()
 
 	// Call BatchWrite
-	err := client.BatchWrite("W", "10", writeData, maxRegisters, logger)
+	err := client.BatchWrite(context.Background(), "W", "10", writeData, maxRegisters, logger)
 	assert.NoError(t, err)
 
 	mockMCP.AssertExpectations(t)
 }
 
+func TestBatchWrite_HighAddress(t *testing.T) {
+	mockMCP := new(mockClient)
+	writeData := make([]byte, 12) // 6 registers
+
+	// iQ-R D devices above 65535 keep their address, chunks do not wrap to low devices
+	mockMCP.On("Write", "D", int64(70000), int64(4), writeData[0:8]).Return(writeOK, nil).Once()
+	mockMCP.On("Write", "D", int64(70004), int64(2), writeData[8:12]).Return(writeOK, nil).Once()
+
+	client := &MSPClient{client: mockMCP, series: mcp.IQRSeries, code: mcp.Binary}
+	assert.NoError(t, client.BatchWrite(context.Background(), "D", "70000", writeData, 4, nil))
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestBatchWrite_Rejected(t *testing.T) {
+	mockMCP := new(mockClient)
+	client := &MSPClient{client: mockMCP, series: mcp.QSeries, code: mcp.Binary}
+
+	// the last register D16777216 does not fit in the 3 byte device number of MELSEC-Q/L
+	err := client.BatchWrite(context.Background(), "D", "16777214", make([]byte, 6), 4, nil)
+	assert.ErrorContains(t, err, "out of range")
+
+	err = client.BatchWrite(context.Background(), "D", "0", make([]byte, 6), 0, nil)
+	assert.Error(t, err)
+
+	mockMCP.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 // ------------------- Test ReadData -------------------
+
+func TestReadData_OutOfRange(t *testing.T) {
+	mockMCP := new(mockClient)
+	client := &MSPClient{client: mockMCP, series: mcp.QSeries, code: mcp.Binary}
+
+	// W is hex-addressed: W1000000 is 0x1000000, one past the 3 byte device number
+	_, err := client.ReadData(context.Background(), "W", "1000000", 1, false)
+	assert.ErrorContains(t, err, "out of range")
+
+	_, err = client.ReadWords(context.Background(), "D", "-1", 1, false)
+	assert.ErrorContains(t, err, "out of range")
+
+	mockMCP.AssertNotCalled(t, "Read", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReadData_Bit(t *testing.T) {
+	mockMCP := new(mockClient)
+	mockMCP.On("ReadBits", "M", int64(41), int64(1)).Return([]bool{true}, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	value, err := client.ReadData(context.Background(), "M", "41", 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), value)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestReadWords(t *testing.T) {
+	// 3E binary response with D100 = 1, D101 = 2, D102 = 3
+	resp, _ := hex.DecodeString("d00000ffff030008000000010002000300")
+	mockMCP := new(mockClient)
+	mockMCP.On("Read", "D", int64(100), int64(3), false).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	words, err := client.ReadWords(context.Background(), "D", "100", 3, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{1, 2, 3}, words)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestReadWords_Fx(t *testing.T) {
+	// 1E binary response with D0-D5 = 1-6, read with one request
+	resp, _ := hex.DecodeString("8100" + "010002000300040005000600")
+	mockMCP := new(mockClient)
+	mockMCP.On("Read", "D", int64(0), int64(6), true).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP, fx: true}
+	words, err := client.ReadWords(context.Background(), "D", "0", 6, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{1, 2, 3, 4, 5, 6}, words)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestReadData_FxBit(t *testing.T) {
+	mockMCP := new(mockClient)
+	// X and Y are octal on FX: X20 is device number 16
+	mockMCP.On("ReadBits", "X", int64(16), int64(1)).Return([]bool{true}, nil).Once()
+
+	client := &MSPClient{client: mockMCP, fx: true}
+	value, err := client.ReadData(context.Background(), "X", "20", 6, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1), value)
+
+	// a 3E client cannot send 1E bit reads
+	_, err = (&MSPClient{client: mockMCP}).ReadData(context.Background(), "X", "20", 6, true)
+	assert.Error(t, err)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestWriteData_Fx(t *testing.T) {
+	mockMCP := new(mockClient)
+	mockMCP.On("WriteBits", "Y", int64(8), []bool{true}).Return([]byte{0x82, 0x00}, nil).Once()
+	mockMCP.On("Write", "D", int64(100), int64(1), []byte{0x34, 0x12}).Return([]byte{0x83, 0x57}, nil).Once()
+
+	client := &MSPClient{client: mockMCP, fx: true}
+	assert.NoError(t, client.WriteData(context.Background(), "Y", "10", []byte{1}, 1))
+
+	err := client.WriteData(context.Background(), "D", "100", []byte{0x34, 0x12}, 1)
+	assert.ErrorIs(t, err, mcp.ErrPointsOutOfRange)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestParseBufferDevice(t *testing.T) {
+	tests := []struct {
+		deviceType, deviceNumber string
+		module                   uint16
+		address                  int64
+		wantErr                  bool
+	}{
+		{deviceType: `U3\G`, deviceNumber: "100", module: 3, address: 100},
+		{deviceType: `U1F\G100`, module: 0x1F, address: 100},
+		{deviceType: `u2\g`, deviceNumber: "0", module: 2, address: 0},
+		{deviceType: `U\G`, deviceNumber: "100", wantErr: true},
+		{deviceType: `U3\G`, deviceNumber: "1A", wantErr: true},
+		{deviceType: `U3\G100`, deviceNumber: "5", wantErr: true}, // not U3\G1005
+	}
+	for _, tt := range tests {
+		module, address, err := parseBufferDevice(tt.deviceType, tt.deviceNumber)
+		if tt.wantErr {
+			assert.Error(t, err, tt.deviceType+tt.deviceNumber)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.module, module)
+		assert.Equal(t, tt.address, address)
+	}
+}
+
+func TestReadData_Buffer(t *testing.T) {
+	mockMCP := new(mockClient)
+	resp, _ := hex.DecodeString("d00000ffff03000400" + "0000" + "e803")
+	mockMCP.On("ReadBuffer", uint16(3), int64(100), int64(1)).Return(resp, nil).Twice()
+
+	client := &MSPClient{client: mockMCP}
+	value, err := client.ReadData(context.Background(), `U3\G`, "100", 1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1000), value)
+
+	// buffer memory is read alone, never merged into random or block reads
+	results := client.ReadDevices(context.Background(), []PLC_Utils.Device{{DeviceType: `U3\G`, DeviceNumber: "100", NumberRegisters: 1}}, false)
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, uint16(1000), results[0].Value)
+	mockMCP.AssertExpectations(t)
+}
+
+func TestWriteData_Buffer(t *testing.T) {
+	mockMCP := new(mockClient)
+	ok, _ := hex.DecodeString("d00000ffff030002000000")
+	mockMCP.On("WriteBuffer", uint16(3), int64(200), int64(1), []byte{0x34, 0x12}).Return(ok, nil).Once()
+	mockMCP.On("WriteBuffer", uint16(3), int64(300), int64(2), []byte{0x01, 0x00, 0x02, 0x00}).Return(ok, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	assert.NoError(t, client.WriteData(context.Background(), `U3\G`, "200", []byte{0x34, 0x12}, 1))
+	assert.NoError(t, client.BatchWrite(context.Background(), `U3\G`, "300", []byte{0x01, 0x00, 0x02, 0x00}, 1, nil))
+	mockMCP.AssertExpectations(t)
+}
+
+// ------------------- Test NewMSPClientWithOptions -------------------
+
+func TestNewMSPClientWithOptions_Frame(t *testing.T) {
+	c3, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c3.MaxInFlight())
+
+	c4, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "4e"})
+	assert.NoError(t, err)
+	assert.Equal(t, maxInFlight4E, c4.MaxInFlight())
+
+	c1, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "1E"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c1.MaxInFlight())
+	assert.True(t, c1.fx)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "2E"})
+	assert.Error(t, err)
+}
+
+func TestNewMSPClientWithOptions_Serial(t *testing.T) {
+	c3, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "3C"})
+	assert.NoError(t, err)
+	// serial frames are ascii: D1000000 fits the binary 3 byte number but not 6 decimal digits
+	assert.Error(t, c3.checkDeviceRange("D", 999999, 2))
+	assert.NoError(t, c3.checkDeviceRange("D", 999998, 2))
+	c3E, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, c3E.checkDeviceRange("D", 999999, 2))
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "4c", SerialStation: 31, SerialFormat: 4})
+	assert.NoError(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "3C", SerialStation: 32})
+	assert.Error(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "3C", SerialFormat: 2})
+	assert.Error(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "3C", Transport: "udp"})
+	assert.Error(t, err)
+}
+
+func TestNewMSPClientWithOptions_Transport(t *testing.T) {
+	_, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Transport: "udp"})
+	assert.NoError(t, err)
+
+	c1, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "1E", Transport: "UDP"})
+	assert.NoError(t, err)
+	assert.True(t, c1.fx)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Frame: "4E", Transport: "udp"})
+	assert.Error(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Transport: "serial"})
+	assert.Error(t, err)
+}
+
+func TestNewMSPClientWithOptions_RemotePassword(t *testing.T) {
+	_, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{RemotePassword: "ab12"})
+	assert.NoError(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{RemotePassword: "ab12", Series: "iQ-R"})
+	assert.Error(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{RemotePassword: "ab12", Frame: "1E"})
+	assert.ErrorIs(t, err, mcp.ErrUnsupported1E)
+}
+
+func TestNewMSPClientWithOptions_Code(t *testing.T) {
+	_, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Code: "ascii"})
+	assert.NoError(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Code: "ebcdic"})
+	assert.Error(t, err)
+}
+
+func TestNewMSPClientWithOptions_Series(t *testing.T) {
+	c, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Series: "iQ-R"})
+	assert.NoError(t, err)
+	assert.Equal(t, mcp.IQRSeries, c.series)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Series: "A"})
+	assert.Error(t, err)
+}
+
+func TestNewMSPClientWithOptions_Route(t *testing.T) {
+	_, err := NewMSPClientWithOptions("127.0.0.1", 5000, Options{Route: "1,2", TargetCPU: "control"})
+	assert.NoError(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{Route: "1"})
+	assert.Error(t, err)
+
+	_, err = NewMSPClientWithOptions("127.0.0.1", 5000, Options{TargetCPU: "9"})
+	assert.Error(t, err)
+}
+
+// ------------------- Test ReadDevices -------------------
+
+func TestReadDevices_Random(t *testing.T) {
+	mockMCP := new(mockClient)
+
+	words := []mcp.Device{{Name: "D", Offset: 100}, {Name: "W", Offset: 0x1A}}
+	dwords := []mcp.Device{{Name: "D", Offset: 650}}
+	// D100=42, W1A=-2, D650=int32 70000
+	resp, _ := hex.DecodeString("d00000ffff03000a00" + "0000" + "2a00" + "feff" + "70110100")
+	mockMCP.On("RandomRead", words, dwords).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP, maxInFlight: 1}
+	results := client.ReadDevices(context.Background(), []PLC_Utils.Device{
+		{DeviceType: "D", DeviceNumber: "100", NumberRegisters: 1},
+		{DeviceType: "D", DeviceNumber: "650", NumberRegisters: 7},
+		{DeviceType: "W", DeviceNumber: "1A", NumberRegisters: 5},
+	}, false)
+
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, uint16(42), results[0].Value)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, int32(70000), results[1].Value)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, int16(-2), results[2].Value)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestReadDevices_Blocks(t *testing.T) {
+	mockMCP := new(mockClient)
+
+	wordBlocks := []mcp.Block{{Device: mcp.Device{Name: "D", Offset: 100}, Points: 4}}
+	// M24-M41 are read in whole words from M16
+	bitBlocks := []mcp.Block{{Device: mcp.Device{Name: "M", Offset: 16}, Points: 2}}
+	// D100=1, D101=2, D102-D103=float 1.5 | M16-M31=0x0100 (M24 on), M32-M47=0x0200 (M41 on)
+	resp, _ := hex.DecodeString("d00000ffff03000e00" + "0000" + "01000200" + "0000c03f" + "00010002")
+	mockMCP.On("ReadBlocks", wordBlocks, bitBlocks).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP, maxInFlight: 1}
+	results := client.ReadDevices(context.Background(), []PLC_Utils.Device{
+		{DeviceType: "M", DeviceNumber: "24", NumberRegisters: 3},
+		{DeviceType: "D", DeviceNumber: "101", NumberRegisters: 1},
+		{DeviceType: "D", DeviceNumber: "100", NumberRegisters: 1},
+		{DeviceType: "M", DeviceNumber: "41", NumberRegisters: 3},
+		{DeviceType: "D", DeviceNumber: "102", NumberRegisters: 2},
+		{DeviceType: "M", DeviceNumber: "40", NumberRegisters: 3},
+	}, false)
+
+	values := make([]any, len(results))
+	for i, r := range results {
+		assert.NoError(t, r.Err)
+		values[i] = r.Value
+	}
+	assert.Equal(t, []any{uint8(1), uint16(2), uint16(1), uint8(1), "1.50000", uint8(0)}, values)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestReadDevices_BitBlockAtTopOfRange(t *testing.T) {
+	mockMCP := new(mockClient)
+
+	// M8185 and M8191 are the last bits of an 8192 point M range: the block must end at M8191,
+	// not 16 bits after M8185
+	bitBlocks := []mcp.Block{{Device: mcp.Device{Name: "M", Offset: 8176}, Points: 1}}
+	// M8176-M8191=0x8200 (M8185 and M8191 on)
+	resp, _ := hex.DecodeString("d00000ffff03000400" + "0000" + "0082")
+	mockMCP.On("ReadBlocks", []mcp.Block(nil), bitBlocks).Return(resp, nil).Once()
+
+	client := &MSPClient{client: mockMCP, maxInFlight: 1}
+	results := client.ReadDevices(context.Background(), []PLC_Utils.Device{
+		{DeviceType: "M", DeviceNumber: "8191", NumberRegisters: 3},
+		{DeviceType: "M", DeviceNumber: "8185", NumberRegisters: 3},
+		{DeviceType: "M", DeviceNumber: "8184", NumberRegisters: 3},
+	}, false)
+
+	values := make([]any, len(results))
+	for i, r := range results {
+		assert.NoError(t, r.Err)
+		values[i] = r.Value
+	}
+	assert.Equal(t, []any{uint8(1), uint8(1), uint8(0)}, values)
+
+	mockMCP.AssertExpectations(t)
+}
+
+func TestPlanScan(t *testing.T) {
+	devices := []PLC_Utils.Device{
+		{DeviceType: "D", DeviceNumber: "0", NumberRegisters: 1},
+		{DeviceType: "D", DeviceNumber: "5", NumberRegisters: 1},   // within the gap of D0
+		{DeviceType: "D", DeviceNumber: "500", NumberRegisters: 1}, // alone
+		{DeviceType: "M", DeviceNumber: "10", NumberRegisters: 1},  // word value on a bit device
+		{DeviceType: "D", DeviceNumber: "20", NumberRegisters: 6},  // not supported by random/block read
+		{DeviceType: "W", DeviceNumber: "1F", NumberRegisters: 1},  // alone
+	}
+
+	plan := planScan(devices, false, mcp.QSeries)
+	assert.Len(t, plan.blocks, 1)
+	assert.Len(t, plan.blocks[0], 1)
+	assert.Equal(t, []int{0, 1}, plan.blocks[0][0].members)
+	assert.Equal(t, int64(6), plan.blocks[0][0].Points)
+	assert.Equal(t, [][]int{{3, 2, 5}}, plan.random)
+	assert.Equal(t, []int{4}, plan.single)
+
+	fxPlan := planScan(devices, true, mcp.QSeries)
+	assert.Empty(t, fxPlan.blocks)
+	assert.Empty(t, fxPlan.random)
+	assert.Len(t, fxPlan.single, len(devices))
+}
+
+// ------------------- Test WriteRandom -------------------
+
+func TestWriteRandom(t *testing.T) {
+	mockMCP := new(mockClient)
+
+	words := []mcp.DeviceValue{{Device: mcp.Device{Name: "D", Offset: 10}, Value: 0x1234}}
+	bits := []mcp.DeviceValue{{Device: mcp.Device{Name: "M", Offset: 5}, Value: 1}}
+	mockMCP.On("RandomWrite", words, []mcp.DeviceValue(nil)).Return(writeOK, nil).Once()
+	mockMCP.On("RandomWriteBits", bits).Return(writeOK, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	err := client.WriteRandom(context.Background(), []PLC_Utils.Device{
+		{DeviceType: "D", DeviceNumber: "10"},
+		{DeviceType: "M", DeviceNumber: "5"},
+	}, [][]byte{{0x34, 0x12}, {0x01}})
+	assert.NoError(t, err)
+
+	mockMCP.AssertExpectations(t)
+}
+
+// ------------------- Test RemoteOperation -------------------
+
+func TestRemoteOperation(t *testing.T) {
+	mockMCP := new(mockClient)
+
+	disabled := &MSPClient{client: mockMCP}
+	assert.Error(t, disabled.RemoteOperation(context.Background(), "stop", "test"))
+
+	mockMCP.On("Remote", mcp.RemoteStop).Return(writeOK, nil).Once()
+	failed, _ := hex.DecodeString("d00000ffff0300020059c0")
+	mockMCP.On("Remote", mcp.RemoteReset).Return(failed, nil).Once()
+
+	var audit strings.Builder
+	enabled := &MSPClient{client: mockMCP, remoteEnabled: true, addr: "192.168.3.39:5000", logger: log.New(&audit, "", 0)}
+	assert.NoError(t, enabled.RemoteOperation(context.Background(), "stop", "test"))
+	assert.Error(t, enabled.RemoteOperation(context.Background(), "reset", "test"))
+	assert.Error(t, enabled.RemoteOperation(context.Background(), "halt", "test"))
+	// audit lines go to the client's logger
+	assert.Contains(t, audit.String(), `AUDIT remote operation stop on PLC 192.168.3.39:5000 requested (reason="test")`)
+	assert.Contains(t, audit.String(), "completed")
+	assert.Contains(t, audit.String(), "failed")
+
+	mockMCP.AssertExpectations(t)
+}
+
+// ------------------- Test Identify -------------------
+
+func TestIdentify(t *testing.T) {
+	mockMCP := new(mockClient)
+	mockMCP.On("ReadCPUModel").Return(&mcp.CPUModel{Name: "R04CPU", Code: 0x4800}, nil).Once()
+
+	client := &MSPClient{client: mockMCP}
+	identity, err := client.Identify(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, plc.Identity{Model: "R04CPU", Code: "4800"}, identity)
+
+	mockMCP.AssertExpectations(t)
+}
+
+// ------------------- Test Connection -------------------
+
+func TestConnection(t *testing.T) {
+	mockMCP := new(mockClient)
+	retryAt := time.Now().Add(time.Second)
+	mockMCP.On("Status").Return(mcp.ConnStatus{State: mcp.Disconnected, Failures: 2, RetryAt: retryAt}).Once()
+
+	client := &MSPClient{client: mockMCP}
+	assert.Equal(t, plc.Connection{State: "disconnected", Failures: 2, RetryAt: retryAt}, client.Connection())
+
+	mockMCP.AssertExpectations(t)
+}
+
+// ------------------- Test against the emulator -------------------
+
+func TestMSPClient_Emulator(t *testing.T) {
+	for _, opts := range []Options{{Frame: "3E"}, {Frame: "3E", Code: "ascii"}, {Frame: "4E"}, {Frame: "1E"}, {Frame: "3E", Transport: "udp"}} {
+		t.Run(opts.Frame+" "+opts.Code+" "+opts.Transport, func(t *testing.T) {
+			sim := mcsim.New(mcsim.Options{})
+			network := "tcp"
+			if opts.Transport == "udp" {
+				network = "udp"
+			}
+			addr, err := sim.Listen(network, "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			defer sim.Close()
+			_, port, _ := net.SplitHostPort(addr.String())
+			portNum, _ := strconv.Atoi(port)
+
+			client, err := NewMSPClientWithOptions("127.0.0.1", portNum, opts)
+			assert.NoError(t, err)
+			ctx := context.Background()
+
+			sim.SetWords("D", 100, 42)
+			value, err := client.ReadData(ctx, "D", "100", 1, false)
+			assert.NoError(t, err)
+			assert.Equal(t, uint16(42), value)
+
+			assert.NoError(t, client.WriteData(ctx, "D", "200", []byte{0x07, 0x00}, 1))
+			words, _ := sim.Words("D", 200, 1)
+			assert.Equal(t, []uint16{7}, words)
+
+			assert.NoError(t, client.WriteBits(ctx, "M", "5", []bool{true}))
+			bits, err := client.ReadBits(ctx, "M", "5", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []bool{true, false}, bits)
+
+			sim.SetEndCode(mcsim.AnyCommand, 0xC056)
+			if opts.Frame == "1E" {
+				sim.SetEndCode(mcsim.AnyCommand, 0x56)
+			}
+			_, err = client.ReadData(ctx, "D", "100", 1, false)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package mitsubishi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+	"github.com/mochigome-git/msp-go/pkg/plc"
+	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
+)
+
+// randomWords returns how many words a device of the given data type occupies in a
+// random read, or 0 when the type has to be read with a batch read instead.
+func randomWords(numberRegisters uint16) int {
+	switch numberRegisters {
+	case 1, 3, 4, 5: // 16-bit values, bit and 2-character ASCII devices fit in one word
+		return 1
+	case 2, 7: // 32-bit float and signed integer
+		return 2
+	default:
+		return 0
+	}
+}
+
+// parseDeviceNumber parses a device address in the device's radix. X, Y, B, W, SB, SW,
+// DX, DY and ZR are hex-addressed on Mitsubishi PLCs; see BatchWrite for why there is no decimal fallback.
+func parseDeviceNumber(deviceType, deviceNumber string) (int64, error) {
+	if mcp.IsHexDevice(deviceType) {
+		return strconv.ParseInt(deviceNumber, 16, 64)
+	}
+	return strconv.ParseInt(deviceNumber, 10, 64)
+}
+
+// readRandom reads devices[indexes] with one random read request and stores
+// each decoded value (or the shared error) in results.
+func (m *MSPClient) readRandom(ctx context.Context, devices []PLC_Utils.Device, indexes []int, results []plc.ReadResult) {
+	fail := func(err error) {
+		for _, i := range indexes {
+			results[i] = plc.ReadResult{Err: err}
+		}
+	}
+
+	var words, dwords []mcp.Device
+	var wordIdx, dwordIdx []int
+	for _, i := range indexes {
+		offset, err := parseDeviceNumber(devices[i].DeviceType, devices[i].DeviceNumber)
+		if err != nil {
+			results[i] = plc.ReadResult{Err: err}
+			continue
+		}
+		d := mcp.Device{Name: devices[i].DeviceType, Offset: offset}
+		if randomWords(devices[i].NumberRegisters) == 2 {
+			dwords = append(dwords, d)
+			dwordIdx = append(dwordIdx, i)
+		} else {
+			words = append(words, d)
+			wordIdx = append(wordIdx, i)
+		}
+	}
+	if len(words)+len(dwords) == 0 {
+		return
+	}
+
+	data, err := m.client.RandomRead(ctx, words, dwords)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	parsed, err := mcp.NewParser().DoRandom(data, len(words), len(dwords))
+	if err != nil {
+		fail(err)
+		return
+	}
+	payload := parsed.Payload
+	if need := 2*len(words) + 4*len(dwords); len(payload) < need {
+		fail(fmt.Errorf("random read response too short: got %d bytes, want %d", len(payload), need))
+		return
+	}
+
+	for n, i := range wordIdx {
+		value, err := decodeData(payload[2*n:2*n+2], int(devices[i].NumberRegisters))
+		results[i] = plc.ReadResult{Value: value, Err: err}
+	}
+	base := 2 * len(words)
+	for n, i := range dwordIdx {
+		value, err := decodeData(payload[base+4*n:base+4*n+4], int(devices[i].NumberRegisters))
+		results[i] = plc.ReadResult{Value: value, Err: err}
+	}
+}
+
+// WriteRandom writes values encoded by EncodeData to scattered devices.
+// One-byte values (EncodeData bit values) are written with a random write in bit units,
+// 2-byte values as words and 4-byte values as double words, so a mixed list needs at most two requests.
+func (m *MSPClient) WriteRandom(ctx context.Context, devices []PLC_Utils.Device, values [][]byte) error {
+	if m == nil || m.client == nil {
+		return fmt.Errorf("MSP client not initialized")
+	}
+	if len(devices) != len(values) {
+		return fmt.Errorf("WriteRandom: %d devices but %d values", len(devices), len(values))
+	}
+
+	var bits, words, dwords []mcp.DeviceValue
+	for i, device := range devices {
+		offset, err := m.parseDeviceNumber(device.DeviceType, device.DeviceNumber)
+		if err != nil {
+			return err
+		}
+		d := mcp.Device{Name: device.DeviceType, Offset: offset}
+
+		v := values[i]
+		switch len(v) {
+		case 1:
+			bits = append(bits, mcp.DeviceValue{Device: d, Value: uint32(v[0])})
+		case 2:
+			words = append(words, mcp.DeviceValue{Device: d, Value: uint32(v[0]) | uint32(v[1])<<8})
+		case 4:
+			dwords = append(dwords, mcp.DeviceValue{Device: d, Value: uint32(v[0]) | uint32(v[1])<<8 | uint32(v[2])<<16 | uint32(v[3])<<24})
+		default:
+			return fmt.Errorf("WriteRandom: %s%s value of %d bytes cannot be written randomly", device.DeviceType, device.DeviceNumber, len(v))
+		}
+	}
+
+	if len(words)+len(dwords) > 0 {
+		if err := m.checkEndCode(m.client.RandomWrite(ctx, words, dwords)); err != nil {
+			return err
+		}
+	}
+	if len(bits) > 0 {
+		if err := m.checkEndCode(m.client.RandomWriteBits(ctx, bits)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
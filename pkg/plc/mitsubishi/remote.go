@@ -0,0 +1,47 @@
+package mitsubishi
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+)
+
+// RemoteOperation runs a remote RUN/STOP/PAUSE/latch clear/reset ("run", "stop", "pause",
+// "latch-clear", "reset") on the PLC. reason is recorded in the audit log line written for
+// every attempt, including refused ones, to Options.Logger. It fails unless
+// Options.EnableRemoteOperation is set.
+func (m *MSPClient) RemoteOperation(ctx context.Context, op string, reason string) error {
+	if m == nil || m.client == nil {
+		return fmt.Errorf("MSP client not initialized")
+	}
+
+	remoteOp, err := mcp.ParseRemoteOp(op)
+	if err != nil {
+		return err
+	}
+
+	if !m.remoteEnabled {
+		m.auditf("AUDIT remote operation %s on PLC %s refused: remote operation is disabled (reason=%q)", remoteOp, m.addr, reason)
+		return fmt.Errorf("remote operation %s is disabled for PLC %s", remoteOp, m.addr)
+	}
+	m.auditf("AUDIT remote operation %s on PLC %s requested (reason=%q)", remoteOp, m.addr, reason)
+
+	err = m.checkEndCode(m.client.Remote(ctx, remoteOp))
+	if err != nil {
+		m.auditf("AUDIT remote operation %s on PLC %s failed: %v", remoteOp, m.addr, err)
+		return err
+	}
+	m.auditf("AUDIT remote operation %s on PLC %s completed", remoteOp, m.addr)
+	return nil
+}
+
+// auditf writes an audit log line to the logger of the client, or the standard logger without one.
+func (m *MSPClient) auditf(format string, args ...any) {
+	logger := m.logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf(format, args...)
+}
@@ -0,0 +1,270 @@
+package mitsubishi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mochigome-git/msp-go/pkg/mcp"
+	"github.com/mochigome-git/msp-go/pkg/plc"
+	PLC_Utils "github.com/mochigome-git/msp-go/pkg/utils"
+)
+
+// blockGapWords is how many unused words a block may span between two tags
+// before it is cheaper to split it. A random read point costs 4 request bytes,
+// so reading a few unused words is cheaper than another block or random point.
+const blockGapWords = 8
+
+// scanBlock is one block of a multiple block batch read and the devices it covers.
+type scanBlock struct {
+	mcp.Block
+	// bit is true for bit device blocks, whose offsets count bits and points count words;
+	// their offset is a multiple of 16
+	bit bool
+	// members are indexes into the scanned device list
+	members []int
+	// offsets are the parsed device numbers of members
+	offsets []int64
+}
+
+// scanPlan groups the devices of one scan into as few requests as possible.
+type scanPlan struct {
+	// blocks holds one multiple block batch read request per element
+	blocks [][]scanBlock
+	// random holds one random read request per element (device indexes)
+	random [][]int
+	// single are devices read one by one with ReadData
+	single []int
+}
+
+// planScan builds the scan plan for devices. Nearby tags of the same device type are
+// merged into blocks, scattered tags share random reads, and whatever neither command
+// supports (FX, 16-bit multi-register types) is read one by one. Bit device blocks start
+// and end on word boundaries (multiples of 16 bits).
+// series selects the block and random point limits of each request.
+func planScan(devices []PLC_Utils.Device, fx bool, series mcp.Series) scanPlan {
+	var plan scanPlan
+	if fx {
+		// 1E frames have neither random nor multiple block read
+		for i := range devices {
+			plan.single = append(plan.single, i)
+		}
+		return plan
+	}
+
+	type tag struct {
+		index  int
+		offset int64
+	}
+	groups := map[string][]tag{} // device type (+ "/bit" for bit device blocks) -> tags
+	var keys []string
+	var random []int
+
+	for i, device := range devices {
+		if randomWords(device.NumberRegisters) == 0 || !series.HasDevice(device.DeviceType) {
+			// unknown devices are read alone so ReadData reports them without failing a whole request
+			plan.single = append(plan.single, i)
+			continue
+		}
+		offset, err := parseDeviceNumber(device.DeviceType, device.DeviceNumber)
+		if err != nil {
+			plan.single = append(plan.single, i) // ReadData reports the address error
+			continue
+		}
+
+		key := device.DeviceType
+		if mcp.IsBitDevice(device.DeviceType) {
+			if device.NumberRegisters != 3 {
+				// word values starting at an arbitrary bit cannot be cut out of a block
+				random = append(random, i)
+				continue
+			}
+			key += "/bit"
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], tag{index: i, offset: offset})
+	}
+
+	// merge each device type into runs of nearby tags
+	var blocks []scanBlock
+	for _, key := range keys {
+		tags := groups[key]
+		sort.SliceStable(tags, func(a, b int) bool { return tags[a].offset < tags[b].offset })
+		bit := mcp.IsBitDevice(devices[tags[0].index].DeviceType)
+
+		var cur *scanBlock
+		var end int64 // exclusive end of cur, in device units
+		for _, t := range tags {
+			width := int64(randomWords(devices[t.index].NumberRegisters))
+			gap := int64(blockGapWords)
+			if bit {
+				width, gap = 1, 16*blockGapWords
+			}
+
+			if cur != nil && t.offset-end <= gap && blockPoints(cur.Offset, max(end, t.offset+width), bit) <= mcp.MAX_BLOCK_POINTS {
+				end = max(end, t.offset+width)
+				cur.Points = blockPoints(cur.Offset, end, bit)
+				cur.members = append(cur.members, t.index)
+				cur.offsets = append(cur.offsets, t.offset)
+				continue
+			}
+
+			if cur != nil {
+				blocks = append(blocks, *cur)
+			}
+			end = t.offset + width
+			start := t.offset
+			if bit {
+				// bit device ranges are set in multiples of 16 points, so a block of whole aligned
+				// words never runs past the top of the range (C056) the way an unaligned one can
+				start = t.offset &^ 15
+			}
+			cur = &scanBlock{
+				Block:   mcp.Block{Device: mcp.Device{Name: devices[t.index].DeviceType, Offset: start}, Points: blockPoints(start, end, bit)},
+				bit:     bit,
+				members: []int{t.index},
+				offsets: []int64{t.offset},
+			}
+		}
+		if cur != nil {
+			blocks = append(blocks, *cur)
+		}
+	}
+
+	// a block with a single tag is cheaper as a random read point
+	var request []scanBlock
+	var points int64
+	for _, b := range blocks {
+		if len(b.members) == 1 {
+			random = append(random, b.members[0])
+			continue
+		}
+		if len(request) == series.MaxBlocks() || points+b.Points > mcp.MAX_BLOCK_POINTS {
+			plan.blocks = append(plan.blocks, request)
+			request, points = nil, 0
+		}
+		request = append(request, b)
+		points += b.Points
+	}
+	if len(request) > 0 {
+		plan.blocks = append(plan.blocks, request)
+	}
+
+	for len(random) > 0 {
+		n := min(len(random), series.MaxRandomReadPoints())
+		plan.random = append(plan.random, random[:n])
+		random = random[n:]
+	}
+
+	return plan
+}
+
+// blockPoints returns the points of a block covering [start, end) in device units.
+// Bit device blocks are read in words of 16 bits.
+func blockPoints(start, end int64, bit bool) int64 {
+	if bit {
+		return (end - start + 15) / 16
+	}
+	return end - start
+}
+
+// ReadDevices satisfies plc.MultiReader. It reads devices following planScan:
+// multiple block batch reads for clustered tags, random reads for scattered ones and
+// ReadData for the rest. Requests are issued concurrently up to MaxInFlight.
+func (m *MSPClient) ReadDevices(ctx context.Context, devices []PLC_Utils.Device, fx bool) []plc.ReadResult {
+	results := make([]plc.ReadResult, len(devices))
+	if m == nil || m.client == nil {
+		for i := range results {
+			results[i].Err = fmt.Errorf("MSP client not initialized")
+		}
+		return results
+	}
+
+	plan := planScan(devices, fx, m.series)
+
+	var jobs []func()
+	for _, blocks := range plan.blocks {
+		jobs = append(jobs, func() { m.readBlocks(ctx, devices, blocks, results) })
+	}
+	for _, indexes := range plan.random {
+		jobs = append(jobs, func() { m.readRandom(ctx, devices, indexes, results) })
+	}
+	for _, i := range plan.single {
+		jobs = append(jobs, func() {
+			device := devices[i]
+			value, err := m.ReadData(ctx, device.DeviceType, device.DeviceNumber, device.NumberRegisters, fx)
+			results[i] = plc.ReadResult{Value: value, Err: err}
+		})
+	}
+
+	sem := make(chan struct{}, m.MaxInFlight())
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(job func()) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			job()
+		}(job)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// readBlocks reads blocks with one multiple block batch read request and stores
+// the decoded value of every member device (or the shared error) in results.
+func (m *MSPClient) readBlocks(ctx context.Context, devices []PLC_Utils.Device, blocks []scanBlock, results []plc.ReadResult) {
+	var wordBlocks, bitBlocks []mcp.Block
+	var ordered []scanBlock // word blocks first, matching the response layout
+	for _, b := range blocks {
+		if !b.bit {
+			wordBlocks = append(wordBlocks, b.Block)
+			ordered = append(ordered, b)
+		}
+	}
+	for _, b := range blocks {
+		if b.bit {
+			bitBlocks = append(bitBlocks, b.Block)
+			ordered = append(ordered, b)
+		}
+	}
+
+	fail := func(err error) {
+		for _, b := range blocks {
+			for _, i := range b.members {
+				results[i] = plc.ReadResult{Err: err}
+			}
+		}
+	}
+
+	resp, err := m.client.ReadBlocks(ctx, wordBlocks, bitBlocks)
+	if err != nil {
+		fail(err)
+		return
+	}
+	_, data, err := mcp.NewParser().DoBlocks(resp, wordBlocks, bitBlocks)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	for n, b := range ordered {
+		for k, i := range b.members {
+			rel := b.offsets[k] - b.Offset
+			if b.bit {
+				// bit blocks are read in words, lower byte first, first device in bit 0
+				bit := data[n][2*(rel/16)+(rel%16)/8] >> (rel % 8) & 0x01
+				results[i] = plc.ReadResult{Value: uint8(bit)}
+				continue
+			}
+			width := int64(randomWords(devices[i].NumberRegisters))
+			value, err := decodeData(data[n][2*rel:2*(rel+width)], int(devices[i].NumberRegisters))
+			results[i] = plc.ReadResult{Value: value, Err: err}
+		}
+	}
+}
@@ -77,8 +77,8 @@ func (c *Client) ScanPorts() map[int]bool {
 // ReadHolding reads count holding registers (FC03) starting at addr.
 // Use this for initial discovery — dump registers 0–50 while watching
 // the HMI to build your register map.
-func (c *Client) ReadHolding(startAddr, count uint16) ([]uint16, error) {
-	raw, err := c.modbusRequest(0x03, startAddr, count)
+func (c *Client) ReadHolding(ctx context.Context, startAddr, count uint16) ([]uint16, error) {
+	raw, err := c.modbusRequest(ctx, 0x03, startAddr, count)
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +87,8 @@ func (c *Client) ReadHolding(startAddr, count uint16) ([]uint16, error) {
 
 // ReadInput reads count input registers (FC04) starting at addr.
 // Some PLCs put read-only process values here instead of holding registers.
-func (c *Client) ReadInput(startAddr, count uint16) ([]uint16, error) {
-	raw, err := c.modbusRequest(0x04, startAddr, count)
+func (c *Client) ReadInput(ctx context.Context, startAddr, count uint16) ([]uint16, error) {
+	raw, err := c.modbusRequest(ctx, 0x04, startAddr, count)
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +96,8 @@ func (c *Client) ReadInput(startAddr, count uint16) ([]uint16, error) {
 }
 
 // ReadCoils reads count coil bits (FC01) — discrete boolean outputs.
-func (c *Client) ReadCoils(startAddr, count uint16) ([]bool, error) {
-	raw, err := c.modbusRequest(0x01, startAddr, count)
+func (c *Client) ReadCoils(ctx context.Context, startAddr, count uint16) ([]bool, error) {
+	raw, err := c.modbusRequest(ctx, 0x01, startAddr, count)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +105,8 @@ func (c *Client) ReadCoils(startAddr, count uint16) ([]bool, error) {
 }
 
 // ReadDiscreteInputs reads count discrete input bits (FC02).
-func (c *Client) ReadDiscreteInputs(startAddr, count uint16) ([]bool, error) {
-	raw, err := c.modbusRequest(0x02, startAddr, count)
+func (c *Client) ReadDiscreteInputs(ctx context.Context, startAddr, count uint16) ([]bool, error) {
+	raw, err := c.modbusRequest(ctx, 0x02, startAddr, count)
 	if err != nil {
 		return nil, err
 	}
@@ -139,17 +139,18 @@ func (c *Client) ReadData(
 
 	switch deviceType {
 	case "X", "I":
-		return c.ReadDiscreteInputs(startAddr, numberRegisters)
+		return c.ReadDiscreteInputs(ctx, startAddr, numberRegisters)
 	case "Y", "O":
-		return c.ReadCoils(startAddr, numberRegisters)
+		return c.ReadCoils(ctx, startAddr, numberRegisters)
 	default:
-		return c.ReadHolding(startAddr, numberRegisters)
+		return c.ReadHolding(ctx, startAddr, numberRegisters)
 	}
 }
 
 // WriteData is disabled until the Shibaura register map is confirmed.
 // Writing unknown registers to a live injection molding machine is dangerous.
 func (c *Client) WriteData(
+	ctx context.Context,
 	deviceType string,
 	deviceNumber string,
 	writeData []byte,
@@ -162,6 +163,7 @@ func (c *Client) WriteData(
 
 // BatchWrite is disabled for the same safety reason as WriteData.
 func (c *Client) BatchWrite(
+	ctx context.Context,
 	deviceType string,
 	startDevice string,
 	writeData []byte,
@@ -171,15 +173,35 @@ func (c *Client) BatchWrite(
 	return fmt.Errorf("shibaura: BatchWrite disabled — see WriteData")
 }
 
+// Ping reads one holding register and returns the round trip time.
+// Modbus TCP has no loopback function, so this is the cheapest request that proves the PLC answers.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if _, err := c.ReadHolding(ctx, 0, 1); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
 // ── internal Modbus TCP framing ───────────────────────────────────────────────
 
-func (c *Client) modbusRequest(fc byte, addr, count uint16) ([]byte, error) {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", c.host, c.port), c.timeout)
+// modbusRequest sends one request on a fresh connection. It gives up after c.timeout
+// or when ctx is done, whichever comes first.
+func (c *Client) modbusRequest(ctx context.Context, fc byte, addr, count uint16) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", c.host, c.port))
 	if err != nil {
 		return nil, fmt.Errorf("shibaura: connect %s:%d: %w", c.host, c.port, err)
 	}
 	defer conn.Close()
-	conn.SetDeadline(time.Now().Add(c.timeout))
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// closing conn unblocks the reads below as soon as ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
 	// Modbus TCP ADU: MBAP header (7 bytes) + PDU (6 bytes)
 	req := make([]byte, 12)